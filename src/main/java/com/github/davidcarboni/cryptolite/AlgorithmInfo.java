@@ -0,0 +1,67 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.util.Collections;
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Describes an algorithm this library supports, as returned by {@link Crypto#supportedCiphers()}
+ * and {@link Keys#supportedKdfs()}.
+ * <p>
+ * This is useful for audits, or for building a user interface without hard-coding the options.
+ *
+ * @author David Carboni
+ */
+public class AlgorithmInfo {
+
+    private final String name;
+    private final String usedBy;
+    private final int keySize;
+    private final Map<String, String> defaults;
+
+    /**
+     * @param name     The algorithm name, as passed to the JCE (or the standard name, if it isn't provided by the JCE).
+     * @param usedBy   The simple name of the class that uses the algorithm.
+     * @param keySize  The key size, in bits. For a key derivation function, this is the size of the derived key.
+     * @param defaults The default parameters, by name, in the order they should be displayed.
+     */
+    public AlgorithmInfo(String name, String usedBy, int keySize, Map<String, String> defaults) {
+        this.name = name;
+        this.usedBy = usedBy;
+        this.keySize = keySize;
+        this.defaults = Collections.unmodifiableMap(new LinkedHashMap<>(defaults));
+    }
+
+    /**
+     * @return The algorithm name.
+     */
+    public String getName() {
+        return name;
+    }
+
+    /**
+     * @return The simple name of the class that uses the algorithm.
+     */
+    public String getUsedBy() {
+        return usedBy;
+    }
+
+    /**
+     * @return The key size, in bits. For a key derivation function, this is the size of the derived key.
+     */
+    public int getKeySize() {
+        return keySize;
+    }
+
+    /**
+     * @return The default parameters, by name. This map can't be modified.
+     */
+    public Map<String, String> getDefaults() {
+        return defaults;
+    }
+
+    @Override
+    public String toString() {
+        return name + " (" + usedBy + ", " + keySize + "-bit) " + defaults;
+    }
+}
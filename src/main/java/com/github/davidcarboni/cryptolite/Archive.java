@@ -0,0 +1,216 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+import java.io.ByteArrayOutputStream;
+import java.io.DataInputStream;
+import java.io.DataOutputStream;
+import java.io.EOFException;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.nio.ByteBuffer;
+import java.security.MessageDigest;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+
+/**
+ * This class seals multiple files into a single authenticated archive, for example to back up a directory.
+ * <p>
+ * Each file is written as a section of chunks of up to {@value #CHUNK_SIZE} bytes, each encrypted with
+ * {@link AuthenticatedCrypto}, followed by an end-of-file marker. After the last file comes a manifest
+ * listing the name, size and {@value Digest#SHA256} digest of each file, in order. Every block
+ * (chunk, marker or manifest) is authenticated, and {@link #open(SecretKey, InputStream)} checks each
+ * file against the manifest before returning anything, so missing, reordered or swapped chunks
+ * and files are detected.
+ * <p>
+ * On the wire, each block is a {@value #LENGTH_BYTES}-byte, big-endian length followed by the encrypted
+ * block. The first byte of each decrypted block gives its type.
+ *
+ * @author David Carboni
+ */
+public class Archive {
+
+    /**
+     * The maximum number of file bytes encrypted in each chunk.
+     */
+    public static final int CHUNK_SIZE = 64 * 1024;
+
+    /**
+     * The number of bytes used for the length prefix of each block.
+     */
+    public static final int LENGTH_BYTES = 4;
+
+    /**
+     * The largest encrypted block that will be read. This limits the size of the manifest.
+     */
+    public static final int MAX_BLOCK_BYTES = 16 * 1024 * 1024;
+
+    private static final byte TYPE_CHUNK = 1;
+    private static final byte TYPE_END_OF_FILE = 2;
+    private static final byte TYPE_MANIFEST = 3;
+
+    private static final AuthenticatedCrypto crypto = new AuthenticatedCrypto();
+
+    /**
+     * Seals the given files into an archive.
+     *
+     * @param key         The key to encrypt the archive with.
+     * @param files       The files to seal, keyed by name. Use a {@link LinkedHashMap} if the order matters to you.
+     * @param destination Where the archive will be written. This stream is flushed, but not closed.
+     * @throws IOException If an error occurs reading the files or writing the archive.
+     */
+    public static void seal(SecretKey key, Map<String, InputStream> files, OutputStream destination) throws IOException {
+
+        DataOutputStream out = new DataOutputStream(destination);
+        Frame manifest = new Frame();
+        byte[] buffer = new byte[CHUNK_SIZE];
+
+        for (Map.Entry<String, InputStream> file : files.entrySet()) {
+            DigestWriter digest = new DigestWriter();
+            long size = 0;
+            int read;
+            while ((read = readChunk(file.getValue(), buffer)) > 0) {
+                digest.write(buffer, 0, read);
+                size += read;
+                writeBlock(out, TYPE_CHUNK, ByteArray.splitAt(buffer, read)[0], key);
+            }
+            writeBlock(out, TYPE_END_OF_FILE, new byte[0], key);
+
+            manifest.addField(ByteArray.fromString(file.getKey()))
+                    .addField(ByteBuffer.allocate(8).putLong(size).array())
+                    .addField(digest.sum());
+        }
+
+        writeBlock(out, TYPE_MANIFEST, manifest.toByteArray(), key);
+        out.flush();
+    }
+
+    /**
+     * Opens an archive created by {@link #seal(SecretKey, Map, OutputStream)}.
+     * <p>
+     * The whole archive is decrypted into memory and verified against the manifest before anything is returned.
+     *
+     * @param key    The key the archive was encrypted with.
+     * @param source The archive. This stream is read to the end, but not closed.
+     * @return The files, keyed by name, in the order they were sealed.
+     * @throws IOException             If an error occurs reading the archive.
+     * @throws MalformedDataException  If the archive is not in the expected format, for example if it's truncated.
+     * @throws AuthenticationException If the key is wrong or the archive has been altered.
+     */
+    public static Map<String, byte[]> open(SecretKey key, InputStream source) throws IOException {
+
+        DataInputStream in = new DataInputStream(source);
+        List<byte[]> contents = new ArrayList<>();
+        ByteArrayOutputStream current = new ByteArrayOutputStream();
+
+        byte[] block;
+        while ((block = readBlock(in, key))[0] != TYPE_MANIFEST) {
+            if (block[0] == TYPE_CHUNK) {
+                current.write(block, 1, block.length - 1);
+            } else if (block[0] == TYPE_END_OF_FILE) {
+                contents.add(current.toByteArray());
+                current.reset();
+            } else {
+                throw new MalformedDataException("Unknown archive block type: " + block[0]);
+            }
+        }
+        if (current.size() > 0 || in.read() != -1) {
+            throw new MalformedDataException("Are you sure this is an archive? The manifest isn't at the end.");
+        }
+
+        return verify(ByteArray.splitAt(block, 1)[1], contents);
+    }
+
+    /**
+     * Checks the decrypted files against the manifest.
+     *
+     * @param manifest The framed manifest.
+     * @param contents The decrypted files, in order.
+     * @return The files, keyed by name.
+     */
+    private static Map<String, byte[]> verify(byte[] manifest, List<byte[]> contents) {
+
+        byte[][] fields = ByteArray.unframe(manifest);
+        if (fields.length != contents.size() * 3) {
+            throw new AuthenticationException("The archive contains " + contents.size()
+                    + " files, which doesn't match the manifest.");
+        }
+
+        Map<String, byte[]> result = new LinkedHashMap<>();
+        for (int i = 0; i < contents.size(); i++) {
+            String name = ByteArray.toString(fields[i * 3]);
+            byte[] content = contents.get(i);
+            if (fields[i * 3 + 1].length != 8 || ByteBuffer.wrap(fields[i * 3 + 1]).getLong() != content.length) {
+                throw new AuthenticationException("The size of " + name + " doesn't match the manifest.");
+            }
+            if (!MessageDigest.isEqual(fields[i * 3 + 2], Digest.sha256(content))) {
+                throw new AuthenticationException("The digest of " + name + " doesn't match the manifest.");
+            }
+            if (result.put(name, content) != null) {
+                throw new MalformedDataException("The manifest lists " + name + " more than once.");
+            }
+        }
+        return result;
+    }
+
+    /**
+     * Encrypts and writes a block.
+     *
+     * @param out  The archive.
+     * @param type The block type.
+     * @param data The block content.
+     * @param key  The encryption key.
+     * @throws IOException If an error occurs writing the block.
+     */
+    private static void writeBlock(DataOutputStream out, byte type, byte[] data, SecretKey key) throws IOException {
+        byte[] encrypted = crypto.encrypt(ByteArray.concat(new byte[]{type}, data), key);
+        out.writeInt(encrypted.length);
+        out.write(encrypted);
+    }
+
+    /**
+     * Reads and decrypts a block.
+     *
+     * @param in  The archive.
+     * @param key The encryption key.
+     * @return The decrypted block, starting with the block type.
+     * @throws IOException If an error occurs reading the block.
+     */
+    private static byte[] readBlock(DataInputStream in, SecretKey key) throws IOException {
+        try {
+            int length = in.readInt();
+            if (length < 0 || length > MAX_BLOCK_BYTES) {
+                throw new MalformedDataException("Are you sure this is an archive? Block length (" + length
+                        + ") is outside the range 0-" + MAX_BLOCK_BYTES + ".");
+            }
+            byte[] encrypted = new byte[length];
+            in.readFully(encrypted);
+            byte[] block = crypto.decrypt(encrypted, key);
+            if (block.length < 1) {
+                throw new MalformedDataException("Are you sure this is an archive? The block type is missing.");
+            }
+            return block;
+        } catch (EOFException e) {
+            throw new MalformedDataException("Are you sure this is an archive? It ended before the manifest.", e);
+        }
+    }
+
+    /**
+     * Reads as many bytes as are available, up to the size of the buffer.
+     *
+     * @param in     The stream to read from.
+     * @param buffer The buffer to fill.
+     * @return The number of bytes read, which is only less than the buffer size at the end of the stream.
+     * @throws IOException If an error occurs reading the stream.
+     */
+    private static int readChunk(InputStream in, byte[] buffer) throws IOException {
+        int total = 0;
+        int read;
+        while (total < buffer.length && (read = in.read(buffer, total, buffer.length - total)) != -1) {
+            total += read;
+        }
+        return total;
+    }
+}
@@ -0,0 +1,24 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Implement this interface to throttle attempts to generate a key from a PIN
+ * (see {@link Keys#generateSecretKeyFromPin(String, String, KdfParameters, AttemptRecorder)}).
+ * <p>
+ * A PIN has so few possible values that a slow key derivation function can only do so much.
+ * The real protection comes from limiting the number of guesses, for example by locking out
+ * after a number of failures. Cryptolite can't tell whether a generated key is correct, so it's
+ * up to you to track failures (e.g. when decryption with the generated key fails) and
+ * reset the count when an attempt succeeds.
+ *
+ * @author David Carboni
+ */
+public interface AttemptRecorder {
+
+    /**
+     * Called before each attempt to generate a key.
+     *
+     * @return If the attempt should be allowed, true. If false, the attempt is refused
+     * by throwing {@link AttemptsExceededException}.
+     */
+    boolean recordAttempt();
+}
@@ -0,0 +1,17 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown when an {@link AttemptRecorder} refuses an attempt, for example because
+ * too many incorrect PINs have been tried.
+ *
+ * @author David Carboni
+ */
+public class AttemptsExceededException extends IllegalStateException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public AttemptsExceededException(String message) {
+        super(message);
+    }
+}
@@ -0,0 +1,1219 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.lang.ArrayUtils;
+import org.apache.commons.lang.StringUtils;
+
+import javax.crypto.BadPaddingException;
+import javax.crypto.Cipher;
+import javax.crypto.IllegalBlockSizeException;
+import javax.crypto.NoSuchPaddingException;
+import javax.crypto.SecretKey;
+import javax.crypto.spec.GCMParameterSpec;
+import java.nio.ByteBuffer;
+import java.security.InvalidAlgorithmParameterException;
+import java.security.InvalidKeyException;
+import java.security.NoSuchAlgorithmException;
+import java.util.Date;
+import java.util.Map;
+import java.util.TreeMap;
+
+/**
+ * This class provides authenticated encryption and decryption of Strings and byte arrays.
+ * <p>
+ * Unlike {@link Crypto}, which uses CTR mode and is therefore "malleable", this class uses
+ * {@value #CIPHER_ALGORITHM} in {@value #CIPHER_MODE} mode. GCM includes an authentication tag in
+ * the ciphertext, so any alteration of the encrypted data (or use of the wrong key) is detected on
+ * decryption rather than silently producing garbage.
+ * <p>
+ * The encrypted output is laid out as follows:
+ * <ul>
+ * <li>One byte giving the format version ({@value #FORMAT_VERSION}).</li>
+ * <li>One byte identifying the cipher ({@value #CIPHER_ID} for {@value #CIPHER_NAME}).</li>
+ * <li>One byte giving the nonce size, so decryption reads the right number of nonce bytes.</li>
+ * <li>The random nonce (also known as an initialisation vector).</li>
+ * <li>The ciphertext, including the authentication tag ({@value #TAG_BITS} bits by default).</li>
+ * </ul>
+ * The header (version, cipher and nonce size) is authenticated as associated data, so altering it is
+ * detected in the same way as altering the ciphertext.
+ * <p>
+ * Decryption reads the version byte and handles each format it knows about, so data encrypted
+ * by older versions of the library remain decryptable. Version {@value #FORMAT_VERSION_1} had no version
+ * byte: its first byte was the cipher identifier, which happened to be {@value #FORMAT_VERSION_1}.
+ * Data specifying any other version cause an {@link UnsupportedVersionException}.
+ * <p>
+ * {@link #encryptWithTimestamp(byte[], SecretKey)} uses version {@value #FORMAT_VERSION_TIMESTAMPED},
+ * which adds an {@value #TIMESTAMP_BYTES}-byte creation time to the header, after the version byte.
+ * Like the rest of the header, the timestamp is authenticated.
+ * <p>
+ * {@link #encryptCompact(byte[], SecretKey)} uses a smaller layout for tiny values, such as individual
+ * database fields: a single byte combining the version ({@value #FORMAT_VERSION_COMPACT}) and cipher, followed by an
+ * {@value #COMPACT_NONCE_SIZE}-byte nonce. See that method for when it's appropriate.
+ * <p>
+ * The nonce size defaults to {@value #NONCE_SIZE} bytes, as recommended by NIST SP 800-38D.
+ * Some systems use a different size, so you can use {@link #AuthenticatedCrypto(int)} if you
+ * need to interoperate with them.
+ * <p>
+ * Similarly, some protocols truncate the authentication tag, so you can use {@link #AuthenticatedCrypto(int, int)}
+ * to set a shorter tag. Output with a non-default tag length uses version {@value #FORMAT_VERSION_TAG_LENGTH},
+ * which adds a byte giving the tag length in bytes after the version byte.
+ * <p>
+ * If decryption fails, a subclass of {@link DecryptionException} is thrown so you can tell
+ * the cause apart.
+ *
+ * @author David Carboni
+ */
+public class AuthenticatedCrypto {
+
+    /**
+     * The name of the cipher algorithm to use for authenticated encryption.
+     */
+    public static final String CIPHER_ALGORITHM = "AES";
+    /**
+     * The name of the cipher mode to use for authenticated encryption.
+     */
+    public static final String CIPHER_MODE = "GCM";
+    /**
+     * The name of the padding type to use for authenticated encryption.
+     */
+    public static final String CIPHER_PADDING = "NoPadding";
+
+    /**
+     * The full name of the {@link Cipher} to use for authenticated encryption,
+     * in a format suitable for passing to the JCE.
+     */
+    public static final String CIPHER_NAME = CIPHER_ALGORITHM + "/" + CIPHER_MODE + "/" + CIPHER_PADDING;
+
+    /**
+     * The default nonce size, in bytes.
+     */
+    public static final int NONCE_SIZE = 12;
+
+    /**
+     * The authentication tag length, in bits.
+     */
+    public static final int TAG_BITS = 128;
+
+    /**
+     * The identifier written to the header of encrypted output for {@value #CIPHER_NAME}.
+     */
+    public static final int CIPHER_ID = 1;
+
+    /**
+     * The format version written to the start of encrypted output.
+     */
+    public static final int FORMAT_VERSION = 2;
+
+    /**
+     * The original format version, which has a header of just the cipher and nonce size.
+     */
+    public static final int FORMAT_VERSION_1 = 1;
+
+    /**
+     * The format version for output that includes a creation timestamp in the header.
+     */
+    public static final int FORMAT_VERSION_TIMESTAMPED = 3;
+
+    /**
+     * The format version for compact output, which has a one-byte header and a shorter nonce.
+     */
+    public static final int FORMAT_VERSION_COMPACT = 4;
+
+    /**
+     * The format version for output with an authentication tag shorter than {@value #TAG_BITS} bits,
+     * which records the tag length in the header.
+     */
+    public static final int FORMAT_VERSION_TAG_LENGTH = 5;
+
+    /**
+     * The shortest authentication tag length that can be configured, in bits.
+     */
+    public static final int MIN_TAG_BITS = 96;
+
+    /**
+     * The single header byte of compact output: the version in the high four bits and the cipher identifier in the low four.
+     */
+    public static final int COMPACT_HEADER = FORMAT_VERSION_COMPACT << 4 | CIPHER_ID;
+
+    /**
+     * The nonce size, in bytes, for compact output.
+     */
+    public static final int COMPACT_NONCE_SIZE = 8;
+
+    /**
+     * The number of bytes used for the creation timestamp, in milliseconds since the epoch.
+     */
+    public static final int TIMESTAMP_BYTES = 8;
+
+    /**
+     * The number of bytes at the start of the encrypted output which give the version, cipher and nonce size.
+     */
+    private static final int HEADER_SIZE = 3;
+
+    /**
+     * The number of header bytes in the {@value #FORMAT_VERSION_1} format.
+     */
+    private static final int HEADER_SIZE_V1 = 2;
+
+    /**
+     * The number of header bytes in the {@value #FORMAT_VERSION_TIMESTAMPED} format.
+     */
+    private static final int HEADER_SIZE_TIMESTAMPED = HEADER_SIZE + TIMESTAMP_BYTES;
+
+    /**
+     * The number of header bytes in the {@value #FORMAT_VERSION_TAG_LENGTH} format.
+     */
+    private static final int HEADER_SIZE_TAG_LENGTH = HEADER_SIZE + 1;
+
+    /**
+     * The label that distinguishes type tags from other associated data.
+     */
+    private static final String TAG_LABEL = "cryptolite-type-tag";
+
+    /**
+     * The number of bytes used to record the original length of padded data.
+     */
+    public static final int PADDING_LENGTH_BYTES = 4;
+
+    /**
+     * The number of bytes used to record each key derivation parameter for password-based encryption.
+     */
+    public static final int KDF_PARAMETER_BYTES = 4;
+
+    private int nonceSize;
+
+    private int tagBits;
+
+    private int keyBits;
+
+    private NonceTracker nonceTracker;
+
+    /**
+     * Initialises the instance with the default nonce size of {@value #NONCE_SIZE} bytes.
+     */
+    public AuthenticatedCrypto() {
+        this(NONCE_SIZE);
+    }
+
+    /**
+     * Initialises the instance with a specific nonce size. You should only need this if
+     * you're interoperating with a system that doesn't use {@value #NONCE_SIZE}-byte nonces.
+     *
+     * @param nonceSize The nonce size, in bytes. This must be between 1 and 255.
+     */
+    public AuthenticatedCrypto(int nonceSize) {
+        this(nonceSize, TAG_BITS);
+    }
+
+    /**
+     * Initialises the instance with a specific nonce size and authentication tag length. You should only need this
+     * if you're interoperating with a protocol that truncates the GCM tag.
+     * <p>
+     * NB a shorter tag weakens authentication: an attacker's chance of a forgery being accepted is roughly
+     * 2<sup>-t</sup> per attempt for a t-bit tag, and NIST SP 800-38D limits how much data should be processed with
+     * one key when tags are short. The recorded tag length must match the tag length of the instance used for
+     * decryption, so a ciphertext can't be downgraded to a shorter tag. Tags shorter than {@value #MIN_TAG_BITS}
+     * bits (such as 64-bit tags) aren't supported by the standard Java provider, so aren't allowed here.
+     * <p>
+     * The tag length is recorded in the header by {@link #encrypt(byte[], SecretKey)} and the methods built on it.
+     * The timestamped and compact formats ({@link #encryptWithTimestamp(byte[], SecretKey)} and
+     * {@link #encryptCompact(byte[], SecretKey)}) have no room to record it, so they always use a
+     * {@value #TAG_BITS}-bit tag and an instance with a shorter tag can't use them.
+     *
+     * @param nonceSize The nonce size, in bytes. This must be between 1 and 255.
+     * @param tagBits   The authentication tag length, in bits. This must be a multiple of 8 between
+     *                  {@value #MIN_TAG_BITS} and {@value #TAG_BITS}.
+     */
+    public AuthenticatedCrypto(int nonceSize, int tagBits) {
+        this(new CryptoOptions(CryptoOptions.ANY_KEY_SIZE, nonceSize, tagBits));
+    }
+
+    /**
+     * Initialises the instance from a single set of options, which are validated when they're created.
+     * <p>
+     * Unlike the other constructors, this can also fix the key size: any key you pass in of a different size is
+     * rejected with an {@link IllegalArgumentException}, rather than silently being accepted. Keys derived from a
+     * password by {@link #encryptWithPassword(byte[], String)} aren't checked: they're always
+     * {@link Keys#SYMMETRIC_KEY_SIZE} bits.
+     *
+     * @param options The key size, nonce size and tag length to use.
+     */
+    public AuthenticatedCrypto(CryptoOptions options) {
+        this.nonceSize = options.getNonceSize();
+        this.tagBits = options.getTagBits();
+        this.keyBits = options.getKeyBits();
+    }
+
+    /**
+     * @return The nonce size, in bytes, used by this instance.
+     */
+    public int getNonceSize() {
+        return nonceSize;
+    }
+
+    /**
+     * @return The authentication tag length, in bits, used by this instance.
+     */
+    public int getTagBits() {
+        return tagBits;
+    }
+
+    /**
+     * Calculates the length of the output of {@link #encrypt(byte[], SecretKey)} for a given input length.
+     * <p>
+     * GCM doesn't pad, so the overhead is constant: the header, the nonce and the authentication tag.
+     * This is useful for sizing fixed-width storage, such as a database column. Note that
+     * {@link #encrypt(String, SecretKey)} base-64 encodes this, giving
+     * <code>4 * ceil(n / 3)</code> characters, where <code>n</code> is the value returned here
+     * and the input length is the number of UTF-8 bytes in the String.
+     *
+     * @param plaintextLength The length of the data to be encrypted, in bytes.
+     * @return The length of the encrypted data, in bytes.
+     */
+    public int ciphertextLength(int plaintextLength) {
+        if (plaintextLength < 0) {
+            throw new IllegalArgumentException("Plaintext length cannot be negative: " + plaintextLength);
+        }
+        return header().length + nonceSize + plaintextLength + tagBits / 8;
+    }
+
+    /**
+     * Sets a {@link NonceTracker} to check for nonce reuse when encrypting.
+     * <p>
+     * This is off by default and is intended for testing and staging environments,
+     * particularly if you're using {@link #encrypt(byte[], SecretKey, byte[])}.
+     *
+     * @param nonceTracker The tracker, or null to stop tracking.
+     */
+    public void setNonceTracker(NonceTracker nonceTracker) {
+        this.nonceTracker = nonceTracker;
+    }
+
+    /**
+     * This method encrypts the given String, returning a base-64 encoded String.
+     *
+     * @param string The input String.
+     * @param key    The key to be used to encrypt the String.
+     * @return The encrypted String, base-64 encoded, or null if the given
+     * String is null. An empty string can be encrypted, but a null one
+     * cannot.
+     * @see #decrypt(String, SecretKey)
+     */
+    public String encrypt(String string, SecretKey key) {
+
+        // Basic null check.
+        // An empty string can be encrypted:
+        if (string == null) {
+            return null;
+        }
+
+        byte[] result = encrypt(ByteArray.fromString(string), key);
+        return ByteArray.toBase64(result);
+    }
+
+    /**
+     * This method encrypts the given byte array.
+     *
+     * @param data The cleartext data.
+     * @param key  The key to be used to encrypt the data.
+     * @return The encrypted data, including the header and nonce, or null if the given byte array is null.
+     * @see #decrypt(byte[], SecretKey)
+     */
+    public byte[] encrypt(byte[] data, SecretKey key) {
+
+        // Basic null check.
+        // An empty array can be encrypted:
+        if (data == null) {
+            return null;
+        }
+
+        // Generate a nonce and encrypt the data:
+        return encrypt(data, key, Generate.byteArray(nonceSize));
+    }
+
+    /**
+     * This method encrypts the given byte array using the given nonce.
+     * <p>
+     * You almost certainly want {@link #encrypt(byte[], SecretKey)} instead, which generates
+     * a random nonce. Only use this if you have a scheme that guarantees a nonce is never used
+     * twice with the same key and consider setting a {@link NonceTracker} to catch mistakes.
+     *
+     * @param data  The cleartext data.
+     * @param key   The key to be used to encrypt the data.
+     * @param nonce The nonce. This must be the nonce size of this instance.
+     * @return The encrypted data, including the header and nonce, or null if the given byte array is null.
+     * @throws NonceReuseException If a {@link NonceTracker} is set and the nonce has already been used with this key.
+     * @see #decrypt(byte[], SecretKey)
+     */
+    public byte[] encrypt(byte[] data, SecretKey key, byte[] nonce) {
+
+        if (data == null) {
+            return null;
+        }
+
+        checkKey(key);
+        return encrypt(header(), data, key, nonce, null);
+    }
+
+    /**
+     * This method encrypts the given byte array and authenticates, but doesn't encrypt or include,
+     * the given associated data.
+     * <p>
+     * This is useful for binding ciphertext to its context (for example, the record it's stored in),
+     * so that it can't be moved to a different context without detection. The same associated data
+     * must be passed to {@link #decryptWithAssociatedData(byte[], SecretKey, byte[])}.
+     *
+     * @param data           The cleartext data.
+     * @param key            The key to be used to encrypt the data.
+     * @param associatedData Data to be authenticated, but not encrypted.
+     * @return The encrypted data, including the header and nonce, or null if the given byte array is null.
+     * @see #decryptWithAssociatedData(byte[], SecretKey, byte[])
+     */
+    public byte[] encryptWithAssociatedData(byte[] data, SecretKey key, byte[] associatedData) {
+
+        if (data == null) {
+            return null;
+        }
+
+        checkKey(key);
+        return encrypt(header(), data, key, Generate.byteArray(nonceSize), associatedData);
+    }
+
+    /**
+     * This method encrypts the given byte array and records the current time in the header.
+     * <p>
+     * This is useful for data-retention policies: you can find out when data were encrypted with
+     * {@link #encryptedAt(byte[])}, without needing the key. The timestamp is stored in the clear,
+     * but is authenticated, so altering it causes decryption to fail.
+     * The output can be decrypted with {@link #decrypt(byte[], SecretKey)}.
+     *
+     * @param data The cleartext data.
+     * @param key  The key to be used to encrypt the data.
+     * @return The encrypted data, including the header, timestamp and nonce, or null if the given byte array is null.
+     * @throws IllegalStateException If this instance uses a tag length other than {@value #TAG_BITS} bits,
+     *                               which this format can't record.
+     * @see #encryptedAt(byte[])
+     */
+    public byte[] encryptWithTimestamp(byte[] data, SecretKey key) {
+
+        if (data == null) {
+            return null;
+        }
+        checkDefaultTagBits("timestamped");
+
+        byte[] header = ByteBuffer.allocate(HEADER_SIZE_TIMESTAMPED)
+                .put((byte) FORMAT_VERSION_TIMESTAMPED)
+                .putLong(System.currentTimeMillis())
+                .put((byte) CIPHER_ID)
+                .put((byte) nonceSize)
+                .array();
+        checkKey(key);
+        return encrypt(header, data, key, Generate.byteArray(nonceSize), null);
+    }
+
+    /**
+     * Reads the creation time of data encrypted by {@link #encryptWithTimestamp(byte[], SecretKey)}.
+     * <p>
+     * This doesn't need the key, so the timestamp hasn't been authenticated when it's returned.
+     * If you need to be sure it's genuine, decrypt the data as well.
+     *
+     * @param encrypted The encrypted data.
+     * @return The time the data were encrypted, or null if the data are null or don't include a timestamp.
+     * @throws MalformedDataException If the data are too short to contain a timestamp.
+     */
+    public static Date encryptedAt(byte[] encrypted) {
+
+        if (encrypted == null || encrypted.length < 1 || (encrypted[0] & 0xff) != FORMAT_VERSION_TIMESTAMPED) {
+            return null;
+        }
+        if (encrypted.length < HEADER_SIZE_TIMESTAMPED) {
+            throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + encrypted.length
+                    + ") is shorter than a header with a timestamp.");
+        }
+        return new Date(ByteBuffer.wrap(encrypted, 1, TIMESTAMP_BYTES).getLong());
+    }
+
+    /**
+     * @return The header for the current format version: the version, cipher and nonce size.
+     * If the tag length isn't the default, the tag length (in bytes) follows the version.
+     */
+    private byte[] header() {
+        if (tagBits != TAG_BITS) {
+            return new byte[]{(byte) FORMAT_VERSION_TAG_LENGTH, (byte) (tagBits / 8), (byte) CIPHER_ID, (byte) nonceSize};
+        }
+        return new byte[]{(byte) FORMAT_VERSION, (byte) CIPHER_ID, (byte) nonceSize};
+    }
+
+    /**
+     * This method encrypts the given byte array using the given header, nonce and associated data.
+     *
+     * @param header         The header, which must end with the cipher identifier and nonce size.
+     * @param data           The cleartext data.
+     * @param key            The key to be used to encrypt the data.
+     * @param nonce          The nonce. This must be the nonce size of this instance.
+     * @param associatedData Data to be authenticated after the header, or null if there are none.
+     * @return The encrypted data, including the header and nonce, or null if the given byte array is null.
+     */
+    private byte[] encrypt(byte[] header, byte[] data, SecretKey key, byte[] nonce, byte[] associatedData) {
+
+        if (data == null) {
+            return null;
+        }
+        if (nonce == null || nonce.length != nonceSize) {
+            throw new IllegalArgumentException("Nonce must be " + nonceSize + " bytes, but got "
+                    + (nonce == null ? null : nonce.length));
+        }
+        return seal(header, nonce, data, key, associatedData);
+    }
+
+    /**
+     * Encrypts the given data, authenticating the header and any associated data.
+     *
+     * @param header         The header.
+     * @param nonce          The nonce.
+     * @param data           The cleartext data.
+     * @param key            The key to be used to encrypt the data.
+     * @param associatedData Data to be authenticated after the header, or null if there are none.
+     * @return The header, nonce and ciphertext.
+     */
+    private byte[] seal(byte[] header, byte[] nonce, byte[] data, SecretKey key, byte[] associatedData) {
+
+        if (nonceTracker != null) {
+            nonceTracker.record(key, nonce);
+        }
+
+        // The header is authenticated as associated data, so it can't be altered undetected:
+        Cipher cipher = getCipher(Cipher.ENCRYPT_MODE, key, nonce);
+        cipher.updateAAD(header);
+        if (associatedData != null) {
+            cipher.updateAAD(associatedData);
+        }
+        byte[] ciphertext;
+        try {
+            ciphertext = cipher.doFinal(data);
+        } catch (IllegalBlockSizeException e) {
+            throw new IllegalStateException("Block-size exception when completing encryption.", e);
+        } catch (BadPaddingException e) {
+            throw new IllegalStateException("Padding error detected when completing encryption.", e);
+        }
+
+        // Prepend the header and nonce:
+        return ByteArray.concat(header, nonce, ciphertext);
+    }
+
+    /**
+     * This method encrypts the given byte array with a compact header, for tiny values such as individual database fields.
+     * <p>
+     * The default output has three header bytes and a {@value #NONCE_SIZE}-byte nonce, which is a lot
+     * relative to a small value. This uses a single header byte ({@value #COMPACT_HEADER}) and a
+     * {@value #COMPACT_NONCE_SIZE}-byte random nonce instead, saving six bytes per value.
+     * <p>
+     * NB a shorter nonce makes a repeated nonce, which is catastrophic for GCM, more likely. This is acceptable for
+     * low-volume-per-key field encryption: after a million (2<sup>20</sup>) values with the same key, the chance of a
+     * repeat is about 1 in 2<sup>25</sup>. If you'll encrypt more than that with one key, use {@link #encrypt(byte[], SecretKey)}.
+     *
+     * @param data The cleartext data.
+     * @param key  The key to be used to encrypt the data.
+     * @return The encrypted data, including the header and nonce, or null if the given byte array is null.
+     * @throws IllegalStateException If this instance uses a tag length other than {@value #TAG_BITS} bits,
+     *                               which this format can't record.
+     * @see #decryptCompact(byte[], SecretKey)
+     */
+    public byte[] encryptCompact(byte[] data, SecretKey key) {
+
+        if (data == null) {
+            return null;
+        }
+        checkDefaultTagBits("compact");
+
+        checkKey(key);
+        byte[] header = new byte[]{(byte) COMPACT_HEADER};
+        return seal(header, Generate.byteArray(COMPACT_NONCE_SIZE), data, key, null);
+    }
+
+    /**
+     * This method decrypts bytes encrypted by {@link #encryptCompact(byte[], SecretKey)}.
+     *
+     * @param encrypted The encrypted data.
+     * @param key       The key to be used for decryption.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException      If the data are too short, or this instance doesn't use a {@value #TAG_BITS}-bit tag.
+     * @throws UnsupportedVersionException If the data are not in the compact format.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If the key is wrong or the data have been altered.
+     * @see #encryptCompact(byte[], SecretKey)
+     */
+    public byte[] decryptCompact(byte[] encrypted, SecretKey key) {
+
+        if (encrypted == null) {
+            return null;
+        }
+
+        // Validate the header:
+        checkTagBits(TAG_BITS);
+        if (encrypted.length < 1 + COMPACT_NONCE_SIZE + tagBits / 8) {
+            throw new MalformedDataException("Are you sure this is compact encrypted data? Byte length (" + encrypted.length
+                    + ") is shorter than a header, nonce and authentication tag.");
+        }
+        int version = (encrypted[0] & 0xff) >> 4;
+        if (version != FORMAT_VERSION_COMPACT) {
+            throw new UnsupportedVersionException("Unsupported format version: " + version
+                    + ". Expected " + FORMAT_VERSION_COMPACT + ".");
+        }
+        int cipherId = encrypted[0] & 0x0f;
+        if (cipherId != CIPHER_ID) {
+            throw new UnsupportedCipherException("Unsupported cipher identifier: " + cipherId
+                    + ". Expected " + CIPHER_ID + " (" + CIPHER_NAME + ").");
+        }
+
+        checkKey(key);
+
+        // Separate the header and nonce from the data:
+        byte[][] split = ByteArray.splitAt(encrypted, 1);
+        byte[] header = split[0];
+        split = ByteArray.splitAt(split[1], COMPACT_NONCE_SIZE);
+        return open(header, split[0], split[1], key, null);
+    }
+
+    /**
+     * This method decrypts the given String and returns the plain text.
+     *
+     * @param encrypted The encrypted String, base-64 encoded, as returned by
+     *                  {@link #encrypt(String, SecretKey)}.
+     * @param key       The key to be used for decryption.
+     * @return The decrypted String, or null if the encrypted String is null.
+     * @throws MalformedDataException      If the data are not in the expected format or the nonce size does not match.
+     * @throws UnsupportedVersionException If the data specify a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If the key is wrong or the data have been altered.
+     * @see #encrypt(String, SecretKey)
+     */
+    public String decrypt(String encrypted, SecretKey key) {
+
+        // Basic null/empty check.
+        // An empty string can be encrypted, but not decrypted:
+        if (StringUtils.isEmpty(encrypted)) {
+            return encrypted;
+        }
+
+        byte[] result = decrypt(ByteArray.fromBase64(encrypted), key);
+        return ByteArray.toString(result);
+    }
+
+    /**
+     * This method decrypts the given bytes and returns the plain text.
+     *
+     * @param encrypted The encrypted data, as returned by {@link #encrypt(byte[], SecretKey)}.
+     * @param key       The key to be used for decryption.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException      If the data are not in the expected format or the nonce size does not match.
+     * @throws UnsupportedVersionException If the data specify a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If the key is wrong or the data have been altered.
+     * @see #encrypt(byte[], SecretKey)
+     */
+    public byte[] decrypt(byte[] encrypted, SecretKey key) {
+        if (encrypted != null) {
+            checkKey(key);
+        }
+        return decrypt(encrypted, key, (byte[]) null);
+    }
+
+    /**
+     * Decrypts the given bytes and lends the plain text to the given consumer for the duration of the call.
+     * <p>
+     * Rather than returning plain text that you have to remember to wipe, this wipes it with
+     * {@link ByteArray#zeroize(byte[]...)} as soon as the consumer returns (or throws), which minimises
+     * the time sensitive data spend in memory.
+     *
+     * @param encrypted The encrypted data, as returned by {@link #encrypt(byte[], SecretKey)}.
+     * @param key       The key to be used for decryption.
+     * @param consumer  Receives the decrypted data. It mustn't keep a reference to them.
+     *                  If the encrypted data are null, the consumer isn't called.
+     * @throws MalformedDataException      If the data are not in the expected format or the nonce size does not match.
+     * @throws UnsupportedVersionException If the data specify a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If the key is wrong or the data have been altered.
+     */
+    public void decryptScoped(byte[] encrypted, SecretKey key, PlaintextConsumer consumer) {
+        byte[] plaintext = decrypt(encrypted, key);
+        if (plaintext == null) {
+            return;
+        }
+        try {
+            consumer.accept(plaintext);
+        } finally {
+            ByteArray.zeroize(plaintext);
+        }
+    }
+
+    /**
+     * This method decrypts bytes encrypted by {@link #encryptWithAssociatedData(byte[], SecretKey, byte[])}.
+     *
+     * @param encrypted      The encrypted data.
+     * @param key            The key to be used for decryption.
+     * @param associatedData The associated data that were passed when the data were encrypted.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException      If the data are not in the expected format or the nonce size does not match.
+     * @throws UnsupportedVersionException If the data specify a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If the key or associated data are wrong, or the data have been altered.
+     * @see #encryptWithAssociatedData(byte[], SecretKey, byte[])
+     */
+    public byte[] decryptWithAssociatedData(byte[] encrypted, SecretKey key, byte[] associatedData) {
+        if (encrypted != null) {
+            checkKey(key);
+        }
+        return decrypt(encrypted, key, associatedData);
+    }
+
+    /**
+     * This method decrypts the given bytes according to their format version.
+     *
+     * @param encrypted      The encrypted data.
+     * @param key            The key to be used for decryption.
+     * @param associatedData Data to be authenticated after the header, or null if there are none.
+     * @return The decrypted data, or null if the encrypted data are null.
+     */
+    private byte[] decrypt(byte[] encrypted, SecretKey key, byte[] associatedData) {
+
+        if (encrypted == null) {
+            return null;
+        }
+
+        // Dispatch according to the format version:
+        if (encrypted.length < 1) {
+            throw new MalformedDataException("Are you sure this is encrypted data? There's no version byte.");
+        }
+        int version = encrypted[0] & 0xff;
+        switch (version) {
+            case FORMAT_VERSION:
+                checkTagBits(TAG_BITS);
+                return decrypt(encrypted, key, HEADER_SIZE, associatedData);
+            case FORMAT_VERSION_1:
+                checkTagBits(TAG_BITS);
+                return decrypt(encrypted, key, HEADER_SIZE_V1, associatedData);
+            case FORMAT_VERSION_TIMESTAMPED:
+                checkTagBits(TAG_BITS);
+                return decrypt(encrypted, key, HEADER_SIZE_TIMESTAMPED, associatedData);
+            case FORMAT_VERSION_TAG_LENGTH:
+                if (encrypted.length < HEADER_SIZE_TAG_LENGTH) {
+                    throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + encrypted.length
+                            + ") is shorter than a header.");
+                }
+                checkTagBits((encrypted[1] & 0xff) * 8);
+                return decrypt(encrypted, key, HEADER_SIZE_TAG_LENGTH, associatedData);
+            default:
+                throw new UnsupportedVersionException("Unsupported format version: " + version
+                        + ". Expected " + FORMAT_VERSION + ", " + FORMAT_VERSION_TIMESTAMPED
+                        + ", " + FORMAT_VERSION_TAG_LENGTH + " or " + FORMAT_VERSION_1 + ".");
+        }
+    }
+
+    /**
+     * Checks that this instance uses the default tag length, for formats that don't record it.
+     *
+     * @param format The name of the format, for the error message.
+     * @throws IllegalStateException If the tag length isn't {@value #TAG_BITS} bits.
+     */
+    private void checkDefaultTagBits(String format) {
+        if (tagBits != TAG_BITS) {
+            throw new IllegalStateException("The " + format + " format always uses a " + TAG_BITS
+                    + "-bit tag, but this instance uses " + tagBits + " bits.");
+        }
+    }
+
+    /**
+     * Checks that a key passed in by the caller is the size fixed by the {@link CryptoOptions} of this instance, if any.
+     *
+     * @param key The key.
+     * @throws IllegalArgumentException If the key is the wrong size.
+     */
+    private void checkKey(SecretKey key) {
+        if (keyBits == CryptoOptions.ANY_KEY_SIZE || key == null) {
+            return;
+        }
+        byte[] keyBytes = key.getEncoded();
+        int length = keyBytes == null ? 0 : keyBytes.length * 8;
+        ByteArray.zeroize(keyBytes);
+        if (length != keyBits) {
+            throw new IllegalArgumentException("Key size mismatch. Expected a " + keyBits + "-bit key but got " + length + " bits.");
+        }
+    }
+
+    /**
+     * Checks that the tag length of the encrypted data matches this instance, so data can't be downgraded to a shorter tag.
+     *
+     * @param dataTagBits The tag length of the encrypted data, in bits.
+     * @throws MalformedDataException If the tag length doesn't match.
+     */
+    private void checkTagBits(int dataTagBits) {
+        if (dataTagBits != tagBits) {
+            throw new MalformedDataException("Tag length mismatch. Expected " + tagBits
+                    + " bits but the encrypted data specify " + dataTagBits + " bits.");
+        }
+    }
+
+    /**
+     * This method decrypts the given bytes, which have a header ending with the cipher identifier and nonce size.
+     *
+     * @param encrypted      The encrypted data.
+     * @param key            The key to be used for decryption.
+     * @param headerSize     The size of the header for the format version of the data.
+     * @param associatedData Data to be authenticated after the header, or null if there are none.
+     * @return The decrypted data.
+     */
+    private byte[] decrypt(byte[] encrypted, SecretKey key, int headerSize, byte[] associatedData) {
+
+        // Validate the header:
+        if (encrypted.length < headerSize) {
+            throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + encrypted.length
+                    + ") is shorter than a header.");
+        }
+        int cipherId = encrypted[headerSize - 2] & 0xff;
+        if (cipherId != CIPHER_ID) {
+            throw new UnsupportedCipherException("Unsupported cipher identifier: " + cipherId
+                    + ". Expected " + CIPHER_ID + " (" + CIPHER_NAME + ").");
+        }
+        int headerNonceSize = encrypted[headerSize - 1] & 0xff;
+        if (headerNonceSize != nonceSize) {
+            throw new MalformedDataException("Nonce size mismatch. Expected " + nonceSize
+                    + " bytes but the encrypted data specify " + headerNonceSize + " bytes.");
+        }
+        if (encrypted.length < headerSize + nonceSize + tagBits / 8) {
+            throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + encrypted.length
+                    + ") is shorter than a header, nonce and authentication tag.");
+        }
+
+        // Separate the header and nonce from the data:
+        byte[][] split = ByteArray.splitAt(encrypted, headerSize);
+        byte[] header = split[0];
+        split = ByteArray.splitAt(split[1], nonceSize);
+        byte[] nonce = split[0];
+        byte[] data = split[1];
+
+        return open(header, nonce, data, key, associatedData);
+    }
+
+    /**
+     * Decrypts the given data, authenticating the header and any associated data.
+     *
+     * @param header         The header.
+     * @param nonce          The nonce.
+     * @param data           The ciphertext, including the authentication tag.
+     * @param key            The key to be used for decryption.
+     * @param associatedData Data to be authenticated after the header, or null if there are none.
+     * @return The decrypted data.
+     */
+    private byte[] open(byte[] header, byte[] nonce, byte[] data, SecretKey key, byte[] associatedData) {
+
+        // Decrypt and authenticate the data and header:
+        Cipher cipher = getCipher(Cipher.DECRYPT_MODE, key, nonce);
+        cipher.updateAAD(header);
+        if (associatedData != null) {
+            cipher.updateAAD(associatedData);
+        }
+        try {
+            return cipher.doFinal(data);
+        } catch (IllegalBlockSizeException e) {
+            throw new IllegalStateException("Block-size exception when completing decryption.", e);
+        } catch (BadPaddingException e) {
+            // In GCM mode this means the authentication tag didn't match:
+            throw new AuthenticationException("Unable to authenticate the encrypted data. " +
+                    "Either the key is wrong or the data have been altered.", e);
+        }
+    }
+
+    /**
+     * This method decrypts the given bytes with whichever of the given keys they were encrypted with.
+     * <p>
+     * This is useful during key rotation, when data may have been encrypted with either the old
+     * or the new key and there's no key identifier to say which. Every key is tried, even after
+     * one succeeds, so the time taken doesn't reveal which key matched.
+     *
+     * @param encrypted The encrypted data, as returned by {@link #encrypt(byte[], SecretKey)}.
+     * @param keys      The candidate keys.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException      If the data are not in the expected format or the nonce size does not match.
+     * @throws UnsupportedVersionException If the data specify a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the data specify a cipher other than {@value #CIPHER_NAME}.
+     * @throws AuthenticationException     If none of the keys authenticate the data.
+     */
+    public byte[] decryptAny(byte[] encrypted, SecretKey... keys) {
+
+        if (encrypted == null) {
+            return null;
+        }
+
+        byte[] result = null;
+        AuthenticationException failure = null;
+        for (SecretKey key : keys) {
+            try {
+                byte[] decrypted = decrypt(encrypted, key);
+                if (result == null) {
+                    result = decrypted;
+                }
+            } catch (AuthenticationException e) {
+                failure = e;
+            }
+        }
+
+        if (result == null) {
+            throw new AuthenticationException("Unable to authenticate the encrypted data with any of the "
+                    + keys.length + " keys.", failure);
+        }
+        return result;
+    }
+
+    /**
+     * This method encrypts a record, authenticating the given header without encrypting it.
+     * <p>
+     * This is useful for record-oriented storage, where metadata such as a tenant, table and id
+     * need to stay readable (for example, for indexing) but mustn't be altered, or the ciphertext
+     * moved to a different record. The header is serialised canonically (sorted by name, each name
+     * and value length-prefixed, as for {@link Frame}) and used as associated data. The output is a
+     * {@link Frame} of the serialised header and the ciphertext.
+     *
+     * @param data   The cleartext data.
+     * @param key    The key to be used to encrypt the data.
+     * @param header The header. Names and values can't be null.
+     * @return The encrypted record, or null if the given byte array is null.
+     * @see #decryptRecord(byte[], SecretKey)
+     */
+    public byte[] encryptRecord(byte[] data, SecretKey key, Map<String, String> header) {
+
+        if (data == null) {
+            return null;
+        }
+
+        Frame serialised = new Frame();
+        for (Map.Entry<String, String> entry : new TreeMap<>(header).entrySet()) {
+            if (entry.getValue() == null) {
+                throw new IllegalArgumentException("Header values can't be null: " + entry.getKey());
+            }
+            serialised.addField(ByteArray.fromString(entry.getKey())).addField(ByteArray.fromString(entry.getValue()));
+        }
+        byte[] headerBytes = serialised.toByteArray();
+
+        return new Frame()
+                .addField(headerBytes)
+                .addField(encryptWithAssociatedData(data, key, headerBytes))
+                .toByteArray();
+    }
+
+    /**
+     * This method decrypts a record encrypted by {@link #encryptRecord(byte[], SecretKey, Map)}.
+     *
+     * @param record The encrypted record.
+     * @param key    The key to be used for decryption.
+     * @return The authenticated header and decrypted data, or null if the record is null.
+     * @throws MalformedDataException  If the record is not in the expected format.
+     * @throws AuthenticationException If the key is wrong or the header or data have been altered.
+     * @see #encryptRecord(byte[], SecretKey, Map)
+     */
+    public DecryptedRecord decryptRecord(byte[] record, SecretKey key) {
+
+        if (record == null) {
+            return null;
+        }
+
+        byte[][] fields;
+        try {
+            fields = ByteArray.unframe(record);
+        } catch (IllegalArgumentException e) {
+            throw new MalformedDataException("Are you sure this is an encrypted record? " + e.getMessage(), e);
+        }
+        if (fields.length != 2) {
+            throw new MalformedDataException("Are you sure this is an encrypted record? Expected 2 fields but got "
+                    + fields.length + ".");
+        }
+
+        // Authenticate before parsing the header:
+        byte[] data = decryptWithAssociatedData(fields[1], key, fields[0]);
+        byte[][] entries = ByteArray.unframe(fields[0]);
+        Map<String, String> header = new TreeMap<>();
+        for (int i = 0; i + 1 < entries.length; i += 2) {
+            header.put(ByteArray.toString(entries[i]), ByteArray.toString(entries[i + 1]));
+        }
+        return new DecryptedRecord(header, data);
+    }
+
+    /**
+     * This method encrypts the given String, bound to a type tag, such as the name of the field it's stored in.
+     * <p>
+     * The tag is authenticated as associated data, so the encrypted value only decrypts with
+     * {@link #decryptTagged(String, SecretKey, String)} and the same tag. This prevents type confusion:
+     * for example, an encrypted value from an "ssn" field can't be pasted into an "email" field and
+     * decrypted there.
+     *
+     * @param string The input String.
+     * @param key    The key to be used to encrypt the String.
+     * @param tag    The type tag. This can't be null.
+     * @return The encrypted String, base-64 encoded, or null if the given String is null.
+     * @see #decryptTagged(String, SecretKey, String)
+     */
+    public String encryptTagged(String string, SecretKey key, String tag) {
+
+        if (string == null) {
+            return null;
+        }
+
+        byte[] result = encryptWithAssociatedData(ByteArray.fromString(string), key, tagData(tag));
+        return ByteArray.toBase64(result);
+    }
+
+    /**
+     * This method decrypts a String encrypted by {@link #encryptTagged(String, SecretKey, String)}.
+     *
+     * @param encrypted The encrypted String, base-64 encoded.
+     * @param key       The key to be used for decryption.
+     * @param tag       The type tag the String was encrypted with.
+     * @return The decrypted String, or null if the encrypted String is null.
+     * @throws AuthenticationException If the key or tag are wrong, or the data have been altered.
+     * @see #encryptTagged(String, SecretKey, String)
+     */
+    public String decryptTagged(String encrypted, SecretKey key, String tag) {
+
+        if (encrypted == null) {
+            return null;
+        }
+
+        byte[] result = decryptWithAssociatedData(ByteArray.fromBase64(encrypted), key, tagData(tag));
+        return ByteArray.toString(result);
+    }
+
+    /**
+     * @param tag A type tag.
+     * @return The associated data for the tag: a {@link Frame} of a fixed label and the tag,
+     * so it can't collide with associated data used for other purposes.
+     */
+    private static byte[] tagData(String tag) {
+        if (tag == null) {
+            throw new IllegalArgumentException("Please provide a type tag.");
+        }
+        return new Frame().addField(ByteArray.fromString(TAG_LABEL)).addField(ByteArray.fromString(tag)).toByteArray();
+    }
+
+    /**
+     * This method encrypts the given data with a password, using the default Argon2id parameters
+     * ({@value Password#ARGON2_MEMORY_KB}KB, {@value Password#ARGON2_ITERATIONS} iterations and
+     * parallelism of {@value Password#ARGON2_PARALLELISM}).
+     *
+     * @param data     The cleartext data.
+     * @param password The password.
+     * @return The encrypted data, or null if the given byte array is null.
+     * @see #encryptWithPassword(byte[], String, KdfParameters)
+     */
+    public byte[] encryptWithPassword(byte[] data, String password) {
+        return encryptWithPassword(data, password,
+                new KdfParameters(Password.ARGON2_MEMORY_KB, Password.ARGON2_ITERATIONS, Password.ARGON2_PARALLELISM));
+    }
+
+    /**
+     * This method encrypts the given data with a password, in the style of <code>openssl enc</code>.
+     * <p>
+     * A random salt is generated and a key is derived from the password with Argon2id (see
+     * {@link Keys#generateSecretKeyArgon2(String, String, KdfParameters)}). The salt and parameters are
+     * prepended to the output, so {@link #decryptWithPassword(byte[], String)} only needs the password:
+     * <ul>
+     * <li>The salt ({@value Generate#SALT_BYTES} bytes).</li>
+     * <li>The memory cost, iterations and parallelism ({@value #KDF_PARAMETER_BYTES} bytes each, big-endian).</li>
+     * <li>The output of {@link #encrypt(byte[], SecretKey)}.</li>
+     * </ul>
+     *
+     * @param data     The cleartext data.
+     * @param password The password.
+     * @param params   The Argon2id cost parameters.
+     * @return The encrypted data, or null if the given byte array is null.
+     * @see #decryptWithPassword(byte[], String)
+     */
+    public byte[] encryptWithPassword(byte[] data, String password, KdfParameters params) {
+
+        if (data == null) {
+            return null;
+        }
+
+        String salt = Generate.salt();
+        SecretKey key = Keys.generateSecretKeyArgon2(password, salt, params);
+        byte[] header = ByteBuffer.allocate(Generate.SALT_BYTES + 3 * KDF_PARAMETER_BYTES)
+                .put(ByteArray.fromBase64(salt))
+                .putInt(params.getMemoryKb())
+                .putInt(params.getIterations())
+                .putInt(params.getParallelism())
+                .array();
+        // The derived key isn't checked against the key size of this instance:
+        return ByteArray.concat(header, encrypt(header(), data, key, Generate.byteArray(nonceSize), null));
+    }
+
+    /**
+     * This method decrypts data encrypted by {@link #encryptWithPassword(byte[], String, KdfParameters)}.
+     * <p>
+     * The key derivation parameters are read from the data, so they're checked against
+     * {@link KdfParameters#maximum()} before a key is derived. This stops forged data from
+     * demanding enough memory or time to deny service.
+     *
+     * @param encrypted The encrypted data.
+     * @param password  The password.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException  If the data are not in the expected format or the parameters exceed the limits.
+     * @throws AuthenticationException If the password is wrong or the data have been altered.
+     * @see #encryptWithPassword(byte[], String, KdfParameters)
+     */
+    public byte[] decryptWithPassword(byte[] encrypted, String password) {
+        return decryptWithPassword(encrypted, password, KdfParameters.maximum());
+    }
+
+    /**
+     * This method decrypts data encrypted by {@link #encryptWithPassword(byte[], String, KdfParameters)},
+     * rejecting key derivation parameters above the given limits.
+     *
+     * @param encrypted The encrypted data.
+     * @param password  The password.
+     * @param limits    The largest memory cost, iterations and parallelism to accept.
+     * @return The decrypted data, or null if the encrypted data are null.
+     * @throws MalformedDataException  If the data are not in the expected format or the parameters exceed the limits.
+     * @throws AuthenticationException If the password is wrong or the data have been altered.
+     * @see #encryptWithPassword(byte[], String, KdfParameters)
+     */
+    public byte[] decryptWithPassword(byte[] encrypted, String password, KdfParameters limits) {
+
+        if (encrypted == null) {
+            return null;
+        }
+
+        int headerSize = Generate.SALT_BYTES + 3 * KDF_PARAMETER_BYTES;
+        if (encrypted.length < headerSize) {
+            throw new MalformedDataException("Are you sure this is password-encrypted data? Byte length ("
+                    + encrypted.length + ") is shorter than a salt and key derivation parameters.");
+        }
+
+        ByteBuffer buffer = ByteBuffer.wrap(encrypted);
+        byte[] salt = new byte[Generate.SALT_BYTES];
+        buffer.get(salt);
+        KdfParameters params;
+        try {
+            params = new KdfParameters(buffer.getInt(), buffer.getInt(), buffer.getInt());
+        } catch (IllegalArgumentException e) {
+            throw new MalformedDataException("Are you sure this is password-encrypted data? " +
+                    "The key derivation parameters are invalid.", e);
+        }
+
+        if (!params.isWithin(limits)) {
+            throw new MalformedDataException("The key derivation parameters (memory " + params.getMemoryKb()
+                    + "KB, iterations " + params.getIterations() + ", parallelism " + params.getParallelism()
+                    + ") exceed the limits (memory " + limits.getMemoryKb() + "KB, iterations " + limits.getIterations()
+                    + ", parallelism " + limits.getParallelism() + ").");
+        }
+
+        SecretKey key;
+        try {
+            key = Keys.generateSecretKeyArgon2(password, ByteArray.toBase64(salt), params);
+        } catch (IllegalStateException e) {
+            throw new MalformedDataException("Are you sure this is password-encrypted data? " +
+                    "Unable to derive a key with the key derivation parameters.", e);
+        }
+        return decrypt(ByteArray.splitAt(encrypted, headerSize)[1], key, (byte[]) null);
+    }
+
+    /**
+     * This method pads the given data to a multiple of the given block size and then encrypts it.
+     * <p>
+     * Encryption doesn't hide the length of a message, which can give away information
+     * (for example, "yes" and "no" are easy to tell apart). Padding means that all messages
+     * whose length falls within the same block produce ciphertext of the same length.
+     * <p>
+     * The data are prefixed with a {@value #PADDING_LENGTH_BYTES}-byte length
+     * and then padded with zeroes before encryption.
+     *
+     * @param data      The cleartext data.
+     * @param key       The key to be used to encrypt the data.
+     * @param blockSize The size, in bytes, to pad to a multiple of.
+     * @return The encrypted data, or null if the given byte array is null.
+     * @see #decryptPadded(byte[], SecretKey)
+     */
+    public byte[] encryptPadded(byte[] data, SecretKey key, int blockSize) {
+
+        if (data == null) {
+            return null;
+        }
+        if (blockSize < 1) {
+            throw new IllegalArgumentException("Block size must be at least 1, but got " + blockSize);
+        }
+
+        // Round the length-prefixed data up to a multiple of the block size:
+        int length = PADDING_LENGTH_BYTES + data.length;
+        int paddedLength = ((length + blockSize - 1) / blockSize) * blockSize;
+        byte[] padded = ByteBuffer.allocate(paddedLength).putInt(data.length).put(data).array();
+
+        return encrypt(padded, key);
+    }
+
+    /**
+     * This method decrypts data encrypted by {@link #encryptPadded(byte[], SecretKey, int)} and strips the padding.
+     *
+     * @param encrypted The encrypted data.
+     * @param key       The key to be used for decryption.
+     * @return The decrypted data, with padding removed, or null if the encrypted data are null.
+     * @throws DecryptionException If the data are not in the expected format, the key is wrong
+     *                             or the data have been altered.
+     * @see #encryptPadded(byte[], SecretKey, int)
+     */
+    public byte[] decryptPadded(byte[] encrypted, SecretKey key) {
+
+        byte[] padded = decrypt(encrypted, key);
+        if (padded == null) {
+            return null;
+        }
+
+        // Read the length prefix and strip the padding:
+        if (padded.length < PADDING_LENGTH_BYTES) {
+            throw new MalformedDataException("Are you sure this is padded data? Byte length (" + padded.length
+                    + ") is shorter than a length prefix.");
+        }
+        int length = ByteBuffer.wrap(padded).getInt();
+        if (length < 0 || length > padded.length - PADDING_LENGTH_BYTES) {
+            throw new MalformedDataException("Are you sure this is padded data? The length prefix (" + length
+                    + ") doesn't fit the padded data.");
+        }
+        return ArrayUtils.subarray(padded, PADDING_LENGTH_BYTES, PADDING_LENGTH_BYTES + length);
+    }
+
+    /**
+     * This method returns a {@link Cipher} instance for {@value #CIPHER_NAME},
+     * initialised with the given key and nonce.
+     *
+     * @param mode  One of {@link Cipher#ENCRYPT_MODE} or {@link Cipher#DECRYPT_MODE}).
+     * @param key   The {@link SecretKey} to be used with the {@link Cipher}.
+     * @param nonce The nonce to use.
+     * @return An initialised {@link Cipher} instance.
+     * @throws IllegalArgumentException If the given key is not a valid {@value #CIPHER_ALGORITHM} key.
+     */
+    private Cipher getCipher(int mode, SecretKey key, byte[] nonce) {
+
+        Cipher cipher;
+        try {
+            cipher = Cipher.getInstance(CIPHER_NAME);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return getCipher(mode, key, nonce);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + CIPHER_NAME, e);
+            }
+        } catch (NoSuchPaddingException e) {
+            throw new IllegalStateException("Padding method unavailable: " + CIPHER_NAME, e);
+        }
+
+        try {
+            cipher.init(mode, key, new GCMParameterSpec(tagBits, nonce));
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Invalid key for " + CIPHER_NAME +
+                    ". NB: If the root cause of this exception is an Illegal key size, " +
+                    "you can either use Keys.useStandardKeys() to limit key size to 128-bits, or install the " +
+                    "'Java Cryptography Extension (JCE) Unlimited Strength Jurisdiction Policy Files' " +
+                    "in your JVM to use 256-bit keys.", e);
+        } catch (InvalidAlgorithmParameterException e) {
+            throw new IllegalArgumentException("Invalid parameter passed to initialise cipher: " +
+                    "GCMParameterSpec containing a " + nonce.length + "-byte nonce.", e);
+        }
+        return cipher;
+    }
+}
@@ -0,0 +1,25 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown when encrypted data cannot be authenticated. This means either the key is wrong
+ * or the data have been altered.
+ *
+ * @author David Carboni
+ */
+public class AuthenticationException extends DecryptionException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public AuthenticationException(String message) {
+        super(message);
+    }
+
+    /**
+     * @param message A description of the error.
+     * @param cause   The underlying cause of the error.
+     */
+    public AuthenticationException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
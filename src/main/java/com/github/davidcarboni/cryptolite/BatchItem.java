@@ -0,0 +1,47 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.security.PublicKey;
+
+/**
+ * A signed message to be checked by {@link DigitalSignature#verifyBatch(java.util.List)}.
+ *
+ * @author David Carboni
+ */
+public class BatchItem {
+
+    private final PublicKey publicKey;
+    private final byte[] message;
+    private final String signature;
+
+    /**
+     * @param publicKey The key of the signer.
+     * @param message   The signed message.
+     * @param signature The base64-encoded signature, as returned by {@link DigitalSignature#sign(java.io.InputStream, java.security.PrivateKey)}.
+     */
+    public BatchItem(PublicKey publicKey, byte[] message, String signature) {
+        this.publicKey = publicKey;
+        this.message = message;
+        this.signature = signature;
+    }
+
+    /**
+     * @return The key of the signer.
+     */
+    public PublicKey getPublicKey() {
+        return publicKey;
+    }
+
+    /**
+     * @return The signed message.
+     */
+    public byte[] getMessage() {
+        return message;
+    }
+
+    /**
+     * @return The base64-encoded signature.
+     */
+    public String getSignature() {
+        return signature;
+    }
+}
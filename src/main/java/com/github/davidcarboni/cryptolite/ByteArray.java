@@ -1,10 +1,18 @@
 package com.github.davidcarboni.cryptolite;
 
 import org.apache.commons.codec.DecoderException;
+import org.apache.commons.codec.binary.Base32;
 import org.apache.commons.codec.binary.Base64;
 import org.apache.commons.codec.binary.Hex;
+import org.apache.commons.lang.StringUtils;
 
+import java.nio.ByteBuffer;
+import java.nio.charset.CharacterCodingException;
+import java.nio.charset.CodingErrorAction;
 import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.List;
 
 /**
  * The ByteArray class provides the ability to convert byte arrays to
@@ -43,6 +51,16 @@ import java.nio.charset.StandardCharsets;
  */
 public class ByteArray {
 
+    /**
+     * The Crockford base-32 alphabet.
+     */
+    static final String CROCKFORD = "0123456789ABCDEFGHJKMNPQRSTVWXYZ";
+
+    /**
+     * The standard base-64 alphabet.
+     */
+    private static final String BASE64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+
     /**
      * Renders the given byte array as a hex String.
      * <p>
@@ -97,6 +115,45 @@ public class ByteArray {
         return result;
     }
 
+    /**
+     * Appends the base-64 encoding of the given byte array to the given destination.
+     * <p>
+     * This produces the same characters as {@link #toBase64(byte[])}, but without creating an intermediate
+     * String, so services that encode many buffers can reuse one {@link StringBuilder} and avoid an allocation
+     * per call.
+     *
+     * @param destination The builder to append to.
+     * @param byteArray   The byte array to be encoded. If this is null, nothing is appended.
+     * @return The destination, to allow chaining.
+     */
+    public static StringBuilder appendBase64(StringBuilder destination, byte[] byteArray) {
+
+        if (byteArray == null) {
+            return destination;
+        }
+
+        destination.ensureCapacity(destination.length() + 4 * ((byteArray.length + 2) / 3));
+        int i = 0;
+        for (; i + 2 < byteArray.length; i += 3) {
+            int bits = (byteArray[i] & 0xff) << 16 | (byteArray[i + 1] & 0xff) << 8 | (byteArray[i + 2] & 0xff);
+            destination.append(BASE64.charAt(bits >>> 18))
+                    .append(BASE64.charAt((bits >>> 12) & 0x3f))
+                    .append(BASE64.charAt((bits >>> 6) & 0x3f))
+                    .append(BASE64.charAt(bits & 0x3f));
+        }
+
+        // Pad the final group, if there is one:
+        int remaining = byteArray.length - i;
+        if (remaining > 0) {
+            int bits = (byteArray[i] & 0xff) << 16 | (remaining == 2 ? (byteArray[i + 1] & 0xff) << 8 : 0);
+            destination.append(BASE64.charAt(bits >>> 18))
+                    .append(BASE64.charAt((bits >>> 12) & 0x3f))
+                    .append(remaining == 2 ? BASE64.charAt((bits >>> 6) & 0x3f) : '=')
+                    .append('=');
+        }
+        return destination;
+    }
+
     /**
      * Decodes the given base-64 string to a byte array.
      *
@@ -112,6 +169,137 @@ public class ByteArray {
         return result;
     }
 
+    /**
+     * Converts the given byte array to a URL-safe base-64 string, without padding.
+     *
+     * @param byteArray The byte array to be converted.
+     * @return The URL-safe base-64 encoded representation of the byte array.
+     */
+    public static String toBase64Url(byte[] byteArray) {
+
+        String result = null;
+        if (byteArray != null) {
+            result = Base64.encodeBase64URLSafeString(byteArray);
+        }
+        return result;
+    }
+
+    /**
+     * Decodes the given URL-safe base-64 string to a byte array.
+     *
+     * @param base64UrlString A URL-safe base-64 encoded string, with or without padding.
+     * @return The decoded byte array.
+     */
+    public static byte[] fromBase64Url(String base64UrlString) {
+
+        byte[] result = null;
+        if (base64UrlString != null) {
+            result = Base64.decodeBase64(base64UrlString);
+        }
+        return result;
+    }
+
+    /**
+     * Converts the given byte array to Crockford's base-32, without padding.
+     * <p>
+     * Crockford's base-32 leaves out I, L, O and U, so it avoids easily confused characters,
+     * which makes it a good choice for codes that people type in. If the number of bits isn't a
+     * multiple of 5, the last character is padded with zero bits.
+     *
+     * @param byteArray The byte array to be converted.
+     * @return The Crockford base-32 encoded representation of the byte array.
+     * @see <a href="https://www.crockford.com/base32.html">https://www.crockford.com/base32.html</a>
+     */
+    public static String toCrockford(byte[] byteArray) {
+
+        String result = null;
+        if (byteArray != null) {
+            StringBuilder builder = new StringBuilder();
+            int buffer = 0;
+            int bits = 0;
+            for (byte b : byteArray) {
+                buffer = (buffer << 8) | (b & 0xff);
+                bits += 8;
+                while (bits >= 5) {
+                    bits -= 5;
+                    builder.append(CROCKFORD.charAt((buffer >> bits) & 0x1f));
+                }
+            }
+            if (bits > 0) {
+                builder.append(CROCKFORD.charAt((buffer << (5 - bits)) & 0x1f));
+            }
+            result = builder.toString();
+        }
+        return result;
+    }
+
+    /**
+     * Decodes the given Crockford base-32 string to a byte array.
+     * <p>
+     * Decoding is forgiving of human entry: it's case-insensitive, hyphens are ignored and
+     * the commonly confused characters O, I and L are read as 0, 1 and 1.
+     * Any trailing bits that don't make up a whole byte are discarded.
+     *
+     * @param crockfordString A Crockford base-32 encoded string.
+     * @return The decoded byte array.
+     * @throws IllegalArgumentException If the string contains a character that isn't valid Crockford base-32.
+     */
+    public static byte[] fromCrockford(String crockfordString) {
+
+        byte[] result = null;
+        if (crockfordString != null) {
+            String normalised = crockfordString.replace("-", "").toUpperCase()
+                    .replace('O', '0').replace('I', '1').replace('L', '1');
+            ByteBuffer bytes = ByteBuffer.allocate(normalised.length() * 5 / 8);
+            int buffer = 0;
+            int bits = 0;
+            for (int i = 0; i < normalised.length(); i++) {
+                int value = CROCKFORD.indexOf(normalised.charAt(i));
+                if (value < 0) {
+                    throw new IllegalArgumentException("Invalid Crockford base-32 character: " + normalised.charAt(i));
+                }
+                buffer = (buffer << 5) | value;
+                bits += 5;
+                if (bits >= 8) {
+                    bits -= 8;
+                    bytes.put((byte) (buffer >> bits));
+                }
+            }
+            result = bytes.array();
+        }
+        return result;
+    }
+
+    /**
+     * Converts the given byte array to a base-32 string (RFC 4648), without padding.
+     *
+     * @param byteArray The byte array to be converted.
+     * @return The base-32 encoded representation of the byte array.
+     */
+    public static String toBase32(byte[] byteArray) {
+
+        String result = null;
+        if (byteArray != null) {
+            result = StringUtils.stripEnd(new Base32().encodeAsString(byteArray), "=");
+        }
+        return result;
+    }
+
+    /**
+     * Decodes the given base-32 string (RFC 4648) to a byte array.
+     *
+     * @param base32String A base-32 encoded string, with or without padding.
+     * @return The decoded byte array.
+     */
+    public static byte[] fromBase32(String base32String) {
+
+        byte[] result = null;
+        if (base32String != null) {
+            result = new Base32().decode(base32String.toUpperCase());
+        }
+        return result;
+    }
+
     /**
      * Converts the given byte array to a String.
      *
@@ -127,6 +315,34 @@ public class ByteArray {
         return result;
     }
 
+    /**
+     * Converts the given byte array to a String, checking that the bytes are valid UTF-8.
+     * <p>
+     * {@link #toString(byte[])} silently substitutes replacement characters for invalid bytes.
+     * If you're decrypting data that should be text, this method lets you detect a wrong key
+     * or corrupted data instead.
+     *
+     * @param byteArray The byte array to be converted to a String.
+     * @return The String represented by the given bytes, or null if the given byte array is null.
+     * @throws IllegalArgumentException If the bytes are not valid UTF-8.
+     */
+    public static String toStringValid(byte[] byteArray) {
+
+        String result = null;
+        if (byteArray != null) {
+            try {
+                result = StandardCharsets.UTF_8.newDecoder()
+                        .onMalformedInput(CodingErrorAction.REPORT)
+                        .onUnmappableCharacter(CodingErrorAction.REPORT)
+                        .decode(ByteBuffer.wrap(byteArray))
+                        .toString();
+            } catch (CharacterCodingException e) {
+                throw new IllegalArgumentException("Are you sure this is text? The bytes are not valid UTF-8.", e);
+            }
+        }
+        return result;
+    }
+
     /**
      * Converts the given String to a byte array.
      *
@@ -141,4 +357,172 @@ public class ByteArray {
         }
         return result;
     }
+
+    /**
+     * Concatenates the given byte arrays into a single array.
+     * <p>
+     * This is useful for framing, such as prepending a nonce or header to encrypted data.
+     *
+     * @param parts The byte arrays to be concatenated, in order. Null parts are skipped.
+     * @return A new byte array containing all the parts.
+     */
+    public static byte[] concat(byte[]... parts) {
+
+        int length = 0;
+        for (byte[] part : parts) {
+            if (part != null) {
+                length += part.length;
+            }
+        }
+
+        byte[] result = new byte[length];
+        int offset = 0;
+        for (byte[] part : parts) {
+            if (part != null) {
+                System.arraycopy(part, 0, result, offset, part.length);
+                offset += part.length;
+            }
+        }
+        return result;
+    }
+
+    /**
+     * Splits the given byte array in two at the given index.
+     * <p>
+     * This is the counterpart to {@link #concat(byte[]...)}, useful for separating a
+     * header (such as a nonce) from the data that follows it.
+     *
+     * @param byteArray The byte array to be split.
+     * @param index     The number of bytes to put in the first part.
+     * @return A two-element array containing the first <code>index</code> bytes, followed by the remaining bytes.
+     * @throws IllegalArgumentException If the byte array is null or shorter than the given index, or the index is negative.
+     */
+    public static byte[][] splitAt(byte[] byteArray, int index) {
+
+        if (byteArray == null || index < 0 || byteArray.length < index) {
+            throw new IllegalArgumentException("Unable to split at index " + index + ": byte length ("
+                    + (byteArray == null ? null : byteArray.length) + ") is too short.");
+        }
+
+        byte[] head = new byte[index];
+        byte[] tail = new byte[byteArray.length - index];
+        System.arraycopy(byteArray, 0, head, 0, head.length);
+        System.arraycopy(byteArray, index, tail, 0, tail.length);
+        return new byte[][]{head, tail};
+    }
+
+    /**
+     * XORs two byte arrays of equal length.
+     * <p>
+     * This is useful for constructions such as combining a key stream with data,
+     * or masking one-time-pad style secret shares.
+     *
+     * @param a The first byte array.
+     * @param b The second byte array.
+     * @return A new byte array containing <code>a[i] ^ b[i]</code> for each index.
+     * @throws IllegalArgumentException If either array is null or the lengths differ.
+     */
+    public static byte[] xor(byte[] a, byte[] b) {
+        byte[] result = new byte[checkLengths(a, b)];
+        xorInto(result, a, b);
+        return result;
+    }
+
+    /**
+     * XORs two byte arrays of equal length into a destination array, without allocating.
+     * <p>
+     * The destination may be the same array as either of the inputs.
+     *
+     * @param destination The array to receive the result. This must be the same length as the inputs.
+     * @param a           The first byte array.
+     * @param b           The second byte array.
+     * @throws IllegalArgumentException If any array is null or the lengths differ.
+     */
+    public static void xorInto(byte[] destination, byte[] a, byte[] b) {
+        int length = checkLengths(a, b);
+        if (destination == null || destination.length != length) {
+            throw new IllegalArgumentException("Destination byte length ("
+                    + (destination == null ? null : destination.length) + ") does not match input length (" + length + ").");
+        }
+        for (int i = 0; i < length; i++) {
+            destination[i] = (byte) (a[i] ^ b[i]);
+        }
+    }
+
+    /**
+     * @param a The first byte array.
+     * @param b The second byte array.
+     * @return The common length of the arrays.
+     * @throws IllegalArgumentException If either array is null or the lengths differ.
+     */
+    private static int checkLengths(byte[] a, byte[] b) {
+        if (a == null || b == null || a.length != b.length) {
+            throw new IllegalArgumentException("Unable to XOR byte arrays of different lengths ("
+                    + (a == null ? null : a.length) + " and " + (b == null ? null : b.length) + ").");
+        }
+        return a.length;
+    }
+
+    /**
+     * Recovers the fields from a byte array produced by {@link Frame#toByteArray()}.
+     *
+     * @param framed The framed fields.
+     * @return The fields, in the order they were added.
+     * @throws IllegalArgumentException If the input is null or truncated.
+     */
+    public static byte[][] unframe(byte[] framed) {
+
+        if (framed == null) {
+            throw new IllegalArgumentException("Unable to unframe null input.");
+        }
+
+        List<byte[]> fields = new ArrayList<>();
+        ByteBuffer buffer = ByteBuffer.wrap(framed);
+        while (buffer.hasRemaining()) {
+            if (buffer.remaining() < Frame.LENGTH_BYTES) {
+                throw new IllegalArgumentException("Are you sure this is framed data? " + buffer.remaining()
+                        + " bytes remaining is shorter than a length prefix.");
+            }
+            int length = buffer.getInt();
+            if (length < 0 || length > buffer.remaining()) {
+                throw new IllegalArgumentException("Are you sure this is framed data? Field length (" + length
+                        + ") is longer than the " + buffer.remaining() + " bytes remaining.");
+            }
+            byte[] field = new byte[length];
+            buffer.get(field);
+            fields.add(field);
+        }
+        return fields.toArray(new byte[fields.size()][]);
+    }
+
+    /**
+     * Overwrites the given byte arrays with zeroes.
+     * <p>
+     * This is useful for limiting how long secrets, such as key material, stay in memory.
+     * Note that the JVM may have made copies (for example during garbage collection), so this
+     * reduces, but can't eliminate, the exposure of a secret.
+     *
+     * @param byteArrays The byte arrays to be wiped. Null arrays are skipped.
+     */
+    public static void zeroize(byte[]... byteArrays) {
+        for (byte[] byteArray : byteArrays) {
+            if (byteArray != null) {
+                Arrays.fill(byteArray, (byte) 0);
+            }
+        }
+    }
+
+    /**
+     * Overwrites the given char array with zeroes.
+     * <p>
+     * Use this to wipe a password once you've finished with it. Strings can't be wiped,
+     * so it's best to keep passwords in char arrays and avoid ever creating a String.
+     *
+     * @param chars The char array to be wiped. If this is null, nothing happens.
+     */
+    public static void zeroize(char[] chars) {
+        if (chars != null) {
+            Arrays.fill(chars, '\0');
+        }
+    }
 }
@@ -5,12 +5,21 @@ import org.apache.commons.lang.StringUtils;
 
 import javax.crypto.*;
 import javax.crypto.spec.IvParameterSpec;
+import java.io.FilterOutputStream;
 import java.io.IOException;
 import java.io.InputStream;
 import java.io.OutputStream;
+import java.io.SequenceInputStream;
 import java.security.InvalidAlgorithmParameterException;
 import java.security.InvalidKeyException;
+import java.security.MessageDigest;
 import java.security.NoSuchAlgorithmException;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Collections;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
 
 /**
  * This class provides encryption and decryption of Strings and streams.
@@ -117,6 +126,16 @@ public class Crypto {
      */
     public static final String CIPHER_NAME = CIPHER_ALGORITHM + "/" + CIPHER_MODE + "/" + CIPHER_PADDING;
 
+    /**
+     * The minimum number of bytes between progress updates when streaming.
+     */
+    public static final int PROGRESS_INTERVAL = 64 * 1024;
+
+    /**
+     * The {@value #CIPHER_ALGORITHM} block size, which is also the initialisation vector size, in bytes.
+     */
+    private static final int CIPHER_BLOCK_BYTES = 16;
+
     /**
      * This method encrypts the given String, returning a base-64 encoded
      * String. Note that the base-64 String will be longer than the input String
@@ -207,6 +226,164 @@ public class Crypto {
         return ByteArray.toBase64(result);
     }
 
+    /**
+     * This method encrypts the given String using "convergent" encryption,
+     * meaning that identical plaintext always produces identical ciphertext.
+     * This is useful for deduplicating, content-addressed storage.
+     * <p>
+     * The key is generated from the plaintext itself by calling
+     * {@link Keys#convergentSecretKey(String)} and a fixed (all-zero)
+     * initialisation vector is used. Because each distinct plaintext gets its
+     * own key, the fixed IV does not lead to keystream reuse.
+     * <p>
+     * Be aware of the security tradeoffs before using this:
+     * <ul>
+     * <li>Anyone who can guess the plaintext can confirm it by encrypting
+     * their guess and comparing the result, so this is not suitable for
+     * low-entropy values such as names, dates or PINs.</li>
+     * <li>It reveals when two ciphertexts contain the same plaintext.</li>
+     * </ul>
+     * The output format matches {@link #encrypt(String, SecretKey)}, so the
+     * result can be decrypted with {@link #decrypt(String, SecretKey)}, using the
+     * key from {@link Keys#convergentSecretKey(String)}. You'll need to store
+     * that key in order to decrypt later.
+     *
+     * @param string The input String.
+     * @return The encrypted String, base-64 encoded, or null if the given
+     * String is null.
+     * @see Keys#convergentSecretKey(String)
+     */
+    public String encryptConvergent(String string) {
+
+        // Basic null check.
+        // An empty string can be encrypted:
+        if (string == null) {
+            return null;
+        }
+
+        Cipher cipher = getCipher();
+
+        // Generate the key from the content and use a fixed IV:
+        SecretKey key = Keys.convergentSecretKey(string);
+        byte[] iv = new byte[getIvSize(cipher)];
+        byte[] data = ByteArray.fromString(string);
+
+        // Encrypt the data:
+        byte[] result = encrypt(iv, data, key, cipher);
+
+        // Prepend the IV so the format matches encrypt(String, SecretKey)
+        result = ArrayUtils.addAll(iv, result);
+
+        // Return as a String:
+        return ByteArray.toBase64(result);
+    }
+
+    /**
+     * This method encrypts the given String deterministically, using a synthetic initialisation vector
+     * in the style of SIV mode.
+     * <p>
+     * The given key is split with {@link Keys#splitKeys(byte[])} into an encryption key and a MAC key.
+     * The initialisation vector is the {@value HashMac#ALGORITHM} of the plaintext, keyed with the MAC key and
+     * truncated to the block size, so the same plaintext and key always give the same ciphertext.
+     * This is useful for looking up encrypted values, for example to check whether an encrypted email address
+     * is already registered. Because the initialisation vector is a MAC, {@link #decryptSynthetic(String, SecretKey)}
+     * also detects tampering.
+     * <p>
+     * NB this leaks information: anyone who can see the encrypted values can tell which plaintexts are equal.
+     * Only use this if you need that property.
+     *
+     * @param string The input String.
+     * @param key    The master key. This is split into encryption and MAC keys, so don't use it for anything else.
+     * @return The encrypted String, base-64 encoded, or null if the given String is null.
+     * @see #decryptSynthetic(String, SecretKey)
+     */
+    public String encryptSynthetic(String string, SecretKey key) {
+
+        // Basic null check.
+        // An empty string can be encrypted:
+        if (string == null) {
+            return null;
+        }
+
+        Cipher cipher = getCipher();
+        SecretKey[] keys = Keys.splitKeys(key.getEncoded());
+        byte[] data = ByteArray.fromString(string);
+
+        // Derive the initialisation vector from the plaintext:
+        byte[] iv = syntheticIv(data, keys[1], cipher);
+
+        // Encrypt the data and prepend the IV:
+        byte[] result = ArrayUtils.addAll(iv, encrypt(iv, data, keys[0], cipher));
+
+        // Return as a String:
+        return ByteArray.toBase64(result);
+    }
+
+    /**
+     * Lists the ciphers this library uses for encryption, with their key sizes and default parameters.
+     * <p>
+     * The key size reflects the current setting of {@link Keys#useStrongKeys()} or {@link Keys#useStandardKeys()}.
+     *
+     * @return Information about each supported cipher.
+     */
+    public static List<AlgorithmInfo> supportedCiphers() {
+
+        Map<String, String> gcm = new LinkedHashMap<>();
+        gcm.put("nonceBytes", String.valueOf(AuthenticatedCrypto.NONCE_SIZE));
+        gcm.put("tagBits", String.valueOf(AuthenticatedCrypto.TAG_BITS));
+
+        Map<String, String> ctr = new LinkedHashMap<>();
+        ctr.put("ivBytes", String.valueOf(CIPHER_BLOCK_BYTES));
+
+        List<AlgorithmInfo> result = new ArrayList<>();
+        result.add(new AlgorithmInfo(AuthenticatedCrypto.CIPHER_NAME, AuthenticatedCrypto.class.getSimpleName(),
+                Keys.SYMMETRIC_KEY_SIZE, gcm));
+        result.add(new AlgorithmInfo(CIPHER_NAME, Crypto.class.getSimpleName(), Keys.SYMMETRIC_KEY_SIZE, ctr));
+        return result;
+    }
+
+    /**
+     * Packs a salt and ciphertext into a single String, for schemas that store both in one column.
+     * <p>
+     * Hand-rolled formats often join the two with a delimiter, which breaks as soon as the delimiter
+     * appears in the data. This uses length-prefixed {@link Frame} fields, base-64 encoded, so it's
+     * unambiguous for any bytes.
+     *
+     * @param salt       The salt.
+     * @param ciphertext The ciphertext.
+     * @return The packed value, or null if either argument is null.
+     * @see #unpackStored(String)
+     */
+    public static String packStored(byte[] salt, byte[] ciphertext) {
+
+        if (salt == null || ciphertext == null) {
+            return null;
+        }
+
+        return ByteArray.toBase64(new Frame().addField(salt).addField(ciphertext).toByteArray());
+    }
+
+    /**
+     * Unpacks a value produced by {@link #packStored(byte[], byte[])}.
+     *
+     * @param stored The packed value.
+     * @return An array containing the salt, followed by the ciphertext, or null if the given value is null.
+     * @throws IllegalArgumentException If the value is not in the expected format.
+     */
+    public static byte[][] unpackStored(String stored) {
+
+        if (stored == null) {
+            return null;
+        }
+
+        byte[][] fields = ByteArray.unframe(ByteArray.fromBase64(stored));
+        if (fields.length != 2) {
+            throw new IllegalArgumentException("Are you sure this is a packed salt and ciphertext? Expected 2 fields but got "
+                    + fields.length + ".");
+        }
+        return fields;
+    }
+
     /**
      * This method encrypts a byte array. This is useful if you have raw binary
      * data you need to encrypt.
@@ -312,6 +489,7 @@ public class Crypto {
      * @return The decrypted String, or null if the encrypted String is null.
      * @throws IllegalArgumentException If the given key is not a valid {@value #CIPHER_ALGORITHM}
      *                                  key.
+     * @throws MalformedDataException   If the encrypted data are shorter than an initialisation vector.
      * @see #encrypt(String, SecretKey)
      */
     public String decrypt(String encrypted, SecretKey key) {
@@ -326,6 +504,10 @@ public class Crypto {
 
         // Separate the initialisation vector from the data:
         byte[] bytes = ByteArray.fromBase64(encrypted);
+        if (bytes.length < getIvSize(cipher)) {
+            throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + bytes.length
+                    + ") is shorter than an initialisation vector value.");
+        }
         byte[] iv = ArrayUtils.subarray(bytes, 0, getIvSize(cipher));
         byte[] data = ArrayUtils.subarray(bytes, getIvSize(cipher), bytes.length);
 
@@ -336,6 +518,47 @@ public class Crypto {
         return ByteArray.toString(result);
     }
 
+    /**
+     * This method decrypts a String encrypted by {@link #encryptSynthetic(String, SecretKey)} and checks
+     * that the synthetic initialisation vector matches the plaintext.
+     *
+     * @param encrypted The encrypted String, base-64 encoded.
+     * @param key       The master key used for encryption.
+     * @return The decrypted String, or null if the encrypted String is null.
+     * @throws MalformedDataException  If the encrypted data are too short.
+     * @throws AuthenticationException If the key is wrong or the data have been altered.
+     * @see #encryptSynthetic(String, SecretKey)
+     */
+    public String decryptSynthetic(String encrypted, SecretKey key) {
+
+        // Basic null/empty check.
+        // An empty string can be encrypted, but not decrypted:
+        if (StringUtils.isEmpty(encrypted)) {
+            return encrypted;
+        }
+
+        Cipher cipher = getCipher();
+        SecretKey[] keys = Keys.splitKeys(key.getEncoded());
+
+        // Separate the initialisation vector from the data:
+        byte[] bytes = ByteArray.fromBase64(encrypted);
+        if (bytes.length < getIvSize(cipher)) {
+            throw new MalformedDataException("Are you sure this is encrypted data? Byte length (" + bytes.length
+                    + ") is shorter than an initialisation vector value.");
+        }
+        byte[] iv = ArrayUtils.subarray(bytes, 0, getIvSize(cipher));
+        byte[] data = ArrayUtils.subarray(bytes, getIvSize(cipher), bytes.length);
+
+        // Decrypt the data and check it matches the initialisation vector:
+        byte[] result = decrypt(iv, data, keys[0], cipher);
+        if (!MessageDigest.isEqual(iv, syntheticIv(result, keys[1], cipher))) {
+            throw new AuthenticationException("Unable to authenticate the encrypted data. Either the key is wrong or the data have been altered.");
+        }
+
+        // Return as a String:
+        return ByteArray.toString(result);
+    }
+
     /**
      * This method decrypts the given bytes and returns the plain text. This is
      * useful if you have raw binary data you need to decrypt.
@@ -486,6 +709,34 @@ public class Crypto {
         return cipherOutputStream;
     }
 
+    /**
+     * This method works in the same way as {@link #encrypt(OutputStream, SecretKey)},
+     * but reports progress to the given {@link ProgressListener} as data are written.
+     * <p>
+     * The listener is called each time at least {@value #PROGRESS_INTERVAL} bytes
+     * have been written since the last call and once more when the returned stream is closed,
+     * so the final value reported is the total size of the plaintext.
+     *
+     * @param destination The output stream to be wrapped with a
+     *                    {@link CipherOutputStream}.
+     * @param key         The key to be used to encrypt data written to the returned stream.
+     * @param listener    Receives progress updates. If this is null, this method behaves
+     *                    exactly like {@link #encrypt(OutputStream, SecretKey)}.
+     * @return An {@link OutputStream}, which wraps the given {@link OutputStream}.
+     * @throws IOException              If an error occurs in writing the initialisation vector to
+     *                                  the destination stream.
+     * @throws IllegalArgumentException If the given key is not a valid {@value #CIPHER_ALGORITHM}
+     *                                  key.
+     */
+    public OutputStream encrypt(OutputStream destination, SecretKey key, ProgressListener listener) throws IOException {
+
+        OutputStream result = encrypt(destination, key);
+        if (result != null && listener != null) {
+            result = new ProgressOutputStream(result, listener);
+        }
+        return result;
+    }
+
     /**
      * This method wraps the source {@link InputStream} with a
      * {@link CipherInputStream}.
@@ -521,7 +772,7 @@ public class Crypto {
         byte[] salt = new byte[Generate.SALT_BYTES];
 
         // THe key generation salt can be stored unencrypted at the start of the stream:
-        source.read(salt);
+        readFully(source, salt);
 
         // Generate the key:
         SecretKey key = Keys.generateSecretKey(password, ByteArray.toBase64(salt));
@@ -566,7 +817,7 @@ public class Crypto {
         byte[] iv = new byte[getIvSize(cipher)];
 
         // The IV can be stored unencrypted at the start of the stream:
-        source.read(iv);
+        readFully(source, iv);
 
         // Get a cipher instance and create the cipherInputStream:
         initCipher(cipher, Cipher.DECRYPT_MODE, key, iv);
@@ -576,6 +827,56 @@ public class Crypto {
         return cipherInputStream;
     }
 
+    /**
+     * This method decrypts encrypted data that have been split into several parts, for example
+     * by a chunked upload or a storage backend that limits object sizes.
+     * <p>
+     * The parts are read in order as if they were a single stream, as produced by
+     * {@link #encrypt(OutputStream, SecretKey)}. The parts can be split at any byte boundary,
+     * including within the initialisation vector.
+     *
+     * @param key   The key to be used for decryption.
+     * @param parts The parts of the encrypted data, in order.
+     * @return A {@link CipherInputStream}, which reads the parts in sequence
+     * and will decrypt the data as they are read.
+     * @throws IOException              If an error occurs in reading the initialisation vector from
+     *                                  the first part(s).
+     * @throws IllegalArgumentException If the given key is not a valid {@value #CIPHER_ALGORITHM}
+     *                                  key.
+     * @see #decrypt(InputStream, SecretKey)
+     */
+    public InputStream decrypt(SecretKey key, InputStream... parts) throws IOException {
+        return decrypt(new SequenceInputStream(Collections.enumeration(Arrays.asList(parts))), key);
+    }
+
+    /**
+     * Reads from the given stream until the buffer is full or the end of the stream is reached.
+     * <p>
+     * A single read may return fewer bytes than requested (for example at the boundary
+     * between parts of a {@link SequenceInputStream}) so this keeps reading.
+     *
+     * @param source The stream to read from.
+     * @param buffer The buffer to fill.
+     * @throws IOException If an error occurs in reading from the stream.
+     */
+    private void readFully(InputStream source, byte[] buffer) throws IOException {
+        int offset = 0;
+        int read;
+        while (offset < buffer.length && (read = source.read(buffer, offset, buffer.length - offset)) != -1) {
+            offset += read;
+        }
+    }
+
+    /**
+     * @param data   The plaintext.
+     * @param macKey The key used to compute the initialisation vector.
+     * @param cipher The {@link Cipher} instance, which determines the IV size.
+     * @return The {@value HashMac#ALGORITHM} of the plaintext, truncated to the IV size.
+     */
+    private byte[] syntheticIv(byte[] data, SecretKey macKey, Cipher cipher) {
+        return Arrays.copyOf(new HashMac(macKey).digest(data), getIvSize(cipher));
+    }
+
     /**
      * @return The initialization vector size, in bytes.
      * <p>
@@ -649,4 +950,48 @@ public class Crypto {
                             + cipher.getBlockSize() + " bytes.", e);
         }
     }
+
+    /**
+     * Counts the bytes written through to a wrapped stream and reports them to a {@link ProgressListener}.
+     */
+    private static class ProgressOutputStream extends FilterOutputStream {
+
+        private final ProgressListener listener;
+        private long count;
+        private long reported;
+
+        ProgressOutputStream(OutputStream out, ProgressListener listener) {
+            super(out);
+            this.listener = listener;
+        }
+
+        @Override
+        public void write(int b) throws IOException {
+            out.write(b);
+            progress(1);
+        }
+
+        @Override
+        public void write(byte[] b, int off, int len) throws IOException {
+            out.write(b, off, len);
+            progress(len);
+        }
+
+        @Override
+        public void close() throws IOException {
+            super.close();
+            if (count != reported) {
+                reported = count;
+                listener.onProgress(count);
+            }
+        }
+
+        private void progress(int bytes) {
+            count += bytes;
+            if (count - reported >= PROGRESS_INTERVAL) {
+                reported = count;
+                listener.onProgress(count);
+            }
+        }
+    }
 }
@@ -0,0 +1,85 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * The configuration for {@link AuthenticatedCrypto}, validated in one place
+ * (see {@link AuthenticatedCrypto#AuthenticatedCrypto(CryptoOptions)}).
+ * <p>
+ * The defaults are the same as for {@link AuthenticatedCrypto#AuthenticatedCrypto()}, so you only need to
+ * change the values you need to, for example to interoperate with another system.
+ *
+ * @author David Carboni
+ */
+public class CryptoOptions {
+
+    /**
+     * The key size that means keys of any valid size are accepted, as for {@link AuthenticatedCrypto#AuthenticatedCrypto()}.
+     */
+    public static final int ANY_KEY_SIZE = 0;
+
+    private final int keyBits;
+    private final int nonceSize;
+    private final int tagBits;
+
+    /**
+     * Initialises the instance with the defaults: any key size (see {@link #ANY_KEY_SIZE}),
+     * a {@value AuthenticatedCrypto#NONCE_SIZE}-byte nonce and a {@value AuthenticatedCrypto#TAG_BITS}-bit tag.
+     * <p>
+     * The key size isn't taken from {@link Keys#SYMMETRIC_KEY_SIZE}, because that can change after the options
+     * are created (see {@link Keys#useStandardKeys()}). If you want to fix the key size, specify it explicitly.
+     */
+    public CryptoOptions() {
+        this(ANY_KEY_SIZE, AuthenticatedCrypto.NONCE_SIZE, AuthenticatedCrypto.TAG_BITS);
+    }
+
+    /**
+     * @param keyBits   The key size, in bits: 128, 192 or 256, or {@value #ANY_KEY_SIZE} to accept any of these.
+     *                  Keys you pass in of any other size are rejected on use. This doesn't apply to keys derived
+     *                  from a password by {@link AuthenticatedCrypto#encryptWithPassword(byte[], String)}, which are
+     *                  always {@link Keys#SYMMETRIC_KEY_SIZE} bits.
+     * @param nonceSize The nonce size, in bytes. This must be between 1 and 255.
+     * @param tagBits   The authentication tag length, in bits. This must be a multiple of 8 between
+     *                  {@value AuthenticatedCrypto#MIN_TAG_BITS} and {@value AuthenticatedCrypto#TAG_BITS}.
+     * @throws IllegalArgumentException If any of the options is invalid.
+     */
+    public CryptoOptions(int keyBits, int nonceSize, int tagBits) {
+        if (keyBits != ANY_KEY_SIZE && keyBits != 128 && keyBits != 192 && keyBits != 256) {
+            throw new IllegalArgumentException("Key size must be 128, 192 or 256 bits, but got " + keyBits);
+        }
+        if (nonceSize < 1 || nonceSize > 255) {
+            throw new IllegalArgumentException("Nonce size must be between 1 and 255 bytes, but got " + nonceSize);
+        }
+        if (tagBits < AuthenticatedCrypto.MIN_TAG_BITS || tagBits > AuthenticatedCrypto.TAG_BITS || tagBits % 8 != 0) {
+            throw new IllegalArgumentException("Tag length must be a multiple of 8 between " + AuthenticatedCrypto.MIN_TAG_BITS
+                    + " and " + AuthenticatedCrypto.TAG_BITS + " bits, but got " + tagBits);
+        }
+        this.keyBits = keyBits;
+        this.nonceSize = nonceSize;
+        this.tagBits = tagBits;
+    }
+
+    /**
+     * @return The key size, in bits, or {@value #ANY_KEY_SIZE} if keys of any valid size are accepted.
+     */
+    public int getKeyBits() {
+        return keyBits;
+    }
+
+    /**
+     * @return The nonce size, in bytes.
+     */
+    public int getNonceSize() {
+        return nonceSize;
+    }
+
+    /**
+     * @return The authentication tag length, in bits.
+     */
+    public int getTagBits() {
+        return tagBits;
+    }
+
+    @Override
+    public String toString() {
+        return (keyBits == ANY_KEY_SIZE ? "any key size" : keyBits + "-bit key") + ", " + nonceSize + "-byte nonce, " + tagBits + "-bit tag";
+    }
+}
@@ -0,0 +1,37 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.util.Map;
+
+/**
+ * The result of {@link AuthenticatedCrypto#decryptRecord(byte[], javax.crypto.SecretKey)}.
+ *
+ * @author David Carboni
+ */
+public class DecryptedRecord {
+
+    private final Map<String, String> header;
+    private final byte[] data;
+
+    /**
+     * @param header The authenticated header.
+     * @param data   The decrypted data.
+     */
+    public DecryptedRecord(Map<String, String> header, byte[] data) {
+        this.header = header;
+        this.data = data;
+    }
+
+    /**
+     * @return The header, which was stored in the clear but has been authenticated, sorted by name.
+     */
+    public Map<String, String> getHeader() {
+        return header;
+    }
+
+    /**
+     * @return The decrypted data.
+     */
+    public byte[] getData() {
+        return data;
+    }
+}
@@ -0,0 +1,34 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * The base class for errors detected when decrypting data.
+ * <p>
+ * This extends {@link IllegalArgumentException} because these errors are caused by the input
+ * (the encrypted data or the key), rather than the state of the library.
+ * The subclasses let you tell apart the different causes, for example to respond differently
+ * to a client that sent malformed data than to one that used the wrong key:
+ * <ul>
+ * <li>{@link AuthenticationException}: the key is wrong or the data have been altered.</li>
+ * <li>{@link MalformedDataException}: the data are not in the expected format (e.g. truncated).</li>
+ * <li>{@link UnsupportedCipherException}: the data specify a cipher that isn't supported.</li>
+ * </ul>
+ *
+ * @author David Carboni
+ */
+public class DecryptionException extends IllegalArgumentException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public DecryptionException(String message) {
+        super(message);
+    }
+
+    /**
+     * @param message A description of the error.
+     * @param cause   The underlying cause of the error.
+     */
+    public DecryptionException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
@@ -0,0 +1,41 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+
+/**
+ * A password-based secret key, together with the salt value used to generate it.
+ * <p>
+ * This is returned by {@link Keys#newDeterministicSecretKey(String)}. The salt isn't
+ * particularly sensitive, so it can be stored alongside the data and used with the
+ * password to regenerate the key via {@link Keys#generateSecretKey(String, String)}.
+ *
+ * @author David Carboni
+ */
+public class DerivedSecretKey {
+
+    private final SecretKey key;
+    private final String salt;
+
+    /**
+     * @param key  The generated key.
+     * @param salt The base64-encoded salt value used to generate the key.
+     */
+    public DerivedSecretKey(SecretKey key, String salt) {
+        this.key = key;
+        this.salt = salt;
+    }
+
+    /**
+     * @return The generated key.
+     */
+    public SecretKey getKey() {
+        return key;
+    }
+
+    /**
+     * @return The base64-encoded salt value used to generate the key. You'll need to store this.
+     */
+    public String getSalt() {
+        return salt;
+    }
+}
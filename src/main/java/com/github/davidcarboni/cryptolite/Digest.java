@@ -0,0 +1,77 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+
+/**
+ * Provides a quick way to compute a message digest (hash) of a byte array.
+ * <p>
+ * This is handy for debugging, checksums and cache keys. Don't use it for passwords:
+ * use {@link Password} instead.
+ *
+ * @author David Carboni
+ */
+public class Digest {
+
+    /**
+     * The SHA-256 digest algorithm.
+     */
+    public static final String SHA256 = "SHA-256";
+
+    /**
+     * The SHA-512 digest algorithm.
+     */
+    public static final String SHA512 = "SHA-512";
+
+    /**
+     * Computes the {@value #SHA256} digest of the given bytes.
+     *
+     * @param bytes The bytes to digest.
+     * @return The digest, or null if the given bytes are null.
+     */
+    public static byte[] sha256(byte[] bytes) {
+        return digest(SHA256, bytes);
+    }
+
+    /**
+     * Computes the {@value #SHA256} digest of the given bytes.
+     *
+     * @param bytes The bytes to digest.
+     * @return The digest as a hexadecimal string, or null if the given bytes are null.
+     */
+    public static String sha256Hex(byte[] bytes) {
+        return ByteArray.toHex(sha256(bytes));
+    }
+
+    /**
+     * Computes the {@value #SHA512} digest of the given bytes.
+     *
+     * @param bytes The bytes to digest.
+     * @return The digest as a hexadecimal string, or null if the given bytes are null.
+     */
+    public static String sha512Hex(byte[] bytes) {
+        return ByteArray.toHex(digest(SHA512, bytes));
+    }
+
+    /**
+     * @param algorithm The digest algorithm.
+     * @param bytes     The bytes to digest.
+     * @return The digest, or null if the given bytes are null.
+     */
+    private static byte[] digest(String algorithm, byte[] bytes) {
+
+        if (bytes == null) {
+            return null;
+        }
+
+        try {
+            return MessageDigest.getInstance(algorithm).digest(bytes);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return digest(algorithm, bytes);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
+            }
+        }
+    }
+}
@@ -0,0 +1,80 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.io.OutputStream;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+
+/**
+ * An {@link OutputStream} that computes a {@value Digest#SHA256} digest of the data written to it.
+ * <p>
+ * This lets you hash data as it flows, rather than making a second pass over it. For example,
+ * to store an upload and hash it at the same time, copy the upload to both your storage stream
+ * and an instance of this class (e.g. with a "tee" stream), then call {@link #sumHex()}.
+ * <p>
+ * The data are discarded once they've been added to the digest. Instances are not thread-safe.
+ *
+ * @author David Carboni
+ */
+public class DigestWriter extends OutputStream {
+
+    private final MessageDigest digest;
+
+    /**
+     * Initialises the instance to compute a {@value Digest#SHA256} digest.
+     */
+    public DigestWriter() {
+        this(Digest.SHA256);
+    }
+
+    /**
+     * This constructor is protected so that, should you need a different algorithm,
+     * it is possible to create a subclass with different settings.
+     *
+     * @param algorithm The digest algorithm. This should normally be {@value Digest#SHA256}.
+     */
+    protected DigestWriter(String algorithm) {
+        digest = getDigest(algorithm);
+    }
+
+    @Override
+    public void write(int b) {
+        digest.update((byte) b);
+    }
+
+    @Override
+    public void write(byte[] b, int off, int len) {
+        digest.update(b, off, len);
+    }
+
+    /**
+     * Completes the digest of the data written so far. The digest is then reset,
+     * so subsequent writes start a new digest.
+     *
+     * @return The digest.
+     */
+    public byte[] sum() {
+        return digest.digest();
+    }
+
+    /**
+     * Completes the digest of the data written so far. The digest is then reset,
+     * so subsequent writes start a new digest.
+     *
+     * @return The digest as a hexadecimal string.
+     */
+    public String sumHex() {
+        return ByteArray.toHex(sum());
+    }
+
+    private static MessageDigest getDigest(String algorithm) {
+        try {
+            return MessageDigest.getInstance(algorithm);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return getDigest(algorithm);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
+            }
+        }
+    }
+}
@@ -3,12 +3,33 @@ package com.github.davidcarboni.cryptolite;
 import java.io.ByteArrayInputStream;
 import java.io.IOException;
 import java.io.InputStream;
+import java.math.BigDecimal;
+import java.math.BigInteger;
 import java.nio.charset.StandardCharsets;
 import java.security.*;
+import java.util.Arrays;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.TreeMap;
 
 /**
  * This class provides a public-private key digital signature capability. The signature algorithm
  * used is {@value #ALGORITHM}.
+ * <p>
+ * If you need to verify signatures in another language (or produce signatures this class can verify),
+ * the exact scheme is:
+ * <ul>
+ * <li>RSASSA-PSS (RFC 8017), not PKCS#1 v1.5.</li>
+ * <li>SHA-256 as the message digest.</li>
+ * <li>MGF1 with SHA-256 as the mask generation function.</li>
+ * <li>A salt length of 32 bytes (the digest length) and the standard trailer field (0xBC).</li>
+ * <li>The content is signed as UTF-8 bytes and the signature is encoded as standard (not URL-safe) base64.</li>
+ * </ul>
+ * For example, with OpenSSL:
+ * <pre>
+ * openssl dgst -sha256 -sigopt rsa_padding_mode:pss -sigopt rsa_pss_saltlen:32 -sigopt rsa_mgf1_md:sha256 ...
+ * </pre>
  *
  * @author David Carboni
  */
@@ -19,6 +40,20 @@ public class DigitalSignature {
      */
     public static final String ALGORITHM = "SHA256withRSAandMGF1";
 
+    /**
+     * The digital signature algorithm used by {@link #forKey(Key)} for EC keys: {@value #EC_ALGORITHM}.
+     */
+    public static final String EC_ALGORITHM = "SHA256withECDSA";
+
+    /**
+     * The first line of a detached signature produced by {@link #signDetached(InputStream, PrivateKey, String)}.
+     */
+    public static final String DETACHED_HEADER = "cryptolite-signature: 1";
+
+    private static final String DETACHED_ALGORITHM = "algorithm";
+    private static final String DETACHED_KEY_ID = "key-id";
+    private static final String DETACHED_SIGNATURE = "signature";
+
     private String algorithm;
 
     /**
@@ -39,6 +74,33 @@ public class DigitalSignature {
         this.algorithm = algorithm;
     }
 
+    /**
+     * Returns an instance with a signature algorithm suitable for the given key.
+     * <p>
+     * This means you can sign and verify with any supported key (for example one loaded with
+     * {@link Keys#parsePrivateKeyPem(String)}) without needing to know its type in advance:
+     * <ul>
+     * <li>RSA keys use {@value #ALGORITHM}.</li>
+     * <li>EC keys use {@value #EC_ALGORITHM}.</li>
+     * <li>{@value Keys#SIGNING_ALGORITHM} keys use {@value Keys#SIGNING_ALGORITHM}.</li>
+     * </ul>
+     *
+     * @param key A public or private key.
+     * @return A {@link DigitalSignature} for the key type.
+     * @throws IllegalArgumentException If the key type is not supported.
+     */
+    public static DigitalSignature forKey(Key key) {
+        String keyAlgorithm = key.getAlgorithm();
+        if ("RSA".equals(keyAlgorithm)) {
+            return new DigitalSignature(ALGORITHM);
+        } else if ("EC".equals(keyAlgorithm) || "ECDSA".equals(keyAlgorithm)) {
+            return new DigitalSignature(EC_ALGORITHM);
+        } else if (Keys.SIGNING_ALGORITHM.equals(keyAlgorithm) || "EdDSA".equals(keyAlgorithm)) {
+            return new DigitalSignature(Keys.SIGNING_ALGORITHM);
+        }
+        throw new IllegalArgumentException("Unsupported key type for digital signature: " + keyAlgorithm);
+    }
+
     /**
      * Generates a digital signature for the given string.
      *
@@ -158,6 +220,277 @@ public class DigitalSignature {
 
     }
 
+    /**
+     * Verifies a batch of signatures, such as a feed of signed events, reporting the result for each one.
+     * <p>
+     * Neither the JCE nor BouncyCastle provide true batch verification for {@value Keys#SIGNING_ALGORITHM},
+     * so each signature is checked individually. This reuses a single {@link Signature} instance, which
+     * avoids the cost of looking up the algorithm for every item. A signature that can't be parsed, or a key
+     * that isn't valid for the algorithm of this instance, is reported as invalid, rather than failing the whole batch.
+     *
+     * @param items The signed messages to check.
+     * @return An array with the result for each item, in the same order: true if that item's signature is valid.
+     * @see #verifyAll(List)
+     */
+    public boolean[] verifyBatch(List<BatchItem> items) {
+
+        boolean[] results = new boolean[items.size()];
+        Signature verifier = getSignature();
+        for (int i = 0; i < results.length; i++) {
+            BatchItem item = items.get(i);
+            try {
+                verifier.initVerify(item.getPublicKey());
+                verifier.update(item.getMessage());
+                results[i] = verifier.verify(ByteArray.fromBase64(item.getSignature()));
+            } catch (InvalidKeyException | SignatureException | IllegalArgumentException e) {
+                results[i] = false;
+            }
+        }
+        return results;
+    }
+
+    /**
+     * Verifies a batch of signatures, as per {@link #verifyBatch(List)}, and reports whether they're all valid.
+     * <p>
+     * This is useful when a batch should be accepted or rejected as a whole. Every signature is checked,
+     * even after one fails.
+     *
+     * @param items The signed messages to check.
+     * @return If every signature in the batch is valid, true. An empty batch is valid.
+     */
+    public boolean verifyAll(List<BatchItem> items) {
+
+        boolean result = true;
+        for (boolean valid : verifyBatch(items)) {
+            result &= valid;
+        }
+        return result;
+    }
+
+    /**
+     * Generates a self-describing detached signature, suitable for saving as a <code>.sig</code> file
+     * alongside a release artifact.
+     * <p>
+     * As well as the signature, this records the signature algorithm and an identifier for the signing key,
+     * so the verifier can check they have the right key before checking the signature. The format is
+     * plain text, one <code>name: value</code> pair per line:
+     * <pre>
+     * cryptolite-signature: 1
+     * algorithm: SHA256withRSAandMGF1
+     * key-id: ...
+     * signature: ...
+     * </pre>
+     *
+     * @param content    The input to be digitally signed.
+     * @param privateKey The {@link PrivateKey} with which the input is to be signed.
+     * @param keyId      An identifier for the signing key, for example from {@link Keys#keyId(PublicKey)}.
+     * @return The detached signature. If the content is null, null is returned.
+     * @see #verifyDetached(InputStream, PublicKey, String, String)
+     */
+    public String signDetached(InputStream content, PrivateKey privateKey, String keyId) {
+
+        if (content == null) {
+            return null;
+        }
+        if (keyId == null || keyId.trim().isEmpty() || keyId.contains("\n")) {
+            throw new IllegalArgumentException("Please provide a key ID that isn't blank and is on a single line.");
+        }
+
+        return DETACHED_HEADER + "\n" +
+                DETACHED_ALGORITHM + ": " + algorithm + "\n" +
+                DETACHED_KEY_ID + ": " + keyId.trim() + "\n" +
+                DETACHED_SIGNATURE + ": " + sign(content, privateKey) + "\n";
+    }
+
+    /**
+     * Verifies a detached signature produced by {@link #signDetached(InputStream, PrivateKey, String)}.
+     *
+     * @param content   The content for which the signature is to be verified.
+     * @param publicKey The {@link PublicKey} corresponding to the {@link PrivateKey} that was used to sign the content.
+     * @param keyId     The identifier you expect the signature to record for this key.
+     * @param detached  The detached signature.
+     * @return If the signature matches the content and key, true. Otherwise false.
+     * @throws IllegalArgumentException If the detached signature is malformed, or records an algorithm or key ID
+     *                                  that doesn't match this instance and the given key ID.
+     */
+    public boolean verifyDetached(InputStream content, PublicKey publicKey, String keyId, String detached) {
+
+        if (detached == null || !detached.startsWith(DETACHED_HEADER)) {
+            throw new IllegalArgumentException("Are you sure this is a detached signature? It doesn't start with "
+                    + DETACHED_HEADER);
+        }
+
+        Map<String, String> fields = new HashMap<>();
+        for (String line : detached.split("\r?\n")) {
+            int colon = line.indexOf(':');
+            if (colon > 0) {
+                fields.put(line.substring(0, colon).trim(), line.substring(colon + 1).trim());
+            }
+        }
+
+        if (!algorithm.equals(fields.get(DETACHED_ALGORITHM))) {
+            throw new IllegalArgumentException("Signature algorithm mismatch. Expected " + algorithm
+                    + " but the signature specifies " + fields.get(DETACHED_ALGORITHM));
+        }
+        if (keyId == null || !keyId.trim().equals(fields.get(DETACHED_KEY_ID))) {
+            throw new IllegalArgumentException("Key ID mismatch. Expected " + keyId
+                    + " but the signature specifies " + fields.get(DETACHED_KEY_ID));
+        }
+        String signature = fields.get(DETACHED_SIGNATURE);
+        if (signature == null) {
+            throw new IllegalArgumentException("Are you sure this is a detached signature? There's no "
+                    + DETACHED_SIGNATURE + " field.");
+        }
+
+        return verify(content, publicKey, signature);
+    }
+
+    /**
+     * Generates a digital signature for a structured value, such as an API payload, by signing its
+     * canonical JSON representation (see {@link #canonicalJson(Object)}).
+     * <p>
+     * Because the JSON is canonical, the signature doesn't depend on the order in which map entries
+     * were added, so the recipient can rebuild the value and verify it with
+     * {@link #verifyJson(Object, PublicKey, String)}.
+     *
+     * @param value      The value to be signed. This is typically a {@link Map}.
+     * @param privateKey The {@link PrivateKey} with which the value is to be signed.
+     * @return The signature as a base64-encoded string.
+     * @throws IllegalArgumentException If the value contains a type that can't be represented as JSON.
+     */
+    public String signJson(Object value, PrivateKey privateKey) {
+        return sign(canonicalJson(value), privateKey);
+    }
+
+    /**
+     * Verifies a signature generated by {@link #signJson(Object, PrivateKey)}.
+     *
+     * @param value     The value for which the signature is to be verified.
+     * @param publicKey The {@link PublicKey} corresponding to the {@link PrivateKey} that was used to sign the value.
+     * @param signature The signature to be verified.
+     * @return If the signature matches the value and key, true. Otherwise false.
+     * @throws IllegalArgumentException If the value contains a type that can't be represented as JSON.
+     */
+    public boolean verifyJson(Object value, PublicKey publicKey, String signature) {
+        return verify(canonicalJson(value), publicKey, signature);
+    }
+
+    /**
+     * Serialises a value as canonical JSON: object keys are sorted (by UTF-16 code unit, as
+     * {@link String#compareTo(String)} does) and there is no insignificant whitespace, so equal values
+     * always produce the same string.
+     * <p>
+     * The supported types are:
+     * <ul>
+     * <li>{@link Map} (keys are converted with {@link String#valueOf(Object)}), as a JSON object.</li>
+     * <li>{@link Iterable} and {@link Object} arrays, as a JSON array.</li>
+     * <li>{@link CharSequence}, as a JSON string.</li>
+     * <li>{@link Number} and {@link Boolean}. Decimal numbers are written in plain notation
+     * with trailing zeros removed, and must be finite.</li>
+     * <li><code>null</code>.</li>
+     * </ul>
+     *
+     * @param value The value to serialise.
+     * @return The canonical JSON for the value.
+     * @throws IllegalArgumentException If the value contains any other type, or a non-finite number.
+     */
+    public static String canonicalJson(Object value) {
+        StringBuilder json = new StringBuilder();
+        appendJson(json, value);
+        return json.toString();
+    }
+
+    private static void appendJson(StringBuilder json, Object value) {
+
+        if (value == null) {
+            json.append("null");
+        } else if (value instanceof Map) {
+            Map<String, Object> sorted = new TreeMap<>();
+            for (Map.Entry<?, ?> entry : ((Map<?, ?>) value).entrySet()) {
+                String key = String.valueOf(entry.getKey());
+                if (sorted.containsKey(key)) {
+                    throw new IllegalArgumentException("Duplicate JSON key: " + key);
+                }
+                sorted.put(key, entry.getValue());
+            }
+            json.append('{');
+            String separator = "";
+            for (Map.Entry<String, Object> entry : sorted.entrySet()) {
+                json.append(separator);
+                appendString(json, entry.getKey());
+                json.append(':');
+                appendJson(json, entry.getValue());
+                separator = ",";
+            }
+            json.append('}');
+        } else if (value instanceof Iterable || value instanceof Object[]) {
+            Iterable<?> items = value instanceof Iterable ? (Iterable<?>) value : Arrays.asList((Object[]) value);
+            json.append('[');
+            String separator = "";
+            for (Object item : items) {
+                json.append(separator);
+                appendJson(json, item);
+                separator = ",";
+            }
+            json.append(']');
+        } else if (value instanceof CharSequence) {
+            appendString(json, value.toString());
+        } else if (value instanceof Boolean) {
+            json.append(value);
+        } else if (value instanceof Byte || value instanceof Short || value instanceof Integer
+                || value instanceof Long || value instanceof BigInteger) {
+            json.append(value);
+        } else if (value instanceof Number) {
+            BigDecimal decimal;
+            try {
+                decimal = value instanceof BigDecimal ? (BigDecimal) value : new BigDecimal(value.toString());
+            } catch (NumberFormatException e) {
+                throw new IllegalArgumentException("Numbers must be finite to be represented as JSON: " + value, e);
+            }
+            decimal = decimal.stripTrailingZeros();
+            json.append(decimal.signum() == 0 ? "0" : decimal.toPlainString());
+        } else {
+            throw new IllegalArgumentException("Unable to represent " + value.getClass().getName() + " as JSON.");
+        }
+    }
+
+    private static void appendString(StringBuilder json, String value) {
+        json.append('"');
+        for (int i = 0; i < value.length(); i++) {
+            char c = value.charAt(i);
+            switch (c) {
+                case '"':
+                    json.append("\\\"");
+                    break;
+                case '\\':
+                    json.append("\\\\");
+                    break;
+                case '\b':
+                    json.append("\\b");
+                    break;
+                case '\f':
+                    json.append("\\f");
+                    break;
+                case '\n':
+                    json.append("\\n");
+                    break;
+                case '\r':
+                    json.append("\\r");
+                    break;
+                case '\t':
+                    json.append("\\t");
+                    break;
+                default:
+                    if (c < 0x20) {
+                        json.append(String.format("\\u%04x", (int) c));
+                    } else {
+                        json.append(c);
+                    }
+            }
+        }
+        json.append('"');
+    }
+
     /**
      * @return A new {@link Signature} instance.
      */
@@ -0,0 +1,79 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+
+/**
+ * Provides envelope encryption with a {@link RemoteKey}, such as a key held in a KMS or HSM.
+ * <p>
+ * Each message is encrypted locally with {@link AuthenticatedCrypto} and a new random data key.
+ * The data key is then wrapped by the remote key and stored with the message. The output is a
+ * {@link Frame} containing the wrapped data key, followed by the encrypted message.
+ * <p>
+ * This is the standard way to use a KMS: the master key never leaves the service, and large messages
+ * don't need to be sent to it.
+ *
+ * @author David Carboni
+ */
+public class Envelope {
+
+    private static final AuthenticatedCrypto crypto = new AuthenticatedCrypto();
+
+    /**
+     * Encrypts a message with a new data key, which is wrapped by the given remote key.
+     *
+     * @param remoteKey The remote key to wrap the data key with.
+     * @param message   The message to encrypt.
+     * @return The envelope, or null if the message is null.
+     */
+    public static byte[] seal(RemoteKey remoteKey, byte[] message) {
+
+        if (message == null) {
+            return null;
+        }
+
+        SecretKey dataKey = Keys.newSecretKey();
+        byte[] dataKeyBytes = Keys.copyKeyBytes(dataKey);
+        byte[] wrappedDataKey;
+        try {
+            wrappedDataKey = remoteKey.wrap(dataKeyBytes);
+        } finally {
+            ByteArray.zeroize(dataKeyBytes);
+        }
+
+        return new Frame().addField(wrappedDataKey).addField(crypto.encrypt(message, dataKey)).toByteArray();
+    }
+
+    /**
+     * Decrypts a message encrypted by {@link #seal(RemoteKey, byte[])}.
+     *
+     * @param remoteKey The remote key the data key was wrapped with.
+     * @param envelope  The envelope.
+     * @return The decrypted message, or null if the envelope is null.
+     * @throws MalformedDataException  If the envelope is not in the expected format.
+     * @throws AuthenticationException If the data key is wrong or the envelope has been altered.
+     */
+    public static byte[] open(RemoteKey remoteKey, byte[] envelope) {
+
+        if (envelope == null) {
+            return null;
+        }
+
+        byte[][] fields;
+        try {
+            fields = ByteArray.unframe(envelope);
+        } catch (IllegalArgumentException e) {
+            throw new MalformedDataException("Are you sure this is an envelope? " + e.getMessage(), e);
+        }
+        if (fields.length != 2) {
+            throw new MalformedDataException("Are you sure this is an envelope? Expected 2 fields but got "
+                    + fields.length + ".");
+        }
+
+        byte[] dataKeyBytes = remoteKey.unwrap(fields[0]);
+        try {
+            return crypto.decrypt(fields[1], Keys.secretKeyFromBytes(dataKeyBytes));
+        } finally {
+            ByteArray.zeroize(dataKeyBytes);
+        }
+    }
+}
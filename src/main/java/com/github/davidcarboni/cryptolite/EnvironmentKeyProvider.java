@@ -0,0 +1,127 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.apache.commons.lang.StringUtils;
+
+import javax.crypto.SecretKey;
+import javax.crypto.spec.SecretKeySpec;
+import java.security.MessageDigest;
+import java.util.Collections;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.Set;
+
+/**
+ * A {@link KeyProvider} that loads keys from environment variables.
+ * <p>
+ * Each variable whose name starts with the given prefix is treated as a base-64 encoded
+ * {@value Keys#SYMMETRIC_ALGORITHM} key and the rest of the name is the key identifier.
+ * For example, with the prefix <code>CRYPTOLITE_KEY_</code>, the variable <code>CRYPTOLITE_KEY_v1</code>
+ * provides the key with identifier <code>v1</code>. You can generate a suitable value with
+ * {@link Keys#newSecretKeyBase64()}.
+ * <p>
+ * Keys are read when the instance is created. Call {@link #reload()} to pick up changes,
+ * for example to add a new key during rotation without restarting. This class is thread-safe.
+ * <p>
+ * By default, keys are looked up in a hash map, so the time taken could reveal something about which
+ * identifiers exist. If your key identifiers are sensitive, call {@link #setConstantTimeLookup(boolean)}.
+ *
+ * @author David Carboni
+ */
+public class EnvironmentKeyProvider implements KeyProvider {
+
+    private final String prefix;
+
+    private volatile Map<String, SecretKey> keys;
+
+    private volatile boolean constantTimeLookup;
+
+    /**
+     * @param prefix The prefix of the environment variables that contain keys.
+     * @throws IllegalArgumentException If the prefix is blank or a variable is not valid base-64.
+     */
+    public EnvironmentKeyProvider(String prefix) {
+        if (StringUtils.isBlank(prefix)) {
+            throw new IllegalArgumentException("Please provide a prefix for key environment variables.");
+        }
+        this.prefix = prefix;
+        reload();
+    }
+
+    @Override
+    public SecretKey getKey(String id) {
+        if (constantTimeLookup) {
+            return getKeyConstantTime(id);
+        }
+        return keys.get(id);
+    }
+
+    /**
+     * Sets whether {@link #getKey(String)} uses a constant-time lookup.
+     * <p>
+     * This is off by default. When it's on, every loaded key identifier is compared with the requested one,
+     * using digests of the identifiers and {@link MessageDigest#isEqual(byte[], byte[])}, with no early exit.
+     * Lookups take time proportional to the number of keys, but the time doesn't depend on whether, or where,
+     * the identifier matches. This is defence in depth for when identifiers are themselves sensitive.
+     *
+     * @param constantTimeLookup True to use a constant-time lookup.
+     */
+    public void setConstantTimeLookup(boolean constantTimeLookup) {
+        this.constantTimeLookup = constantTimeLookup;
+    }
+
+    /**
+     * @return The identifiers of the keys currently loaded.
+     */
+    public Set<String> getKeyIds() {
+        return keys.keySet();
+    }
+
+    /**
+     * Re-reads the environment, replacing the keys currently loaded.
+     *
+     * @throws IllegalArgumentException If a variable is not valid base-64.
+     */
+    public void reload() {
+        Map<String, SecretKey> loaded = new HashMap<>();
+        for (Map.Entry<String, String> variable : getEnvironment().entrySet()) {
+            String name = variable.getKey();
+            if (name.startsWith(prefix) && name.length() > prefix.length()) {
+                String value = StringUtils.trim(variable.getValue());
+                if (StringUtils.isEmpty(value) || !Base64.isBase64(value)) {
+                    throw new IllegalArgumentException("Environment variable " + name + " is not a base-64 encoded key.");
+                }
+                loaded.put(name.substring(prefix.length()), new SecretKeySpec(ByteArray.fromBase64(value), Keys.SYMMETRIC_ALGORITHM));
+            }
+        }
+        keys = Collections.unmodifiableMap(loaded);
+    }
+
+    /**
+     * @param id The key identifier.
+     * @return The key with the given identifier, or null if there is no such key.
+     */
+    private SecretKey getKeyConstantTime(String id) {
+        if (id == null) {
+            return null;
+        }
+        byte[] target = Digest.sha256(ByteArray.fromString(id));
+        SecretKey result = null;
+        for (Map.Entry<String, SecretKey> entry : keys.entrySet()) {
+            boolean match = MessageDigest.isEqual(target, Digest.sha256(ByteArray.fromString(entry.getKey())));
+            SecretKey candidate = entry.getValue();
+            result = match ? candidate : result;
+        }
+        return result;
+    }
+
+    /**
+     * This method is protected so that a subclass can supply variables from elsewhere, such as in tests.
+     * NB it is called from the constructor.
+     *
+     * @return The environment variables.
+     */
+    protected Map<String, String> getEnvironment() {
+        return System.getenv();
+    }
+}
@@ -0,0 +1,43 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.io.ByteArrayOutputStream;
+import java.nio.ByteBuffer;
+
+/**
+ * Packs multiple variable-length fields into a single byte array, unambiguously.
+ * <p>
+ * Each field is prefixed with its length as a {@value #LENGTH_BYTES}-byte, big-endian integer.
+ * This avoids bespoke offset calculations when combining values such as an ephemeral key,
+ * a nonce and ciphertext. Use {@link ByteArray#unframe(byte[])} to recover the fields.
+ *
+ * @author David Carboni
+ */
+public class Frame {
+
+    /**
+     * The number of bytes used for the length prefix of each field.
+     */
+    public static final int LENGTH_BYTES = 4;
+
+    private final ByteArrayOutputStream bytes = new ByteArrayOutputStream();
+
+    /**
+     * Adds a field to the frame.
+     *
+     * @param field The field. This may be empty. A null field is added as empty.
+     * @return This instance, so calls can be chained.
+     */
+    public Frame addField(byte[] field) {
+        byte[] value = field == null ? new byte[0] : field;
+        bytes.write(ByteBuffer.allocate(LENGTH_BYTES).putInt(value.length).array(), 0, LENGTH_BYTES);
+        bytes.write(value, 0, value.length);
+        return this;
+    }
+
+    /**
+     * @return The length-prefixed fields added so far.
+     */
+    public byte[] toByteArray() {
+        return bytes.toByteArray();
+    }
+}
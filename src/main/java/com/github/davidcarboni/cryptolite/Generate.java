@@ -1,7 +1,19 @@
 package com.github.davidcarboni.cryptolite;
 
+import org.apache.commons.lang.StringUtils;
+
+import javax.crypto.Mac;
+import javax.crypto.SecretKey;
+import javax.crypto.spec.SecretKeySpec;
+import java.io.InputStream;
+import java.math.BigInteger;
+import java.nio.ByteBuffer;
+import java.security.InvalidKeyException;
 import java.security.NoSuchAlgorithmException;
 import java.security.SecureRandom;
+import java.util.Arrays;
+import java.util.LinkedHashSet;
+import java.util.Set;
 
 /**
  * Generates things that need to be random,
@@ -26,12 +38,61 @@ public class Generate {
      */
     public static final String ALGORITHM = "SHA1PRNG";
 
+    /**
+     * The number of characters in each group of a recovery code.
+     */
+    public static final int RECOVERY_CODE_GROUP = 4;
+
+    /**
+     * The number of times to try generating random bytes before giving up.
+     */
+    public static final int RANDOM_ATTEMPTS = 5;
+
+    /**
+     * The HMAC algorithm used by {@link #rollingCode(byte[], long, int)}, as specified for HOTP (RFC 4226).
+     */
+    public static final String ROLLING_CODE_ALGORITHM = "HmacSHA1";
+
+    /**
+     * The maximum number of random bytes to generate at a time when building long values.
+     */
+    private static final int CHUNK_BYTES = 4096;
+
+    /**
+     * The number of random bits in a value returned by {@link #randomDouble()}.
+     */
+    private static final int DOUBLE_BITS = 53;
+
+    /**
+     * The number of random bytes buffered by {@link #fastByteArray(int)}.
+     */
+    public static final int FAST_BUFFER_BYTES = 4096;
+
+    // Buffered random bytes for fastByteArray, consumed from the end:
+    private static final byte[] fastBuffer = new byte[FAST_BUFFER_BYTES];
+    private static int fastRemaining;
+
     // Work out the right number of bytes for random tokens:
     private static final int tokenLengthBytes = TOKEN_BITS / 8;
 
     // Characters for pasword generation:
     private static final String passwordCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789";
 
+    // Characters for recovery codes, excluding easily confused characters (0/O, 1/I/L):
+    private static final String recoveryCodeCharacters = "ABCDEFGHJKMNPQRSTUVWXYZ23456789";
+
+    /**
+     * The number of characters {@link #password(int)} selects from.
+     * Use this with {@link #passwordEntropyBits(int, int)}.
+     */
+    public static final int PASSWORD_ALPHABET_SIZE = passwordCharacters.length();
+
+    /**
+     * The number of characters {@link #recoveryCodes(int, int)} selects from.
+     * Use this with {@link #passwordEntropyBits(int, int)}.
+     */
+    public static final int RECOVERY_CODE_ALPHABET_SIZE = recoveryCodeCharacters.length();
+
     /**
      * A {@link SecureRandom} instance for the algorithm {@value #ALGORITHM}.
      * <p>
@@ -67,9 +128,103 @@ public class Generate {
      * @return {@link SecureRandom#nextBytes(byte[])}
      */
     public static byte[] byteArray(int length) {
+        return byteArray(length, secureRandom);
+    }
+
+    /**
+     * Instantiates and populates a byte array of the specified length from a buffer of random bytes.
+     * <p>
+     * {@link #byteArray(int)} calls {@link SecureRandom} every time, which dominates the cost of
+     * frequent small values such as nonces. This method instead reads {@value #FAST_BUFFER_BYTES}
+     * bytes at a time and hands them out in pieces, zeroing each piece in the buffer once it's used.
+     * <p>
+     * Use this for values that are public once used, such as nonces and IVs. Use {@link #byteArray(int)}
+     * for keys and other secrets, so that they are never held in a long-lived buffer.
+     *
+     * @param length The length of the array. Lengths greater than {@value #FAST_BUFFER_BYTES}
+     *               are passed straight to {@link #byteArray(int)}.
+     * @return A byte array of the given length, fully populated.
+     */
+    public static byte[] fastByteArray(int length) {
+
+        if (length > FAST_BUFFER_BYTES) {
+            return byteArray(length);
+        }
+
+        byte[] result = new byte[length];
+        synchronized (fastBuffer) {
+            if (fastRemaining < length) {
+                byte[] refill = byteArray(FAST_BUFFER_BYTES);
+                System.arraycopy(refill, 0, fastBuffer, 0, FAST_BUFFER_BYTES);
+                ByteArray.zeroize(refill);
+                fastRemaining = FAST_BUFFER_BYTES;
+            }
+            fastRemaining -= length;
+            System.arraycopy(fastBuffer, fastRemaining, result, 0, length);
+            Arrays.fill(fastBuffer, fastRemaining, fastRemaining + length, (byte) 0);
+        }
+        return result;
+    }
+
+    /**
+     * Provides an endless stream of random bytes.
+     * <p>
+     * This is useful for tools that need a lot of random data, such as wiping files or fuzzing.
+     * For example, <code>IOUtils.copyLarge(Generate.randomInputStream(), out, 0, n)</code> writes
+     * <code>n</code> random bytes. Bulk reads are filled directly from {@link SecureRandom} and
+     * single-byte reads are served from a buffer of up to {@value #CHUNK_BYTES} bytes.
+     * <p>
+     * The returned stream is not thread-safe.
+     *
+     * @return An {@link InputStream} that never reaches the end of the stream.
+     */
+    public static InputStream randomInputStream() {
+        return new InputStream() {
+            private byte[] buffer = new byte[0];
+            private int position;
+
+            @Override
+            public int read() {
+                if (position == buffer.length) {
+                    buffer = byteArray(CHUNK_BYTES);
+                    position = 0;
+                }
+                return buffer[position++] & 0xff;
+            }
+
+            @Override
+            public int read(byte[] b, int off, int len) {
+                int count = Math.min(len, CHUNK_BYTES);
+                System.arraycopy(byteArray(count), 0, b, off, count);
+                return count;
+            }
+        };
+    }
+
+    /**
+     * Populates a byte array from the given source, retrying if the source fails.
+     * <p>
+     * On some platforms, the underlying source of randomness can fail transiently
+     * (for example, if reading from the operating system is interrupted). Rather than failing
+     * key generation outright, this retries up to {@value #RANDOM_ATTEMPTS} times.
+     *
+     * @param length The length of the array.
+     * @param random The source of randomness.
+     * @return A byte array of the given length, fully populated.
+     * @throws IllegalStateException If the source fails on every attempt.
+     */
+    static byte[] byteArray(int length, SecureRandom random) {
         byte[] bytes = new byte[length];
-        secureRandom.nextBytes(bytes);
-        return bytes;
+        RuntimeException failure = null;
+        for (int attempt = 0; attempt < RANDOM_ATTEMPTS; attempt++) {
+            try {
+                random.nextBytes(bytes);
+                return bytes;
+            } catch (RuntimeException e) {
+                failure = e;
+            }
+        }
+        throw new IllegalStateException("Unable to generate random bytes after " + RANDOM_ATTEMPTS + " attempts.", failure);
     }
 
     /**
@@ -82,6 +237,206 @@ public class Generate {
         return ByteArray.toHex(tokenBytes);
     }
 
+    /**
+     * Generates a random token in the given encoding.
+     *
+     * @param encoding The encoding to use for the token.
+     * @return A 256-bit (32 byte) random token, encoded as requested.
+     */
+    public static String token(TokenEncoding encoding) {
+        return encode(byteArray(tokenLengthBytes), encoding);
+    }
+
+    /**
+     * Re-encodes a token, for example to migrate hex tokens issued by {@link #token()} to the shorter
+     * {@link TokenEncoding#BASE64URL}, without having to issue new tokens.
+     * <p>
+     * The token is validated by checking that it re-encodes to the same value in its source encoding
+     * (ignoring case for {@link TokenEncoding#HEX} and {@link TokenEncoding#BASE32}), so a token that
+     * isn't in the source encoding is rejected rather than silently mangled.
+     *
+     * @param token The token.
+     * @param from  The encoding the token is in.
+     * @param to    The encoding to convert the token to.
+     * @return The same token bytes, in the requested encoding, or null if the token is null.
+     * @throws IllegalArgumentException If the token is empty or isn't valid in the source encoding.
+     */
+    public static String convertToken(String token, TokenEncoding from, TokenEncoding to) {
+
+        if (token == null) {
+            return null;
+        }
+
+        byte[] tokenBytes;
+        switch (from) {
+            case BASE64URL:
+                tokenBytes = ByteArray.fromBase64Url(token);
+                break;
+            case BASE32:
+                tokenBytes = ByteArray.fromBase32(token);
+                break;
+            default:
+                tokenBytes = ByteArray.fromHex(token);
+        }
+
+        String canonical = encode(tokenBytes, from);
+        boolean valid = from == TokenEncoding.BASE64URL ?
+                canonical.equals(StringUtils.stripEnd(token, "=")) :
+                canonical.equalsIgnoreCase(StringUtils.stripEnd(token, "="));
+        if (tokenBytes.length == 0 || !valid) {
+            throw new IllegalArgumentException("Are you sure this is a " + from + " token? " + token);
+        }
+        return encode(tokenBytes, to);
+    }
+
+    /**
+     * @param tokenBytes The token bytes.
+     * @param encoding   The encoding to use.
+     * @return The token bytes, encoded as requested.
+     */
+    private static String encode(byte[] tokenBytes, TokenEncoding encoding) {
+        switch (encoding) {
+            case BASE64URL:
+                return ByteArray.toBase64Url(tokenBytes);
+            case BASE32:
+                return ByteArray.toBase32(tokenBytes);
+            default:
+                return ByteArray.toHex(tokenBytes);
+        }
+    }
+
+    /**
+     * Generates a deterministic token for the given input, which is unguessable without the secret.
+     * <p>
+     * Unlike {@link #token()}, the same secret and input always produce the same token. This is useful
+     * for idempotency or deduplication keys, where a retried request needs to produce the same key.
+     * The token is an HMAC (see {@link HashMac}) of the input.
+     *
+     * @param secret A server-side secret. Anyone who knows this can generate tokens.
+     * @param input  The input to generate a token for.
+     * @return A 256-bit (32 byte) token as a hexadecimal string.
+     */
+    public static String deterministicToken(SecretKey secret, String input) {
+        return new HashMac(secret).digest(input);
+    }
+
+    /**
+     * Generates the rolling code for the given counter, as used by devices such as garage door openers
+     * and OTP hardware tokens that share a key and advance a counter.
+     * <p>
+     * This is HOTP, as defined in RFC 4226: an {@value #ROLLING_CODE_ALGORITHM} of the counter (as a big-endian
+     * 8-byte value), dynamically truncated to 31 bits and reduced to the requested number of decimal digits.
+     * The same key and counter always produce the same code, so the codes are compatible with other HOTP
+     * implementations.
+     *
+     * @param key     The shared key.
+     * @param counter The counter value.
+     * @param digits  The number of digits in the code, from 6 to 9.
+     * @return The code, zero-padded to the requested number of digits.
+     * @throws IllegalArgumentException If the key is null or empty, or the number of digits is out of range.
+     */
+    public static String rollingCode(byte[] key, long counter, int digits) {
+
+        if (key == null || key.length == 0) {
+            throw new IllegalArgumentException("Please provide a key for the rolling code.");
+        }
+        if (digits < 6 || digits > 9) {
+            throw new IllegalArgumentException("Rolling codes must have between 6 and 9 digits, but got " + digits);
+        }
+
+        Mac mac;
+        try {
+            mac = Mac.getInstance(ROLLING_CODE_ALGORITHM);
+            mac.init(new SecretKeySpec(key, ROLLING_CODE_ALGORITHM));
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return rollingCode(key, counter, digits);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + ROLLING_CODE_ALGORITHM, e);
+            }
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Error initialising " + ROLLING_CODE_ALGORITHM + " - invalid key", e);
+        }
+        byte[] hash = mac.doFinal(ByteBuffer.allocate(8).putLong(counter).array());
+
+        // Dynamic truncation (RFC 4226 section 5.3):
+        int offset = hash[hash.length - 1] & 0x0f;
+        int binary = ByteBuffer.wrap(hash, offset, 4).getInt() & 0x7fffffff;
+        int code = binary % (int) Math.pow(10, digits);
+        return StringUtils.leftPad(String.valueOf(code), digits, '0');
+    }
+
+    /**
+     * Generates a random double, uniformly distributed in the range [0, 1).
+     * <p>
+     * This is for simulations and sampling that must not be predictable. It draws 53 random bits
+     * (the precision of a double) with {@link #byteArray(int)} and divides by 2<sup>53</sup>, so every
+     * possible value is equally likely. It's much slower than {@link java.util.Random#nextDouble()},
+     * so only use it where unpredictability matters.
+     *
+     * @return A random value greater than or equal to 0 and less than 1.
+     */
+    public static double randomDouble() {
+        long bits = ByteBuffer.wrap(byteArray(8)).getLong() >>> (64 - DOUBLE_BITS);
+        return bits / (double) (1L << DOUBLE_BITS);
+    }
+
+    /**
+     * Generates a random integer, uniformly distributed in the range [0, max), for example a value
+     * below a modulus when building a higher-level protocol.
+     * <p>
+     * Candidates with the same bit length as max are drawn and any that are too large are rejected,
+     * so there's no modulo bias. On average, fewer than two draws are needed.
+     *
+     * @param max The upper bound (exclusive). This must be positive.
+     * @return A random value greater than or equal to 0 and less than max.
+     * @throws IllegalArgumentException If max is null, zero or negative.
+     */
+    public static BigInteger randomBigInteger(BigInteger max) {
+
+        if (max == null || max.signum() <= 0) {
+            throw new IllegalArgumentException("The upper bound must be positive, but got " + max);
+        }
+
+        BigInteger result;
+        do {
+            result = new BigInteger(max.bitLength(), secureRandom);
+        } while (result.compareTo(max) >= 0);
+        return result;
+    }
+
+    /**
+     * Selects k distinct indices at random from the range [0, n), for example to pick
+     * 3 security questions out of 10.
+     * <p>
+     * This uses a partial Fisher-Yates shuffle, so every subset (and every order) is equally likely.
+     *
+     * @param n The size of the range to select from.
+     * @param k The number of indices to select.
+     * @return An array of k distinct indices, in random order.
+     * @throws IllegalArgumentException If k is greater than n, or either is negative.
+     */
+    public static int[] sample(int n, int k) {
+
+        if (n < 0 || k < 0 || k > n) {
+            throw new IllegalArgumentException("Unable to select " + k + " distinct indices from " + n + ".");
+        }
+
+        int[] indices = new int[n];
+        for (int i = 0; i < n; i++) {
+            indices[i] = i;
+        }
+
+        // Only the first k positions need to be shuffled:
+        for (int i = 0; i < k; i++) {
+            int j = i + secureRandom.nextInt(n - i);
+            int swap = indices[i];
+            indices[i] = indices[j];
+            indices[j] = swap;
+        }
+        return Arrays.copyOf(indices, k);
+    }
+
     /**
      * Generates a random password.
      *
@@ -89,22 +444,229 @@ public class Generate {
      * @return A password of the specified length, selected from {@link #passwordCharacters}.
      */
     public static String password(int length) {
-        StringBuilder result = new StringBuilder();
+        return password(length, passwordCharacters);
+    }
+
+    /**
+     * Generates a random password, using characters from the given alphabet.
+     * <p>
+     * Random bytes are generated in chunks, so this is
+     * suitable for generating very long strings (e.g. for load testing) without
+     * allocating a second buffer of the same length.
+     *
+     * @param length   The length of the password to be returned.
+     * @param alphabet The characters to select from. Each character is equally likely to be selected,
+     *                 so repeating a character makes it more likely to appear.
+     * @return A password of the specified length, selected from the given alphabet.
+     */
+    public static String password(int length, String alphabet) {
+        if (StringUtils.isEmpty(alphabet)) {
+            throw new IllegalArgumentException("Please provide at least one character to generate a password from.");
+        }
+
+        StringBuilder result = new StringBuilder(Math.max(length, 0));
 
-        byte[] values = byteArray(length);
         // We use a modulus of an increasing index rather than of the byte values
         // to avoid certain characters coming up more often.
         int index = 0;
+        int remaining = length;
 
-        for (int i = 0; i < length; i++) {
-            index += (values[i] & 0xff);
-            index = index % passwordCharacters.length();
-            result.append(passwordCharacters.charAt(index));
+        while (remaining > 0) {
+            byte[] values = byteArray(Math.min(remaining, CHUNK_BYTES));
+            for (byte value : values) {
+                index += (value & 0xff);
+                index = index % alphabet.length();
+                result.append(alphabet.charAt(index));
+            }
+            remaining -= values.length;
         }
 
         return result.toString();
     }
 
+    /**
+     * Generates many random passwords at once, for example to seed test accounts.
+     * <p>
+     * This is more efficient than calling {@link #password(int)} in a loop, because the random bytes for all
+     * the passwords are drawn in a single buffer. Characters are selected as in {@link #password(int, String)},
+     * starting afresh for each password so that each one is independent of the one before.
+     *
+     * @param count  The number of passwords to generate.
+     * @param length The length of each password.
+     * @return An array of passwords of the specified length, selected from {@link #passwordCharacters}.
+     * @throws IllegalArgumentException If the count or length is negative, or the total number of characters is too large.
+     */
+    public static String[] passwords(int count, int length) {
+        return passwords(count, length, passwordCharacters);
+    }
+
+    /**
+     * Generates many random passwords at once, using characters from the given alphabet.
+     *
+     * @param count    The number of passwords to generate.
+     * @param length   The length of each password.
+     * @param alphabet The characters to select from.
+     * @return An array of passwords of the specified length, selected from the given alphabet.
+     * @throws IllegalArgumentException If the count or length is negative, or the total number of characters is too large.
+     * @see #passwords(int, int)
+     */
+    public static String[] passwords(int count, int length, String alphabet) {
+        if (count < 0 || length < 0) {
+            throw new IllegalArgumentException("Unable to generate " + count + " passwords of length " + length + ".");
+        }
+        long total = (long) count * length;
+        if (total > Integer.MAX_VALUE) {
+            throw new IllegalArgumentException("Unable to generate " + total + " characters in one go. Please generate fewer passwords at a time.");
+        }
+
+        if (StringUtils.isEmpty(alphabet)) {
+            throw new IllegalArgumentException("Please provide at least one character to generate a password from.");
+        }
+
+        byte[] values = byteArray((int) total);
+        String[] result = new String[count];
+        char[] password = new char[length];
+        int offset = 0;
+        for (int i = 0; i < count; i++) {
+            // Reset the index so that each password is independent of the previous one:
+            int index = 0;
+            for (int j = 0; j < length; j++) {
+                index += (values[offset++] & 0xff);
+                index = index % alphabet.length();
+                password[j] = alphabet.charAt(index);
+            }
+            result[i] = new String(password);
+        }
+        ByteArray.zeroize(values);
+        ByteArray.zeroize(password);
+        return result;
+    }
+
+    /**
+     * Generates a random password with no runs of three or more repeated or sequential characters,
+     * such as "aaa", "abc" or "321", for password policies that forbid them.
+     * <p>
+     * Each character is selected at random from the alphabet and, if it would complete a forbidden run,
+     * it's discarded and selected again. This slightly reduces the entropy compared to {@link #password(int, String)}.
+     *
+     * @param length   The length of the password to be returned.
+     * @param alphabet The characters to select from.
+     * @return A password of the specified length, selected from the given alphabet.
+     * @throws IllegalArgumentException If the alphabet can't produce a password of this length without a run,
+     *                                  which is the case if it contains fewer than two distinct characters.
+     */
+    public static String passwordNoRuns(int length, String alphabet) {
+        if (StringUtils.isEmpty(alphabet)) {
+            throw new IllegalArgumentException("Please provide at least one character to generate a password from.");
+        }
+
+        // At most one character is forbidden at each position, so two distinct characters are always enough:
+        boolean oneCharacter = StringUtils.containsOnly(alphabet, alphabet.substring(0, 1));
+        if (length > 2 && oneCharacter) {
+            throw new IllegalArgumentException("Please provide at least two distinct characters to generate a password of "
+                    + length + " characters without runs.");
+        }
+
+        StringBuilder result = new StringBuilder(Math.max(length, 0));
+        while (result.length() < length) {
+            char c = alphabet.charAt(secureRandom.nextInt(alphabet.length()));
+            if (!completesRun(result, c)) {
+                result.append(c);
+            }
+        }
+        return result.toString();
+    }
+
+    /**
+     * @param password The password so far.
+     * @param c        The next character.
+     * @return If appending the character would make a run of three repeated or sequential characters, true.
+     */
+    static boolean completesRun(CharSequence password, char c) {
+        int length = password.length();
+        if (length < 2) {
+            return false;
+        }
+        int step = c - password.charAt(length - 1);
+        return Math.abs(step) <= 1 && password.charAt(length - 1) - password.charAt(length - 2) == step;
+    }
+
+    /**
+     * Generates a short, random code in Crockford's base-32, such as a verification code sent by SMS or email.
+     * <p>
+     * Crockford's base-32 avoids easily confused characters and, when decoded with
+     * {@link ByteArray#fromCrockford(String)}, is case-insensitive and ignores hyphens, so
+     * it's friendlier than hex for people to type in. Each character carries 5 bits of entropy.
+     *
+     * @param length The number of characters in the code.
+     * @return A random code of the given length.
+     */
+    public static String shortCode(int length) {
+
+        if (length < 1) {
+            throw new IllegalArgumentException("Please specify a length of at least 1.");
+        }
+
+        // The alphabet has 32 characters, so the low 5 bits of each byte select one without bias:
+        StringBuilder result = new StringBuilder(length);
+        for (byte value : byteArray(length)) {
+            result.append(ByteArray.CROCKFORD.charAt(value & 0x1f));
+        }
+        return result.toString();
+    }
+
+    /**
+     * Calculates the entropy of a randomly generated password.
+     * <p>
+     * This is useful for displaying or logging the strength of a generated secret, for example
+     * to justify it in an audit. It assumes each character is chosen uniformly at random, as is the
+     * case for {@link #password(int)} ({@link #PASSWORD_ALPHABET_SIZE} characters) and
+     * {@link #recoveryCodes(int, int)} ({@link #RECOVERY_CODE_ALPHABET_SIZE} characters).
+     * It does not apply to passwords chosen by people.
+     *
+     * @param length       The length of the password.
+     * @param alphabetSize The number of distinct characters the password is selected from.
+     * @return The entropy, in bits: <code>length * log2(alphabetSize)</code>.
+     */
+    public static double passwordEntropyBits(int length, int alphabetSize) {
+        if (length < 0 || alphabetSize < 1) {
+            throw new IllegalArgumentException("Please specify a non-negative length and an alphabet size of at least 1.");
+        }
+        return length * (Math.log(alphabetSize) / Math.log(2));
+    }
+
+    /**
+     * Generates a set of one-time recovery codes, for example for account recovery.
+     * <p>
+     * Codes are generated from an alphabet that excludes easily confused characters
+     * and are formatted in hyphen-separated groups of {@value #RECOVERY_CODE_GROUP}
+     * (e.g. <code>XXXX-XXXX</code>) to make them easier to read and type.
+     * Each code is generated independently and no code is repeated within the set.
+     *
+     * @param count  The number of codes to generate.
+     * @param length The number of characters in each code, not including hyphens.
+     * @return An array of distinct recovery codes.
+     */
+    public static String[] recoveryCodes(int count, int length) {
+        if (count < 0 || length < 1) {
+            throw new IllegalArgumentException("Please specify a non-negative count and a length of at least 1.");
+        }
+
+        Set<String> codes = new LinkedHashSet<>();
+        while (codes.size() < count) {
+            String code = password(length, recoveryCodeCharacters);
+            StringBuilder formatted = new StringBuilder(length + length / RECOVERY_CODE_GROUP);
+            for (int i = 0; i < code.length(); i++) {
+                if (i > 0 && i % RECOVERY_CODE_GROUP == 0) {
+                    formatted.append('-');
+                }
+                formatted.append(code.charAt(i));
+            }
+            codes.add(formatted.toString());
+        }
+        return codes.toArray(new String[codes.size()]);
+    }
+
     /**
      * Generates a random salt value.
      * <p>
@@ -116,8 +678,19 @@ public class Generate {
      * string (for easy storage).
      */
     public static String salt() {
-        byte[] salt = byteArray(SALT_BYTES);
-        return ByteArray.toBase64(salt);
+        return ByteArray.toBase64(saltBytes());
+    }
+
+    /**
+     * Generates a random salt value as raw bytes.
+     * <p>
+     * This is useful if you're passing the salt straight to a key derivation function,
+     * because it avoids encoding it as base64 only to decode it again.
+     *
+     * @return A random salt value of {@value #SALT_BYTES} bytes.
+     */
+    public static byte[] saltBytes() {
+        return byteArray(SALT_BYTES);
     }
 
 
@@ -3,8 +3,12 @@ package com.github.davidcarboni.cryptolite;
 import javax.crypto.Mac;
 import javax.crypto.SecretKey;
 import javax.crypto.spec.SecretKeySpec;
+import java.io.IOException;
+import java.io.InputStream;
 import java.security.InvalidKeyException;
+import java.security.MessageDigest;
 import java.security.NoSuchAlgorithmException;
+import java.util.Locale;
 
 /**
  * Provides a simple way to generate a Hash MAC (HMAC) using {@value #ALGORITHM}.
@@ -18,6 +22,11 @@ public class HashMac {
      */
     public static final String ALGORITHM = "HmacSHA256";
 
+    /**
+     * The number of bytes read at a time by {@link #digest(InputStream)}.
+     */
+    private static final int BUFFER_SIZE = 8192;
+
     private byte[] key;
 
     private String algorithm;
@@ -64,13 +73,83 @@ public class HashMac {
      * @return The HMAC value for the message and key.
      */
     public String digest(String message) {
+        return ByteArray.toHex(digest(ByteArray.fromString(message)));
+    }
+
+    /**
+     * Derives a stable, non-reversible identifier for the given value, such as an email address,
+     * so you can index or pseudonymise it without storing the value itself.
+     * <p>
+     * The same key and value always produce the same identifier, and it can't be reversed (or brute-forced
+     * from a list of likely values) without the key. This is deliberately unlike password hashing (see
+     * {@link Password}): there's no per-value salt, so lookups work. Values are used exactly as given, so
+     * normalise them first if you want variants to match (for example, trim and lower-case email addresses).
+     *
+     * @param value The value to derive an identifier for.
+     * @return The {@value #ALGORITHM} of the value as lower-case base-32, without padding,
+     * or null if the value is null.
+     */
+    public String identifier(String value) {
+        if (value == null) {
+            return null;
+        }
+        return ByteArray.toBase32(digest(ByteArray.fromString(value))).toLowerCase(Locale.ROOT);
+    }
+
+    /**
+     * Computes an HMAC for the given bytes, using the key passed to the constructor.
+     *
+     * @param message The message.
+     * @return The HMAC value for the message and key.
+     */
+    public byte[] digest(byte[] message) {
+        return newMac().doFinal(message);
+    }
+
+    /**
+     * Computes an HMAC for the given stream, using the key passed to the constructor.
+     * <p>
+     * The stream is processed in chunks, so this is suitable for large files.
+     *
+     * @param message The message. This is read to the end, but not closed.
+     * @return The HMAC value for the message and key.
+     * @throws IOException If an error occurs reading the stream.
+     */
+    public byte[] digest(InputStream message) throws IOException {
+
+        Mac mac = newMac();
+        byte[] buffer = new byte[BUFFER_SIZE];
+        int read;
+        while ((read = message.read(buffer)) != -1) {
+            mac.update(buffer, 0, read);
+        }
+        return mac.doFinal();
+    }
+
+    /**
+     * Verifies an HMAC for the given stream, using the key passed to the constructor.
+     * <p>
+     * The comparison takes constant time, so it doesn't leak how much of the HMAC matched.
+     *
+     * @param message The message. This is read to the end, but not closed.
+     * @param hmac    The expected HMAC value, as returned by {@link #digest(InputStream)}.
+     * @return If the HMAC matches, true, otherwise false.
+     * @throws IOException If an error occurs reading the stream.
+     */
+    public boolean verify(InputStream message, byte[] hmac) throws IOException {
+        return hmac != null && MessageDigest.isEqual(digest(message), hmac);
+    }
+
+    /**
+     * @return A {@link Mac}, initialised with the key passed to the constructor.
+     */
+    private Mac newMac() {
 
         try {
             Mac mac = Mac.getInstance(algorithm);
             SecretKeySpec macKey = new SecretKeySpec(key, algorithm);
             mac.init(macKey);
-            byte[] digest = mac.doFinal(ByteArray.fromString(message));
-            return ByteArray.toHex(digest);
+            return mac;
         } catch (NoSuchAlgorithmException e) {
             throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
         } catch (InvalidKeyException e) {
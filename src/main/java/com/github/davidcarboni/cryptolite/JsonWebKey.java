@@ -0,0 +1,242 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.apache.commons.lang.StringUtils;
+
+import java.math.BigInteger;
+import java.security.AlgorithmParameters;
+import java.security.KeyFactory;
+import java.security.NoSuchAlgorithmException;
+import java.security.PublicKey;
+import java.security.interfaces.ECPublicKey;
+import java.security.interfaces.RSAPublicKey;
+import java.security.spec.ECGenParameterSpec;
+import java.security.spec.ECParameterSpec;
+import java.security.spec.ECPoint;
+import java.security.spec.ECPublicKeySpec;
+import java.security.spec.InvalidKeySpecException;
+import java.security.spec.InvalidParameterSpecException;
+import java.security.spec.KeySpec;
+import java.security.spec.RSAPublicKeySpec;
+import java.util.HashMap;
+import java.util.Map;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * This class converts public keys to and from JSON Web Key (JWK) format, as defined in RFC 7517.
+ * <p>
+ * This is useful if you need to publish keys to, or consume keys from, OIDC/JOSE tooling.
+ * RSA keys are represented with the standard <code>kty</code>, <code>n</code> and <code>e</code>
+ * fields and EC keys with <code>kty</code>, <code>crv</code>, <code>x</code> and <code>y</code>.
+ * The supported curves are P-256, P-384 and P-521.
+ * <p>
+ * Only the fields needed to reconstruct the key are read, so any others (e.g. <code>kid</code>
+ * or <code>use</code>) are ignored.
+ *
+ * @author David Carboni
+ */
+public class JsonWebKey {
+
+    /**
+     * The JWK key type for RSA keys.
+     */
+    public static final String KTY_RSA = "RSA";
+
+    /**
+     * The JWK key type for elliptic curve keys.
+     */
+    public static final String KTY_EC = "EC";
+
+    /**
+     * Matches a <code>"name": "value"</code> pair in a JSON object.
+     */
+    private static final Pattern FIELD = Pattern.compile("\"([^\"\\\\]*)\"\\s*:\\s*\"([^\"\\\\]*)\"");
+
+    /**
+     * Encodes the given {@link PublicKey} as a JWK.
+     *
+     * @param key An RSA or EC {@link PublicKey}.
+     * @return A JSON representation of the key, or null if the key is null.
+     * @throws IllegalArgumentException If the key is not an RSA or EC key, or uses an unsupported curve.
+     */
+    public static String encodePublicKey(PublicKey key) {
+
+        if (key == null) {
+            return null;
+        }
+
+        if (key instanceof RSAPublicKey) {
+            RSAPublicKey rsaKey = (RSAPublicKey) key;
+            return "{\"kty\":\"" + KTY_RSA + "\"," +
+                    "\"n\":\"" + encode(rsaKey.getModulus(), 0) + "\"," +
+                    "\"e\":\"" + encode(rsaKey.getPublicExponent(), 0) + "\"}";
+        } else if (key instanceof ECPublicKey) {
+            ECPublicKey ecKey = (ECPublicKey) key;
+            String crv = curveName(ecKey.getParams());
+            if (crv == null) {
+                throw new IllegalArgumentException("Unsupported curve for JWK. Only P-256, P-384 and P-521 are supported.");
+            }
+            int fieldSize = ecKey.getParams().getCurve().getField().getFieldSize();
+            int length = (fieldSize + 7) / 8;
+            ECPoint point = ecKey.getW();
+            return "{\"kty\":\"" + KTY_EC + "\"," +
+                    "\"crv\":\"" + crv + "\"," +
+                    "\"x\":\"" + encode(point.getAffineX(), length) + "\"," +
+                    "\"y\":\"" + encode(point.getAffineY(), length) + "\"}";
+        }
+
+        throw new IllegalArgumentException("Unsupported key type for JWK: " + key.getAlgorithm());
+    }
+
+    /**
+     * Decodes the given JWK to a {@link PublicKey}.
+     *
+     * @param jwk A JSON representation of an RSA or EC public key, as returned by {@link #encodePublicKey(PublicKey)}.
+     * @return The decoded {@link PublicKey}, or null if the given JWK is null.
+     * @throws IllegalArgumentException If the JWK is not a supported RSA or EC key.
+     */
+    public static PublicKey decodePublicKey(String jwk) {
+
+        if (jwk == null) {
+            return null;
+        }
+
+        Map<String, String> fields = parse(jwk);
+        String kty = fields.get("kty");
+
+        if (KTY_RSA.equals(kty)) {
+            KeySpec spec = new RSAPublicKeySpec(decode(fields, "n"), decode(fields, "e"));
+            return generatePublic("RSA", spec);
+        } else if (KTY_EC.equals(kty)) {
+            ECParameterSpec params = curve(fields.get("crv"));
+            ECPoint point = new ECPoint(decode(fields, "x"), decode(fields, "y"));
+            return generatePublic("EC", new ECPublicKeySpec(point, params));
+        }
+
+        throw new IllegalArgumentException("Unsupported JWK key type: " + kty);
+    }
+
+    /**
+     * Encodes the given value as unsigned, big-endian, URL-safe base64.
+     *
+     * @param value  The value to encode.
+     * @param length The number of bytes to left-pad the value to, or 0 for the minimum length.
+     * @return The encoded value.
+     */
+    private static String encode(BigInteger value, int length) {
+        byte[] bytes = value.toByteArray();
+
+        // Remove the sign byte, if present:
+        if (bytes.length > 1 && bytes[0] == 0) {
+            bytes = ByteArray.splitAt(bytes, 1)[1];
+        }
+
+        // Left-pad to the requested length:
+        if (bytes.length < length) {
+            bytes = ByteArray.concat(new byte[length - bytes.length], bytes);
+        }
+
+        return Base64.encodeBase64URLSafeString(bytes);
+    }
+
+    /**
+     * Decodes the named field as an unsigned, big-endian value.
+     *
+     * @param fields The fields of the JWK.
+     * @param name   The name of the field to decode.
+     * @return The decoded value.
+     */
+    private static BigInteger decode(Map<String, String> fields, String name) {
+        String value = fields.get(name);
+        if (StringUtils.isEmpty(value)) {
+            throw new IllegalArgumentException("JWK is missing the '" + name + "' field.");
+        }
+        return new BigInteger(1, Base64.decodeBase64(value));
+    }
+
+    /**
+     * Reads the string fields of a flat JSON object. This is sufficient for a JWK.
+     *
+     * @param json The JSON object.
+     * @return A map of field names to values.
+     */
+    private static Map<String, String> parse(String json) {
+        Map<String, String> result = new HashMap<>();
+        Matcher matcher = FIELD.matcher(json);
+        while (matcher.find()) {
+            result.put(matcher.group(1), matcher.group(2));
+        }
+        return result;
+    }
+
+    /**
+     * Identifies a curve by comparing all of its parameters, rather than just the field size,
+     * which other curves (such as secp256k1) share.
+     *
+     * @param params The parameters of an elliptic curve.
+     * @return The JWK name of the curve (P-256, P-384 or P-521), or null if it's not one of these.
+     */
+    static String curveName(ECParameterSpec params) {
+        for (String crv : new String[]{"P-256", "P-384", "P-521"}) {
+            ECParameterSpec candidate = curve(crv);
+            if (params.getCurve().equals(candidate.getCurve())
+                    && params.getGenerator().equals(candidate.getGenerator())
+                    && params.getOrder().equals(candidate.getOrder())
+                    && params.getCofactor() == candidate.getCofactor()) {
+                return crv;
+            }
+        }
+        return null;
+    }
+
+    /**
+     * @param crv The JWK name of a curve.
+     * @return The parameters for the curve.
+     */
+    static ECParameterSpec curve(String crv) {
+        String name;
+        if ("P-256".equals(crv)) {
+            name = "secp256r1";
+        } else if ("P-384".equals(crv)) {
+            name = "secp384r1";
+        } else if ("P-521".equals(crv)) {
+            name = "secp521r1";
+        } else {
+            throw new IllegalArgumentException("Unsupported JWK curve: " + crv);
+        }
+
+        try {
+            AlgorithmParameters parameters = AlgorithmParameters.getInstance("EC");
+            parameters.init(new ECGenParameterSpec(name));
+            return parameters.getParameterSpec(ECParameterSpec.class);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return curve(crv);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: EC", e);
+            }
+        } catch (InvalidParameterSpecException e) {
+            throw new IllegalStateException("Curve unavailable: " + name, e);
+        }
+    }
+
+    /**
+     * @param algorithm The key algorithm.
+     * @param spec      The key specification.
+     * @return The {@link PublicKey} for the given specification.
+     */
+    private static PublicKey generatePublic(String algorithm, KeySpec spec) {
+        try {
+            return KeyFactory.getInstance(algorithm).generatePublic(spec);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return generatePublic(algorithm, spec);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
+            }
+        } catch (InvalidKeySpecException e) {
+            throw new IllegalArgumentException("Unable to convert JWK to a valid " + algorithm + " public key.", e);
+        }
+    }
+}
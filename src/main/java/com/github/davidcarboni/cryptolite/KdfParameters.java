@@ -0,0 +1,108 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Cost parameters for the Argon2id key derivation function used by
+ * {@link Keys#generateSecretKeyFromPin(String, String, KdfParameters, AttemptRecorder)}.
+ * <p>
+ * The defaults are deliberately expensive, because a PIN has very little entropy.
+ * You'll want to measure how long derivation takes on your slowest target device
+ * and adjust from there.
+ *
+ * @author David Carboni
+ */
+public class KdfParameters {
+
+    /**
+     * The default memory cost, in kibibytes (256 MiB).
+     */
+    public static final int DEFAULT_MEMORY_KB = 262144;
+
+    /**
+     * The default number of iterations.
+     */
+    public static final int DEFAULT_ITERATIONS = 4;
+
+    /**
+     * The default degree of parallelism.
+     */
+    public static final int DEFAULT_PARALLELISM = 1;
+
+    /**
+     * The largest memory cost, in kibibytes (1 GiB), accepted by default when parameters are read from
+     * encrypted data (see {@link AuthenticatedCrypto#decryptWithPassword(byte[], String)}).
+     */
+    public static final int MAX_MEMORY_KB = 1048576;
+
+    /**
+     * The largest number of iterations accepted by default when parameters are read from encrypted data.
+     */
+    public static final int MAX_ITERATIONS = 32;
+
+    /**
+     * The largest degree of parallelism accepted by default when parameters are read from encrypted data.
+     */
+    public static final int MAX_PARALLELISM = 16;
+
+    private final int memoryKb;
+    private final int iterations;
+    private final int parallelism;
+
+    /**
+     * Initialises the instance with the default, high-cost parameters.
+     */
+    public KdfParameters() {
+        this(DEFAULT_MEMORY_KB, DEFAULT_ITERATIONS, DEFAULT_PARALLELISM);
+    }
+
+    /**
+     * @param memoryKb    The memory cost, in kibibytes.
+     * @param iterations  The number of iterations.
+     * @param parallelism The degree of parallelism.
+     */
+    public KdfParameters(int memoryKb, int iterations, int parallelism) {
+        if (memoryKb < 8 * parallelism || iterations < 1 || parallelism < 1) {
+            throw new IllegalArgumentException("Invalid KDF parameters. Iterations and parallelism must be at least 1 " +
+                    "and memory must be at least 8KB per degree of parallelism.");
+        }
+        this.memoryKb = memoryKb;
+        this.iterations = iterations;
+        this.parallelism = parallelism;
+    }
+
+    /**
+     * @return The default limits for parameters read from untrusted data: {@value #MAX_MEMORY_KB}KB of memory,
+     * {@value #MAX_ITERATIONS} iterations and a parallelism of {@value #MAX_PARALLELISM}.
+     */
+    public static KdfParameters maximum() {
+        return new KdfParameters(MAX_MEMORY_KB, MAX_ITERATIONS, MAX_PARALLELISM);
+    }
+
+    /**
+     * @param limits The largest acceptable value of each parameter.
+     * @return If none of these parameters exceeds the corresponding limit, true.
+     */
+    public boolean isWithin(KdfParameters limits) {
+        return memoryKb <= limits.memoryKb && iterations <= limits.iterations && parallelism <= limits.parallelism;
+    }
+
+    /**
+     * @return The memory cost, in kibibytes.
+     */
+    public int getMemoryKb() {
+        return memoryKb;
+    }
+
+    /**
+     * @return The number of iterations.
+     */
+    public int getIterations() {
+        return iterations;
+    }
+
+    /**
+     * @return The degree of parallelism.
+     */
+    public int getParallelism() {
+        return parallelism;
+    }
+}
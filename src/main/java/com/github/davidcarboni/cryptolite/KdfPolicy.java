@@ -0,0 +1,140 @@
+package com.github.davidcarboni.cryptolite;
+
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Collections;
+import java.util.List;
+import java.util.Locale;
+
+/**
+ * Minimum parameters for password-based key derivation, so an organisation can enforce them
+ * across its codebase (see {@link Keys#checkKdfPolicy(int, int, String)}).
+ * <p>
+ * Call {@link #check(int, int, String)} from a test or at startup, with the parameters your code
+ * actually uses, to catch weak configurations before they reach production.
+ *
+ * @author David Carboni
+ */
+public class KdfPolicy {
+
+    /**
+     * The default minimum number of PBKDF2 iterations.
+     */
+    public static final int DEFAULT_MIN_ITERATIONS = 100000;
+
+    /**
+     * The default minimum salt length, in bytes.
+     */
+    public static final int DEFAULT_MIN_SALT_BYTES = 16;
+
+    /**
+     * The digests that are disallowed by default. An algorithm is disallowed if its name contains
+     * one of these, ignoring case and hyphens.
+     */
+    public static final List<String> DEFAULT_DISALLOWED_DIGESTS = Collections.unmodifiableList(Arrays.asList("SHA1", "MD5"));
+
+    private final int minIterations;
+    private final int minSaltBytes;
+    private final List<String> disallowedDigests;
+
+    /**
+     * Initialises the instance with the default policy: at least {@value #DEFAULT_MIN_ITERATIONS} iterations,
+     * at least {@value #DEFAULT_MIN_SALT_BYTES} bytes of salt and no SHA-1 or MD5.
+     */
+    public KdfPolicy() {
+        this(DEFAULT_MIN_ITERATIONS, DEFAULT_MIN_SALT_BYTES, DEFAULT_DISALLOWED_DIGESTS);
+    }
+
+    /**
+     * @param minIterations     The minimum number of iterations. This must not be negative.
+     * @param minSaltBytes      The minimum salt length, in bytes. This must not be negative.
+     * @param disallowedDigests Digests that mustn't be used, such as "SHA1". This can be empty, but not null.
+     * @throws IllegalArgumentException If a minimum is negative, or the list of digests is, or contains, null.
+     */
+    public KdfPolicy(int minIterations, int minSaltBytes, List<String> disallowedDigests) {
+        if (minIterations < 0 || minSaltBytes < 0) {
+            throw new IllegalArgumentException("Minimum iterations and salt length can't be negative, but got "
+                    + minIterations + " and " + minSaltBytes);
+        }
+        if (disallowedDigests == null) {
+            throw new IllegalArgumentException("Please provide a list of disallowed digests. This can be empty.");
+        }
+        this.minIterations = minIterations;
+        this.minSaltBytes = minSaltBytes;
+        List<String> normalised = new ArrayList<>();
+        for (String digest : disallowedDigests) {
+            if (digest == null) {
+                throw new IllegalArgumentException("Disallowed digests can't contain null.");
+            }
+            normalised.add(normalise(digest));
+        }
+        this.disallowedDigests = Collections.unmodifiableList(normalised);
+    }
+
+    /**
+     * Checks the given key derivation parameters against this policy.
+     * <p>
+     * The iteration minimum doesn't apply to {@value Keys#ARGON2ID}, because its cost comes mainly from
+     * memory rather than iterations (see {@link KdfParameters}).
+     *
+     * @param iterations The number of iterations.
+     * @param saltBytes  The salt length, in bytes.
+     * @param algorithm  The key derivation algorithm, for example {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM}.
+     * @throws IllegalArgumentException If the parameters don't meet this policy. The message lists every problem found.
+     */
+    public void check(int iterations, int saltBytes, String algorithm) {
+
+        List<String> problems = new ArrayList<>();
+        String name = normalise(String.valueOf(algorithm));
+        for (String digest : disallowedDigests) {
+            if (name.contains(digest)) {
+                problems.add("algorithm " + algorithm + " uses a disallowed digest (" + digest + ")");
+            }
+        }
+        if (!Keys.ARGON2ID.equalsIgnoreCase(algorithm) && iterations < minIterations) {
+            problems.add(iterations + " iterations is below the minimum of " + minIterations);
+        }
+        if (saltBytes < minSaltBytes) {
+            problems.add(saltBytes + "-byte salt is below the minimum of " + minSaltBytes + " bytes");
+        }
+
+        if (!problems.isEmpty()) {
+            StringBuilder message = new StringBuilder("KDF parameters don't meet policy: ");
+            String separator = "";
+            for (String problem : problems) {
+                message.append(separator).append(problem);
+                separator = "; ";
+            }
+            throw new IllegalArgumentException(message.append('.').toString());
+        }
+    }
+
+    /**
+     * @return The minimum number of iterations.
+     */
+    public int getMinIterations() {
+        return minIterations;
+    }
+
+    /**
+     * @return The minimum salt length, in bytes.
+     */
+    public int getMinSaltBytes() {
+        return minSaltBytes;
+    }
+
+    /**
+     * @return The disallowed digests, upper-case and without hyphens.
+     */
+    public List<String> getDisallowedDigests() {
+        return disallowedDigests;
+    }
+
+    /**
+     * @param name An algorithm or digest name.
+     * @return The name, upper-case and without hyphens, so that "SHA-1" and "HmacSHA1" can be matched.
+     */
+    private static String normalise(String name) {
+        return name.toUpperCase(Locale.ROOT).replace("-", "");
+    }
+}
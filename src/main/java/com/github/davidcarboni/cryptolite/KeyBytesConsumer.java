@@ -0,0 +1,20 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Implement this interface to use the raw bytes of a key without keeping a copy
+ * (see {@link Keys#withKeyBytes(javax.crypto.SecretKey, KeyBytesConsumer)}).
+ * <p>
+ * This is useful for passing a key to an API that only accepts a byte array.
+ *
+ * @author David Carboni
+ */
+public interface KeyBytesConsumer {
+
+    /**
+     * Called with the raw key bytes. These are wiped as soon as this method returns,
+     * so don't keep a reference to them.
+     *
+     * @param keyBytes The raw key bytes.
+     */
+    void accept(byte[] keyBytes);
+}
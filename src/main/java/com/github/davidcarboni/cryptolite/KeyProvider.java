@@ -0,0 +1,21 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+
+/**
+ * Implement this interface to supply secret keys by identifier, for example from
+ * configuration, a secrets manager or a keyring.
+ * <p>
+ * Using an identifier (such as a version, "v1", "v2") means encrypted data can record which key
+ * was used, so keys can be rotated without losing access to existing data.
+ *
+ * @author David Carboni
+ */
+public interface KeyProvider {
+
+    /**
+     * @param id The key identifier.
+     * @return The key with the given identifier, or null if there is no such key.
+     */
+    SecretKey getKey(String id);
+}
@@ -3,15 +3,21 @@ package com.github.davidcarboni.cryptolite;
 import org.apache.commons.codec.binary.Base64;
 import org.apache.commons.lang.StringUtils;
 
+import javax.crypto.BadPaddingException;
 import javax.crypto.Cipher;
 import javax.crypto.IllegalBlockSizeException;
 import javax.crypto.NoSuchPaddingException;
 import javax.crypto.SecretKey;
+import javax.crypto.spec.SecretKeySpec;
 import java.io.Serializable;
+import java.nio.ByteBuffer;
 import java.nio.charset.StandardCharsets;
 import java.security.*;
 import java.security.spec.InvalidKeySpecException;
 import java.security.spec.X509EncodedKeySpec;
+import java.util.Arrays;
+import java.util.LinkedHashMap;
+import java.util.Map;
 
 /**
  * This class provides secure "wrapping" of keys. Wrapping a key is important if
@@ -70,6 +76,41 @@ public class KeyWrapper implements Serializable {
      */
     private static final String WRAP_ALGORITHM_ASYMMETRIC = "AES/ECB/PKCS7Padding";
 
+    /**
+     * Wrapped keys are always a whole number of blocks of this size, in bytes.
+     */
+    private static final int WRAP_BLOCK_SIZE = 8;
+
+    /**
+     * The smallest possible wrapped key, in bytes: a 128-bit key plus the 8-byte integrity check value.
+     */
+    private static final int MIN_WRAPPED_SIZE = 24;
+
+    /**
+     * The raw AES block cipher used to implement RFC 3394 key wrapping: {@value #AES_BLOCK_ALGORITHM}.
+     */
+    private static final String AES_BLOCK_ALGORITHM = "AES/ECB/NoPadding";
+
+    /**
+     * The default initial value defined by RFC 3394.
+     */
+    private static final byte[] AES_KEY_WRAP_IV = ByteArray.fromHex("a6a6a6a6a6a6a6a6");
+
+    /**
+     * The constant part of the alternative initial value defined by RFC 5649.
+     */
+    private static final byte[] AES_KEY_WRAP_PAD_IV = ByteArray.fromHex("a65959a6");
+
+    /**
+     * The size, in bits, of a password-based wrap key after {@link Keys#useStrongKeys()}.
+     */
+    private static final int STRONG_WRAP_KEY_SIZE = 256;
+
+    /**
+     * The size, in bits, of a password-based wrap key after {@link Keys#useStandardKeys()}.
+     */
+    private static final int STANDARD_WRAP_KEY_SIZE = 128;
+
     /**
      * Start marker for encoding a public key
      */
@@ -252,6 +293,28 @@ public class KeyWrapper implements Serializable {
         }
     }
 
+    /**
+     * Checks whether the given value looks like the output of {@link #wrapSecretKey(SecretKey)} or
+     * {@link #wrapPrivateKey(PrivateKey)}, without needing the password or attempting to unwrap it.
+     * <p>
+     * This checks the value is base-64 encoded and that the decoded length is consistent with a
+     * wrapped key (a whole number of {@value #WRAP_BLOCK_SIZE}-byte blocks, at least
+     * {@value #MIN_WRAPPED_SIZE} bytes). It can't tell whether the key inside is valid, but it does
+     * help distinguish a corrupt value from a wrong password when diagnosing problems.
+     *
+     * @param wrappedKey The value to check.
+     * @return If the value is structurally valid as a wrapped key, true.
+     */
+    public static boolean isWrapped(String wrappedKey) {
+
+        if (StringUtils.isBlank(wrappedKey) || !Base64.isBase64(wrappedKey)) {
+            return false;
+        }
+
+        byte[] bytes = ByteArray.fromBase64(wrappedKey);
+        return bytes.length >= MIN_WRAPPED_SIZE && bytes.length % WRAP_BLOCK_SIZE == 0;
+    }
+
     /**
      * Convenience method to unwrap a public-private key pain in a single call.
      *
@@ -268,6 +331,329 @@ public class KeyWrapper implements Serializable {
         return new KeyPair(publicKey, privateKey);
     }
 
+    /**
+     * Re-wraps a set of secret keys, wrapped by this instance, under a new wrap key.
+     * <p>
+     * This is what you need when the password (or wrap key) behind your stored keys changes.
+     * It's all-or-nothing: every key is unwrapped before any are re-wrapped, so if any key
+     * fails to unwrap an exception is thrown and nothing is returned. The given map is not modified,
+     * so you can store the result in a single transaction.
+     *
+     * @param wrappedKeys The wrapped keys, as returned by {@link #wrapSecretKey(SecretKey)}, keyed by any identifier.
+     * @param newWrapper  A {@link KeyWrapper} initialised with the new password or wrap key.
+     * @return The keys, wrapped by the new wrapper, with the same identifiers.
+     * @throws IllegalArgumentException If any of the keys can't be unwrapped by this instance.
+     */
+    public Map<String, String> rewrapSecretKeys(Map<String, String> wrappedKeys, KeyWrapper newWrapper) {
+
+        // Unwrap everything first, so a failure leaves nothing half-done:
+        Map<String, SecretKey> keys = new LinkedHashMap<>();
+        for (Map.Entry<String, String> entry : wrappedKeys.entrySet()) {
+            try {
+                keys.put(entry.getKey(), unwrapSecretKey(entry.getValue()));
+            } catch (RuntimeException e) {
+                throw new IllegalArgumentException("Unable to unwrap key " + entry.getKey()
+                        + ". No keys have been re-wrapped.", e);
+            }
+        }
+
+        Map<String, String> result = new LinkedHashMap<>();
+        for (Map.Entry<String, SecretKey> entry : keys.entrySet()) {
+            result.put(entry.getKey(), newWrapper.wrapSecretKey(entry.getValue()));
+        }
+        return result;
+    }
+
+    /**
+     * Wraps the given key twice: once with a password and once with a recovery token, so that either can
+     * unwrap it independently with {@link #unwrapDual(String, String)}.
+     * <p>
+     * This supports password-forgotten flows: if a user forgets their password, the recovery token (for example,
+     * one generated by {@link Generate#token()} and given to the user to keep safe) can still recover the key,
+     * which can then be wrapped with a new password. Each wrapped key includes its own random salt,
+     * packed with {@link Crypto#packStored(byte[], byte[])}, so there's nothing else to store.
+     *
+     * @param key           The key to be wrapped.
+     * @param password      The user's password.
+     * @param recoveryToken The recovery token.
+     * @return A two-element array containing the key wrapped with the password, followed by the key wrapped
+     * with the recovery token.
+     */
+    public static String[] wrapDual(SecretKey key, String password, String recoveryToken) {
+        return new String[]{wrapWithSalt(key, password), wrapWithSalt(key, recoveryToken)};
+    }
+
+    /**
+     * Unwraps a key wrapped by {@link #wrapDual(SecretKey, String, String)}.
+     *
+     * @param wrappedKey Either of the wrapped keys.
+     * @param credential The password or recovery token the key was wrapped with.
+     * @return The unwrapped {@link SecretKey}, or null if the wrapped key is null.
+     * @throws IllegalArgumentException If the wrapped key is not in the expected format, the credential is wrong
+     *                                  or the wrapped key has been altered.
+     */
+    public static SecretKey unwrapDual(String wrappedKey, String credential) {
+
+        if (wrappedKey == null) {
+            return null;
+        }
+
+        byte[][] unpacked = Crypto.unpackStored(wrappedKey);
+        KeyWrapper keyWrapper = new KeyWrapper(credential, ByteArray.toBase64(unpacked[0]));
+        return keyWrapper.unwrapSecretKey(ByteArray.toBase64(unpacked[1]));
+    }
+
+    /**
+     * @param key        The key to be wrapped.
+     * @param credential The password or token to wrap it with.
+     * @return The key, wrapped with a key generated from the credential and a new salt, packed with the salt.
+     */
+    private static String wrapWithSalt(SecretKey key, String credential) {
+        byte[] salt = Generate.saltBytes();
+        KeyWrapper keyWrapper = new KeyWrapper(credential, ByteArray.toBase64(salt));
+        return Crypto.packStored(salt, ByteArray.fromBase64(keyWrapper.wrapSecretKey(key)));
+    }
+
+    /**
+     * Unwraps a secret key that was wrapped by {@link #KeyWrapper(String, String)} and {@link #wrapSecretKey(SecretKey)},
+     * whichever key size was in use when it was wrapped.
+     * <p>
+     * The size of a password-based wrap key depends on {@link Keys#useStrongKeys()} or {@link Keys#useStandardKeys()},
+     * so a stored wrapped key can only be unwrapped with the same setting. That's a problem when migrating stored keys
+     * between environments, or when the setting has changed over time. This method tries a
+     * {@value #STRONG_WRAP_KEY_SIZE}-bit wrap key, then a {@value #STANDARD_WRAP_KEY_SIZE}-bit one, and uses whichever
+     * passes the {@value #WRAP_ALGORITHM_SYMMETRIC} integrity check. You can then re-wrap the key in the current format
+     * with {@link #wrapSecretKey(SecretKey)}.
+     *
+     * @param password   The password the key was wrapped with.
+     * @param salt       The salt the key was wrapped with.
+     * @param wrappedKey The wrapped key, base-64 encoded, as returned by {@link #wrapSecretKey(SecretKey)}.
+     * @return The unwrapped {@link SecretKey}, or null if the wrapped key is null.
+     * @throws IllegalArgumentException If the key can't be unwrapped with either key size, which means the password
+     *                                  or salt are wrong or the wrapped key has been altered.
+     */
+    public static SecretKey importWrappedSecretKey(String password, String salt, String wrappedKey) {
+
+        if (wrappedKey == null) {
+            return null;
+        }
+
+        byte[] wrapped = ByteArray.fromBase64(wrappedKey);
+        char[] chars = password.toCharArray();
+        try {
+            for (int keySize : new int[]{STRONG_WRAP_KEY_SIZE, STANDARD_WRAP_KEY_SIZE}) {
+                SecretKey kek = Keys.generateSecretKey(chars, salt, Keys.SYMMETRIC_PASSWORD_ITERATIONS, keySize);
+                try {
+                    return new SecretKeySpec(aesKeyUnwrap(kek, wrapped), Keys.SYMMETRIC_ALGORITHM);
+                } catch (IllegalArgumentException e) {
+                    // Try the next key size
+                }
+            }
+        } finally {
+            ByteArray.zeroize(chars);
+        }
+
+        throw new IllegalArgumentException("Unable to unwrap key with either a " + STRONG_WRAP_KEY_SIZE + "-bit or a "
+                + STANDARD_WRAP_KEY_SIZE + "-bit wrap key. Either the password or salt are wrong or the wrapped key has been altered.");
+    }
+
+    /**
+     * Wraps the given key material using AES Key Wrap, as defined in RFC 3394.
+     * <p>
+     * This is for interoperating with HSMs and key management systems that expect this
+     * standard format. If the key material is not a multiple of 8 bytes (or is only 8 bytes),
+     * the padded variant defined in RFC 5649 is used instead.
+     * {@link #aesKeyUnwrap(SecretKey, byte[])} handles both variants.
+     *
+     * @param kek The key-encryption key. This must be an AES key.
+     * @param key The key material to be wrapped.
+     * @return The wrapped key, or null if the key material is null.
+     */
+    public static byte[] aesKeyWrap(SecretKey kek, byte[] key) {
+
+        if (key == null) {
+            return null;
+        }
+        if (key.length == 0) {
+            throw new IllegalArgumentException("Unable to wrap empty key material.");
+        }
+
+        Cipher cipher = getAesBlockCipher(Cipher.ENCRYPT_MODE, kek);
+
+        // RFC 3394 applies to two or more whole 64-bit blocks:
+        if (key.length >= 2 * WRAP_BLOCK_SIZE && key.length % WRAP_BLOCK_SIZE == 0) {
+            return aesKeyWrap(cipher, AES_KEY_WRAP_IV, key);
+        }
+
+        // Otherwise use RFC 5649: an alternative IV which includes the length, plus zero padding
+        byte[] iv = ByteArray.concat(AES_KEY_WRAP_PAD_IV, ByteBuffer.allocate(4).putInt(key.length).array());
+        int paddedLength = ((key.length + WRAP_BLOCK_SIZE - 1) / WRAP_BLOCK_SIZE) * WRAP_BLOCK_SIZE;
+        byte[] padded = Arrays.copyOf(key, paddedLength);
+        if (padded.length == WRAP_BLOCK_SIZE) {
+            // A single block is simply encrypted with the IV:
+            return aesBlock(cipher, ByteArray.concat(iv, padded));
+        }
+        return aesKeyWrap(cipher, iv, padded);
+    }
+
+    /**
+     * Unwraps key material wrapped using AES Key Wrap, as defined in RFC 3394 or RFC 5649.
+     *
+     * @param kek     The key-encryption key. This must be an AES key.
+     * @param wrapped The wrapped key, as returned by {@link #aesKeyWrap(SecretKey, byte[])}.
+     * @return The unwrapped key material, or null if the wrapped key is null.
+     * @throws IllegalArgumentException If the wrapped key is the wrong length, the kek is wrong or
+     *                                  the wrapped key has been altered.
+     */
+    public static byte[] aesKeyUnwrap(SecretKey kek, byte[] wrapped) {
+
+        if (wrapped == null) {
+            return null;
+        }
+        if (wrapped.length < 2 * WRAP_BLOCK_SIZE || wrapped.length % WRAP_BLOCK_SIZE != 0) {
+            throw new IllegalArgumentException("Are you sure this is a wrapped key? Byte length (" + wrapped.length
+                    + ") is not a whole number of " + WRAP_BLOCK_SIZE + "-byte blocks.");
+        }
+
+        Cipher cipher = getAesBlockCipher(Cipher.DECRYPT_MODE, kek);
+
+        // Recover the IV and key material:
+        byte[][] result;
+        if (wrapped.length == 2 * WRAP_BLOCK_SIZE) {
+            // Only possible with RFC 5649, where a single block is simply encrypted:
+            result = ByteArray.splitAt(aesBlock(cipher, wrapped), WRAP_BLOCK_SIZE);
+        } else {
+            result = aesKeyUnwrap(cipher, wrapped);
+        }
+        byte[] iv = result[0];
+        byte[] key = result[1];
+
+        // RFC 3394:
+        if (Arrays.equals(iv, AES_KEY_WRAP_IV)) {
+            return key;
+        }
+
+        // RFC 5649 - check the length and padding:
+        byte[][] ivParts = ByteArray.splitAt(iv, AES_KEY_WRAP_PAD_IV.length);
+        if (Arrays.equals(ivParts[0], AES_KEY_WRAP_PAD_IV)) {
+            int length = ByteBuffer.wrap(ivParts[1]).getInt();
+            if (length > key.length - WRAP_BLOCK_SIZE && length <= key.length) {
+                byte[][] keyParts = ByteArray.splitAt(key, length);
+                if (Arrays.equals(keyParts[1], new byte[keyParts[1].length])) {
+                    return keyParts[0];
+                }
+            }
+        }
+
+        throw new IllegalArgumentException("Integrity check failed when unwrapping key. " +
+                "Either the key-encryption key is wrong or the wrapped key has been altered.");
+    }
+
+    /**
+     * Implements the RFC 3394 wrapping process.
+     *
+     * @param cipher An AES block cipher, initialised for encryption with the key-encryption key.
+     * @param iv     The 8-byte initial value.
+     * @param key    The key material, a multiple of 8 bytes.
+     * @return The wrapped key.
+     */
+    private static byte[] aesKeyWrap(Cipher cipher, byte[] iv, byte[] key) {
+
+        int n = key.length / WRAP_BLOCK_SIZE;
+        byte[] a = iv.clone();
+        byte[] r = key.clone();
+
+        for (int j = 0; j <= 5; j++) {
+            for (int i = 1; i <= n; i++) {
+                int offset = (i - 1) * WRAP_BLOCK_SIZE;
+                byte[] b = aesBlock(cipher, ByteArray.concat(a, Arrays.copyOfRange(r, offset, offset + WRAP_BLOCK_SIZE)));
+                a = Arrays.copyOf(b, WRAP_BLOCK_SIZE);
+                xorCounter(a, (long) n * j + i);
+                System.arraycopy(b, WRAP_BLOCK_SIZE, r, offset, WRAP_BLOCK_SIZE);
+            }
+        }
+
+        return ByteArray.concat(a, r);
+    }
+
+    /**
+     * Implements the RFC 3394 unwrapping process.
+     *
+     * @param cipher  An AES block cipher, initialised for decryption with the key-encryption key.
+     * @param wrapped The wrapped key.
+     * @return A two-element array containing the recovered initial value, followed by the key material.
+     */
+    private static byte[][] aesKeyUnwrap(Cipher cipher, byte[] wrapped) {
+
+        int n = wrapped.length / WRAP_BLOCK_SIZE - 1;
+        byte[][] parts = ByteArray.splitAt(wrapped, WRAP_BLOCK_SIZE);
+        byte[] a = parts[0];
+        byte[] r = parts[1];
+
+        for (int j = 5; j >= 0; j--) {
+            for (int i = n; i >= 1; i--) {
+                int offset = (i - 1) * WRAP_BLOCK_SIZE;
+                xorCounter(a, (long) n * j + i);
+                byte[] b = aesBlock(cipher, ByteArray.concat(a, Arrays.copyOfRange(r, offset, offset + WRAP_BLOCK_SIZE)));
+                a = Arrays.copyOf(b, WRAP_BLOCK_SIZE);
+                System.arraycopy(b, WRAP_BLOCK_SIZE, r, offset, WRAP_BLOCK_SIZE);
+            }
+        }
+
+        return new byte[][]{a, r};
+    }
+
+    /**
+     * XORs the given counter value, as a big-endian 64-bit integer, into the given block.
+     *
+     * @param block   An 8-byte block, which will be modified.
+     * @param counter The counter value.
+     */
+    private static void xorCounter(byte[] block, long counter) {
+        for (int k = WRAP_BLOCK_SIZE - 1; k >= 0; k--) {
+            block[k] ^= (byte) counter;
+            counter >>>= 8;
+        }
+    }
+
+    /**
+     * @param cipher An AES block cipher.
+     * @param block  A single 16-byte block.
+     * @return The processed block.
+     */
+    private static byte[] aesBlock(Cipher cipher, byte[] block) {
+        try {
+            return cipher.doFinal(block);
+        } catch (IllegalBlockSizeException e) {
+            throw new IllegalStateException("Error in block size for algorithm " + AES_BLOCK_ALGORITHM, e);
+        } catch (BadPaddingException e) {
+            throw new IllegalStateException("Padding error for algorithm " + AES_BLOCK_ALGORITHM, e);
+        }
+    }
+
+    /**
+     * @param mode One of {@link Cipher#ENCRYPT_MODE} or {@link Cipher#DECRYPT_MODE}.
+     * @param kek  The key-encryption key.
+     * @return An AES block cipher, initialised with the given key.
+     */
+    private static Cipher getAesBlockCipher(int mode, SecretKey kek) {
+        try {
+            Cipher cipher = Cipher.getInstance(AES_BLOCK_ALGORITHM);
+            cipher.init(mode, kek);
+            return cipher;
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return getAesBlockCipher(mode, kek);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + AES_BLOCK_ALGORITHM, e);
+            }
+        } catch (NoSuchPaddingException e) {
+            throw new IllegalStateException("Padding unavailable: " + AES_BLOCK_ALGORITHM, e);
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Invalid key for " + AES_BLOCK_ALGORITHM, e);
+        }
+    }
+
     /**
      * Wraps the given {@link Key} using the given wrap algorithm.
      *
@@ -1,15 +1,81 @@
 package com.github.davidcarboni.cryptolite;
 
+import org.apache.commons.lang.StringUtils;
+import org.bouncycastle.asn1.ASN1EncodableVector;
+import org.bouncycastle.asn1.ASN1Encoding;
+import org.bouncycastle.asn1.ASN1Integer;
+import org.bouncycastle.asn1.ASN1ObjectIdentifier;
+import org.bouncycastle.asn1.DERBitString;
+import org.bouncycastle.asn1.DERNull;
+import org.bouncycastle.asn1.DERSequence;
+import org.bouncycastle.asn1.edec.EdECObjectIdentifiers;
+import org.bouncycastle.asn1.pkcs.PKCSObjectIdentifiers;
+import org.bouncycastle.asn1.pkcs.PrivateKeyInfo;
+import org.bouncycastle.asn1.x500.X500Name;
+import org.bouncycastle.asn1.x500.X500NameBuilder;
+import org.bouncycastle.asn1.x500.style.BCStyle;
+import org.bouncycastle.asn1.x509.AlgorithmIdentifier;
+import org.bouncycastle.asn1.x509.SubjectPublicKeyInfo;
+import org.bouncycastle.asn1.x509.TBSCertificate;
+import org.bouncycastle.asn1.x509.Time;
+import org.bouncycastle.asn1.x509.V3TBSCertificateGenerator;
+import org.bouncycastle.asn1.x9.X9ObjectIdentifiers;
+import org.bouncycastle.crypto.digests.SHA256Digest;
+import org.bouncycastle.crypto.generators.Argon2BytesGenerator;
+import org.bouncycastle.crypto.generators.HKDFBytesGenerator;
+import org.bouncycastle.crypto.params.Argon2Parameters;
+import org.bouncycastle.crypto.params.HKDFParameters;
+
 import javax.crypto.Cipher;
 import javax.crypto.KeyGenerator;
+import javax.crypto.Mac;
 import javax.crypto.SecretKey;
 import javax.crypto.SecretKeyFactory;
 import javax.crypto.spec.PBEKeySpec;
 import javax.crypto.spec.SecretKeySpec;
+import java.io.ByteArrayInputStream;
+import java.io.ByteArrayOutputStream;
+import java.io.DataInputStream;
+import java.io.DataOutputStream;
+import java.io.IOException;
+import java.math.BigInteger;
+import java.security.InvalidAlgorithmParameterException;
+import java.security.InvalidKeyException;
+import java.security.KeyFactory;
 import java.security.KeyPair;
 import java.security.KeyPairGenerator;
+import java.security.KeyStore;
+import java.security.KeyStoreException;
+import java.security.MessageDigest;
 import java.security.NoSuchAlgorithmException;
+import java.security.PrivateKey;
+import java.security.PublicKey;
+import java.security.Signature;
+import java.security.SignatureException;
+import java.security.UnrecoverableEntryException;
+import java.security.cert.Certificate;
+import java.security.cert.CertificateException;
+import java.security.cert.CertificateFactory;
+import java.security.cert.X509Certificate;
+import java.security.interfaces.ECPublicKey;
+import java.security.interfaces.RSAPublicKey;
+import java.security.spec.ECParameterSpec;
+import java.security.spec.ECPoint;
+import java.security.spec.ECPublicKeySpec;
 import java.security.spec.InvalidKeySpecException;
+import java.security.spec.KeySpec;
+import java.security.spec.PKCS8EncodedKeySpec;
+import java.security.spec.RSAPublicKeySpec;
+import java.security.spec.X509EncodedKeySpec;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.Collections;
+import java.util.Date;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
 
 /**
  * Generates cryptographic keys.
@@ -108,11 +174,41 @@ public class Keys {
      */
     public static final String SYMMETRIC_PASSWORD_ALGORITHM = "PBKDF2WithHmacSHA256";
 
+    /**
+     * The name of the memory-hard key derivation function used by {@link #generateSecretKeyArgon2(String, String, KdfParameters)}.
+     */
+    public static final String ARGON2ID = "Argon2id";
+
     /**
      * The number of iteration rounds to use for password-based secret keys.
      */
     public static final int SYMMETRIC_PASSWORD_ITERATIONS = 1024;
 
+    /**
+     * The digest algorithm used to generate convergent secret keys from content.
+     */
+    public static final String CONVERGENT_DIGEST_ALGORITHM = "SHA-256";
+
+    /**
+     * The size of the keys returned by {@link #splitKeys(byte[])}, in bytes.
+     */
+    public static final int SPLIT_KEY_BYTES = 32;
+
+    /**
+     * The HKDF info label for the encryption key returned by {@link #splitKeys(byte[])}.
+     */
+    public static final String ENCRYPTION_KEY_INFO = "enc";
+
+    /**
+     * The HKDF info label for the MAC key returned by {@link #splitKeys(byte[])}.
+     */
+    public static final String MAC_KEY_INFO = "mac";
+
+    /**
+     * The prefix of the HKDF info label for keys returned by {@link #tenantKey(byte[], String)}.
+     */
+    public static final String TENANT_KEY_INFO_PREFIX = "tenant:";
+
     /**
      * The public-private key pair algorithm.
      */
@@ -123,6 +219,46 @@ public class Keys {
      */
     public static final int ASYMMETRIC_KEY_SIZE = 4096;
 
+    /**
+     * The algorithm for compact signing key pairs (see {@link SignedToken}).
+     */
+    public static final String SIGNING_ALGORITHM = "Ed25519";
+
+    /**
+     * The number of digest bytes used for identifiers returned by {@link #keyId(PublicKey)}.
+     */
+    public static final int KEY_ID_BYTES = 10;
+
+    /**
+     * The message signed and verified by {@link #newKeyPairVerified()}.
+     */
+    public static final String SELF_TEST_MESSAGE = "cryptolite pairwise consistency test";
+
+    /**
+     * The OpenSSH key type for RSA keys.
+     */
+    public static final String SSH_RSA = "ssh-rsa";
+
+    /**
+     * The OpenSSH key type for Ed25519 keys.
+     */
+    public static final String SSH_ED25519 = "ssh-ed25519";
+
+    /**
+     * The prefix of OpenSSH key types for EC keys, followed by the curve name (e.g. nistp256).
+     */
+    public static final String SSH_ECDSA_PREFIX = "ecdsa-sha2-";
+
+    /**
+     * The alias under which {@link #toPkcs12(PrivateKey, X509Certificate, String)} stores the key.
+     */
+    public static final String PKCS12_ALIAS = "cryptolite";
+
+    /**
+     * Matches the first PEM block in a string, capturing the type and base64 content.
+     */
+    private static final Pattern PEM = Pattern.compile("-----BEGIN ([A-Z0-9 ]+)-----([A-Za-z0-9+/=\\s]*)-----END \\1-----");
+
     /**
      * Generates a new secret (also known as symmetric) key for use with {@value #SYMMETRIC_ALGORITHM}.
      * <p>
@@ -156,6 +292,115 @@ public class Keys {
         return keyGenerator.generateKey();
     }
 
+    /**
+     * Generates a new secret key by calling {@link #newSecretKey()} and returns it base64-encoded.
+     * <p>
+     * This is handy for setup scripts where you want to generate a key and paste it into configuration.
+     * Bear in mind that the returned value is the raw key, so treat it as a secret.
+     *
+     * @return A new, randomly generated secret key, as a base64-encoded String.
+     */
+    public static String newSecretKeyBase64() {
+        return ByteArray.toBase64(newSecretKey().getEncoded());
+    }
+
+    /**
+     * Generates a new random secret key of the right size for the given cipher.
+     * <p>
+     * This avoids wrong-size key errors when you choose a cipher by name, for example from configuration.
+     * The supported ciphers, and their key sizes, are listed by {@link Crypto#supportedCiphers()}.
+     *
+     * @param cipherName The name of the cipher, as given by {@link AlgorithmInfo#getName()}.
+     * @return A new, randomly generated key of the size the cipher requires.
+     * @throws IllegalArgumentException If the cipher isn't supported.
+     */
+    public static SecretKey newSecretKeyFor(String cipherName) {
+        for (AlgorithmInfo cipher : Crypto.supportedCiphers()) {
+            if (cipher.getName().equals(cipherName)) {
+                byte[] keyBytes = Generate.byteArray(cipher.getKeySize() / 8);
+                SecretKey key = new SecretKeySpec(keyBytes, SYMMETRIC_ALGORITHM);
+                ByteArray.zeroize(keyBytes);
+                return key;
+            }
+        }
+        throw new IllegalArgumentException("Unsupported cipher: " + cipherName + ". Supported ciphers are listed by Crypto.supportedCiphers().");
+    }
+
+    /**
+     * Generates a new secret key by calling {@link #newSecretKeyFor(String)} and returns it base64-encoded.
+     *
+     * @param cipherName The name of the cipher, as given by {@link AlgorithmInfo#getName()}.
+     * @return A new, randomly generated key of the size the cipher requires, as a base64-encoded String.
+     * @throws IllegalArgumentException If the cipher isn't supported.
+     */
+    public static String newSecretKeyBase64(String cipherName) {
+        return ByteArray.toBase64(newSecretKeyFor(cipherName).getEncoded());
+    }
+
+    /**
+     * Generates a new root encryption key for an application, by calling {@link #newSecretKey()},
+     * together with a random identifier and the creation time.
+     * <p>
+     * Use {@link RootKey#toJson()} to serialise it for secure storage.
+     *
+     * @return A new, randomly generated root key.
+     */
+    public static RootKey newRootKey() {
+        String keyId = ByteArray.toBase32(Generate.byteArray(KEY_ID_BYTES));
+        return new RootKey(newSecretKey(), keyId, new Date());
+    }
+
+    /**
+     * Lends the raw bytes of the given key to the given consumer for the duration of the call.
+     * <p>
+     * This is the preferred way to pass a key to an API that takes a byte array. The bytes are a
+     * copy of the key material, which is wiped with {@link ByteArray#zeroize(byte[]...)} as soon as
+     * the consumer returns (or throws), so the key material doesn't linger in memory. The key itself
+     * is unaffected and can still be used afterwards.
+     *
+     * @param key      The key.
+     * @param consumer Receives the raw key bytes. It mustn't keep a reference to them.
+     */
+    public static void withKeyBytes(SecretKey key, KeyBytesConsumer consumer) {
+        byte[] keyBytes = key.getEncoded();
+        try {
+            consumer.accept(keyBytes);
+        } finally {
+            ByteArray.zeroize(keyBytes);
+        }
+    }
+
+    /**
+     * Returns a copy of the raw bytes of the given key.
+     * <p>
+     * Prefer {@link #withKeyBytes(SecretKey, KeyBytesConsumer)} where you can. If you do need a copy,
+     * it's up to you to wipe it with {@link ByteArray#zeroize(byte[]...)} once you're done with it.
+     *
+     * @param key The key.
+     * @return A copy of the key material, or null if the key is null.
+     */
+    public static byte[] copyKeyBytes(SecretKey key) {
+        return key == null ? null : key.getEncoded();
+    }
+
+    /**
+     * Creates a {@value #SYMMETRIC_ALGORITHM} key from raw bytes, for example those received from another API.
+     *
+     * @param keyBytes The raw key material. This must be 16, 24 or 32 bytes. The array is copied, so you can wipe it afterwards.
+     * @return A {@link SecretKey}, or null if the bytes are null.
+     */
+    public static SecretKey secretKeyFromBytes(byte[] keyBytes) {
+
+        if (keyBytes == null) {
+            return null;
+        }
+        if (keyBytes.length != 16 && keyBytes.length != 24 && keyBytes.length != 32) {
+            throw new IllegalArgumentException("Are you sure this is a " + SYMMETRIC_ALGORITHM
+                    + " key? Byte length (" + keyBytes.length + ") isn't 16, 24 or 32.");
+        }
+        return new SecretKeySpec(keyBytes, SYMMETRIC_ALGORITHM);
+    }
+
     /**
      * Generates a new secret (or symmetric) key for use with AES using the given password and salt values.
      *
@@ -176,7 +421,200 @@ public class Keys {
      *                 identical which might give away someone's password.
      * @return A deterministic secret key, defined by the given password and salt
      */
-    static SecretKey generateSecretKey(String password, String salt) {
+    public static SecretKey generateSecretKey(String password, String salt) {
+        return generateSecretKey(password, salt, SYMMETRIC_PASSWORD_ITERATIONS);
+    }
+
+    /**
+     * Generates a secret key from a password held in a char array, as per {@link #generateSecretKey(String, String)}.
+     * <p>
+     * Strings are immutable, so a password held in a String can't be wiped and may linger in memory
+     * until it's garbage collected. If you read the password into a char array instead (for example with
+     * {@link Password#readPassword(java.io.Reader, char[])}) you can wipe it with
+     * {@link ByteArray#zeroize(char[])} as soon as the key has been generated.
+     *
+     * @param password The password. This is not modified, so it's up to you to wipe it.
+     * @param salt     A value for this parameter can be generated by calling {@link Generate#salt()}.
+     * @return A deterministic secret key, defined by the given password and salt, or null if the password is null.
+     */
+    public static SecretKey generateSecretKey(char[] password, String salt) {
+        return generateSecretKey(password, salt, SYMMETRIC_PASSWORD_ITERATIONS);
+    }
+
+    /**
+     * Generates a secret key from a password that is bound to a machine-specific secret.
+     * <p>
+     * The password is first combined with the machine secret using {@value HashMac#ALGORITHM}, and the result
+     * is used as the input to {@link #generateSecretKey(String, String)}. This means that someone who
+     * obtains your stored salts (for example, from a stolen database) can't derive the key, even if they
+     * guess the password, unless they also have the machine secret.
+     * <p>
+     * The machine secret might be held in a hardware-backed store, or derived from a device identifier.
+     * Bear in mind that if it is lost, the key can't be regenerated.
+     *
+     * @param password      The password.
+     * @param salt          A value for this parameter can be generated by calling {@link Generate#salt()}.
+     * @param machineSecret The machine-specific secret. This must not be null or empty.
+     * @return A deterministic secret key, defined by the given password, salt and machine secret,
+     * or null if the password is null.
+     */
+    public static SecretKey generateSecretKeyBound(String password, String salt, byte[] machineSecret) {
+
+        if (password == null) {
+            return null;
+        }
+        if (machineSecret == null || machineSecret.length == 0) {
+            throw new IllegalArgumentException("Please provide a machine secret.");
+        }
+
+        // Bind the password to the machine before stretching it:
+        byte[] bound = hmac(machineSecret, ByteArray.fromString(password));
+        char[] chars = ByteArray.toBase64(bound).toCharArray();
+        try {
+            return generateSecretKey(chars, salt, SYMMETRIC_PASSWORD_ITERATIONS);
+        } finally {
+            ByteArray.zeroize(bound);
+            ByteArray.zeroize(chars);
+        }
+    }
+
+    /**
+     * Generates a secret key from a low-entropy PIN, using the Argon2id key derivation function.
+     * <p>
+     * A 4-6 digit PIN can be brute-forced in seconds with an ordinary password-based key, so this
+     * uses a deliberately slow, memory-hard function and, crucially, asks the given
+     * {@link AttemptRecorder} for permission before each attempt so you can enforce a lockout.
+     *
+     * @param pin      The PIN.
+     * @param salt     A value for this parameter can be generated by calling {@link Generate#salt()}.
+     *                 As with {@link #generateSecretKey(String, String)}, you'll need to store it.
+     * @param params   The Argon2id cost parameters. Use <code>new KdfParameters()</code> for the defaults.
+     * @param recorder Called before each attempt. This must not be null.
+     * @return A deterministic secret key, defined by the given PIN, salt and parameters.
+     * @throws IllegalArgumentException  If the PIN or salt is null.
+     * @throws AttemptsExceededException If the recorder refuses the attempt.
+     */
+    public static SecretKey generateSecretKeyFromPin(String pin, String salt, KdfParameters params, AttemptRecorder recorder) {
+
+        // A missing PIN or salt would otherwise silently give a null or unsalted key:
+        if (pin == null || salt == null) {
+            throw new IllegalArgumentException("Please provide a PIN and a salt to generate a key from.");
+        }
+
+        // Check we're allowed to make an attempt before doing any work:
+        if (!recorder.recordAttempt()) {
+            throw new AttemptsExceededException("Too many attempts. Please try again later.");
+        }
+
+        return generateSecretKeyArgon2(pin, salt, params);
+    }
+
+    /**
+     * Generates a secret key from a password, using the Argon2id key derivation function.
+     * <p>
+     * Argon2id is memory-hard, so it's much more resistant to brute-force attacks with specialised
+     * hardware than {@link #generateSecretKey(String, String)}. The trade-off is that it uses
+     * significant memory, as determined by the given parameters.
+     *
+     * @param password The password.
+     * @param salt     A value for this parameter can be generated by calling {@link Generate#salt()}.
+     *                 You'll need to store the salt value and the parameters to regenerate the same key.
+     * @param params   The Argon2id cost parameters.
+     * @return A deterministic secret key, defined by the given password, salt and parameters,
+     * or null if the password is null.
+     */
+    public static SecretKey generateSecretKeyArgon2(String password, String salt, KdfParameters params) {
+
+        if (password == null) {
+            return null;
+        }
+
+        Argon2Parameters parameters = new Argon2Parameters.Builder(Argon2Parameters.ARGON2_id)
+                .withSalt(ByteArray.fromBase64(salt))
+                .withMemoryAsKB(params.getMemoryKb())
+                .withIterations(params.getIterations())
+                .withParallelism(params.getParallelism())
+                .build();
+        Argon2BytesGenerator generator = new Argon2BytesGenerator();
+        generator.init(parameters);
+
+        // SecretKeySpec takes a copy, so we can clear the intermediate values:
+        char[] chars = password.toCharArray();
+        byte[] keyBytes = new byte[SYMMETRIC_KEY_SIZE / 8];
+        try {
+            generator.generateBytes(chars, keyBytes);
+            return new SecretKeySpec(keyBytes, SYMMETRIC_ALGORITHM);
+        } finally {
+            ByteArray.zeroize(keyBytes);
+            ByteArray.zeroize(chars);
+        }
+    }
+
+    /**
+     * Generates a new random salt value and a deterministic secret key from the given password and that salt.
+     * <p>
+     * This is what you need when a user first sets a password: store the returned salt
+     * and pass it to {@link #generateSecretKey(String, String)} each time you need to regenerate the key.
+     * Doing both in one call avoids the risk of generating the key with a different salt to the one you store.
+     *
+     * @param password The starting point to use in generating the key. See {@link #generateSecretKey(String, String)}.
+     * @return The generated key and salt, or null if the password is null.
+     */
+    public static DerivedSecretKey newDeterministicSecretKey(String password) {
+
+        if (password == null) {
+            return null;
+        }
+
+        String salt = Generate.salt();
+        return new DerivedSecretKey(generateSecretKey(password, salt), salt);
+    }
+
+    /**
+     * Generates a secret key from the given password and salt, as per
+     * {@link #generateSecretKey(String, String)}, but with a specific number of iterations.
+     *
+     * @param password   The starting point to use in generating the key.
+     * @param salt       A value for this parameter can be generated by calling {@link Generate#salt()}.
+     * @param iterations The number of iteration rounds. This is normally {@value #SYMMETRIC_PASSWORD_ITERATIONS}.
+     * @return A deterministic secret key, defined by the given password, salt and iterations.
+     */
+    static SecretKey generateSecretKey(String password, String salt, int iterations) {
+
+        if (password == null) {
+            return null;
+        }
+
+        char[] chars = password.toCharArray();
+        try {
+            return generateSecretKey(chars, salt, iterations);
+        } finally {
+            ByteArray.zeroize(chars);
+        }
+    }
+
+    /**
+     * Generates a secret key from the given password, salt and number of iterations.
+     *
+     * @param password   The starting point to use in generating the key.
+     * @param salt       A value for this parameter can be generated by calling {@link Generate#salt()}.
+     * @param iterations The number of iteration rounds. This is normally {@value #SYMMETRIC_PASSWORD_ITERATIONS}.
+     * @return A deterministic secret key, defined by the given password, salt and iterations.
+     */
+    static SecretKey generateSecretKey(char[] password, String salt, int iterations) {
+        return generateSecretKey(password, salt, iterations, SYMMETRIC_KEY_SIZE);
+    }
+
+    /**
+     * Generates a secret key of a specific size from the given password, salt and number of iterations.
+     *
+     * @param password   The starting point to use in generating the key.
+     * @param salt       A value for this parameter can be generated by calling {@link Generate#salt()}.
+     * @param iterations The number of iteration rounds. This is normally {@value #SYMMETRIC_PASSWORD_ITERATIONS}.
+     * @param keySize    The key size, in bits. This is normally {@link #SYMMETRIC_KEY_SIZE}.
+     * @return A deterministic secret key, defined by the given password, salt, iterations and key size.
+     */
+    static SecretKey generateSecretKey(char[] password, String salt, int iterations, int keySize) {
 
         if (password == null) {
             return null;
@@ -190,7 +628,7 @@ public class Keys {
         } catch (NoSuchAlgorithmException e) {
             if (SecurityProvider.addProvider()) {
                 // Retry
-                return generateSecretKey(password, salt);
+                return generateSecretKey(password, salt, iterations, keySize);
             } else {
                 throw new IllegalStateException("Algorithm unavailable: " + SYMMETRIC_PASSWORD_ALGORITHM, e);
             }
@@ -198,12 +636,14 @@ public class Keys {
 
         // Generate the key:
         byte[] saltBytes = ByteArray.fromBase64(salt);
-        PBEKeySpec pbeKeySpec = new PBEKeySpec(password.toCharArray(), saltBytes, SYMMETRIC_PASSWORD_ITERATIONS, SYMMETRIC_KEY_SIZE);
+        PBEKeySpec pbeKeySpec = new PBEKeySpec(password, saltBytes, iterations, keySize);
         SecretKey key;
         try {
             key = factory.generateSecret(pbeKeySpec);
         } catch (InvalidKeySpecException e) {
             throw new IllegalStateException("Error generating password-based key.", e);
+        } finally {
+            pbeKeySpec.clearPassword();
         }
 
         // NB: At this point, key.getAlgorithm() returns PBKDF2WithHmacSHA256,
@@ -214,6 +654,160 @@ public class Keys {
         return new SecretKeySpec(key.getEncoded(), SYMMETRIC_ALGORITHM);
     }
 
+    /**
+     * Lists the key derivation and password hashing functions this library uses, with their output sizes
+     * and default parameters.
+     * <p>
+     * The key size of password-based keys reflects the current setting of {@link #useStrongKeys()} or
+     * {@link #useStandardKeys()}.
+     *
+     * @return Information about each supported key derivation function.
+     */
+    public static List<AlgorithmInfo> supportedKdfs() {
+
+        Map<String, String> pbkdf2 = new LinkedHashMap<>();
+        pbkdf2.put("iterations", String.valueOf(SYMMETRIC_PASSWORD_ITERATIONS));
+        pbkdf2.put("saltBytes", String.valueOf(Generate.SALT_BYTES));
+
+        Map<String, String> argon2 = new LinkedHashMap<>();
+        argon2.put("memoryKb", String.valueOf(KdfParameters.DEFAULT_MEMORY_KB));
+        argon2.put("iterations", String.valueOf(KdfParameters.DEFAULT_ITERATIONS));
+        argon2.put("parallelism", String.valueOf(KdfParameters.DEFAULT_PARALLELISM));
+
+        Map<String, String> passwordPbkdf2 = new LinkedHashMap<>();
+        passwordPbkdf2.put("iterations", String.valueOf(SYMMETRIC_PASSWORD_ITERATIONS));
+        passwordPbkdf2.put("saltBytes", String.valueOf(Generate.SALT_BYTES));
+
+        Map<String, String> passwordArgon2 = new LinkedHashMap<>();
+        passwordArgon2.put("memoryKb", String.valueOf(Password.ARGON2_MEMORY_KB));
+        passwordArgon2.put("iterations", String.valueOf(Password.ARGON2_ITERATIONS));
+        passwordArgon2.put("parallelism", String.valueOf(Password.ARGON2_PARALLELISM));
+
+        List<AlgorithmInfo> result = new ArrayList<>();
+        String keys = Keys.class.getSimpleName();
+        String password = Password.class.getSimpleName();
+        result.add(new AlgorithmInfo(SYMMETRIC_PASSWORD_ALGORITHM, keys, SYMMETRIC_KEY_SIZE, pbkdf2));
+        result.add(new AlgorithmInfo(ARGON2ID, keys, SYMMETRIC_KEY_SIZE, argon2));
+        result.add(new AlgorithmInfo(SYMMETRIC_PASSWORD_ALGORITHM, password, SYMMETRIC_KEY_SIZE, passwordPbkdf2));
+        result.add(new AlgorithmInfo(ARGON2ID, password, Password.ARGON2_HASH_BYTES * 8, passwordArgon2));
+        return result;
+    }
+
+    /**
+     * Checks key derivation parameters against the default {@link KdfPolicy}: at least
+     * {@value KdfPolicy#DEFAULT_MIN_ITERATIONS} iterations, at least {@value KdfPolicy#DEFAULT_MIN_SALT_BYTES}
+     * bytes of salt and no SHA-1 or MD5.
+     * <p>
+     * Call this from a test or at startup. To enforce different minimums, create a {@link KdfPolicy}
+     * and call {@link KdfPolicy#check(int, int, String)}.
+     * <p>
+     * Note that this library's own default, {@value #SYMMETRIC_PASSWORD_ITERATIONS} iterations (see
+     * {@link #generateSecretKey(String, String)}), is kept for compatibility with existing keys and
+     * <em>fails</em> this policy. To pass, use {@link #generateSecretKeyArgon2(String, String, KdfParameters)},
+     * which the iteration minimum doesn't apply to, or a PBKDF2 iteration count of at least
+     * {@value KdfPolicy#DEFAULT_MIN_ITERATIONS}.
+     *
+     * @param iterations The number of iterations.
+     * @param saltBytes  The salt length, in bytes.
+     * @param algorithm  The key derivation algorithm, for example {@value #SYMMETRIC_PASSWORD_ALGORITHM}.
+     * @throws IllegalArgumentException If the parameters don't meet the policy. The message lists every problem found.
+     */
+    public static void checkKdfPolicy(int iterations, int saltBytes, String algorithm) {
+        new KdfPolicy().check(iterations, saltBytes, algorithm);
+    }
+
+    /**
+     * Finds salt values that are used more than once, for example when auditing stored password-based keys.
+     * <p>
+     * Each key should have its own random salt (see {@link #newDeterministicSecretKey(String)}), so a shared salt
+     * usually points to a bug in how salts were generated or stored.
+     *
+     * @param salts The stored salt values, for example one per account, in a stable order.
+     * @return Each salt that appears more than once, mapped to the positions in the given list where it appears.
+     * Salts are in the order they were first seen. If there are no duplicates, the map is empty.
+     */
+    public static Map<String, List<Integer>> findDuplicateSalts(List<String> salts) {
+
+        // Group positions by salt value:
+        Map<String, List<Integer>> positions = new LinkedHashMap<>();
+        for (int i = 0; i < salts.size(); i++) {
+            List<Integer> group = positions.get(salts.get(i));
+            if (group == null) {
+                group = new ArrayList<>();
+                positions.put(salts.get(i), group);
+            }
+            group.add(i);
+        }
+
+        // Keep only the salts that are used more than once:
+        Map<String, List<Integer>> result = new LinkedHashMap<>();
+        for (Map.Entry<String, List<Integer>> entry : positions.entrySet()) {
+            if (entry.getValue().size() > 1) {
+                result.put(entry.getKey(), entry.getValue());
+            }
+        }
+        return result;
+    }
+
+    /**
+     * Regenerates a deterministic secret key with its existing salt and iterations,
+     * as well as a new key with a new salt and iterations.
+     * <p>
+     * This is useful if you need to migrate data to a stronger salt/iteration scheme:
+     * decrypt with the old key and re-encrypt with the new one.
+     * Bear in mind this requires the password, so migration can only happen when it's available
+     * (e.g. when a user logs in).
+     *
+     * @param password      The password used to generate both keys.
+     * @param oldSalt       The salt used to generate the existing key.
+     * @param newSalt       The salt to use for the new key. This can be generated by calling {@link Generate#salt()}.
+     * @param oldIterations The number of iterations used to generate the existing key.
+     * @param newIterations The number of iterations to use for the new key.
+     * @return A two-element array containing the old key, followed by the new key,
+     * or null if the password is null.
+     */
+    public static SecretKey[] rederiveSecretKey(String password, String oldSalt, String newSalt, int oldIterations, int newIterations) {
+
+        if (password == null) {
+            return null;
+        }
+
+        SecretKey oldKey = generateSecretKey(password, oldSalt, oldIterations);
+        SecretKey newKey = generateSecretKey(password, newSalt, newIterations);
+        return new SecretKey[]{oldKey, newKey};
+    }
+
+    /**
+     * Generates a secret key for use with {@value #SYMMETRIC_ALGORITHM} from the content it will be used to encrypt.
+     * <p>
+     * This is for "convergent" encryption (see {@link Crypto#encryptConvergent(String)}).
+     * The key is a {@value #CONVERGENT_DIGEST_ALGORITHM} digest of the content,
+     * truncated to {@link #SYMMETRIC_KEY_SIZE} bits, so identical content always produces an identical key.
+     * <p>
+     * Anyone who can guess the content can regenerate the key, so only use this where that's acceptable.
+     *
+     * @param content The content the key will be used to encrypt.
+     * @return A deterministic secret key, defined by the given content, or null if the content is null.
+     */
+    public static SecretKey convergentSecretKey(String content) {
+
+        if (content == null) {
+            return null;
+        }
+
+        MessageDigest digest;
+        try {
+            digest = MessageDigest.getInstance(CONVERGENT_DIGEST_ALGORITHM);
+        } catch (NoSuchAlgorithmException e) {
+            throw new IllegalStateException("Algorithm unavailable: " + CONVERGENT_DIGEST_ALGORITHM, e);
+        }
+
+        // Truncate the digest to the configured key size:
+        byte[] hash = digest.digest(ByteArray.fromString(content));
+        byte[] keyBytes = Arrays.copyOf(hash, SYMMETRIC_KEY_SIZE / 8);
+        return new SecretKeySpec(keyBytes, SYMMETRIC_ALGORITHM);
+    }
+
     /**
      * Generates a new public-private (or asymmetric) key pair for use with {@value #ASYMMETRIC_ALGORITHM}.
      * <p>
@@ -245,6 +839,677 @@ public class Keys {
         return result;
     }
 
+    /**
+     * Generates a new key pair, as per {@link #newKeyPair()}, and checks it with a pairwise consistency test
+     * before returning it.
+     * <p>
+     * The test signs {@value #SELF_TEST_MESSAGE} with the private key and verifies the signature with
+     * the public key, in the style of the FIPS 140 pairwise consistency check. This catches a broken key
+     * before it's used to protect anything.
+     *
+     * @return A new, randomly generated asymmetric key pair that has passed the self-test.
+     * @throws IllegalStateException If the key pair fails the self-test.
+     */
+    public static KeyPair newKeyPairVerified() {
+
+        KeyPair keyPair = newKeyPair();
+        if (!selfTest(keyPair)) {
+            throw new IllegalStateException("The generated key pair failed its pairwise consistency self-test.");
+        }
+        return keyPair;
+    }
+
+    /**
+     * Signs {@value #SELF_TEST_MESSAGE} with the private key and verifies the signature with the public key.
+     *
+     * @param keyPair The key pair to test.
+     * @return If the signature verifies, true, otherwise false.
+     */
+    static boolean selfTest(KeyPair keyPair) {
+        DigitalSignature digitalSignature = DigitalSignature.forKey(keyPair.getPrivate());
+        String signature = digitalSignature.sign(SELF_TEST_MESSAGE, keyPair.getPrivate());
+        return digitalSignature.verify(SELF_TEST_MESSAGE, keyPair.getPublic(), signature);
+    }
+
+    /**
+     * Derives separate encryption and MAC keys from a single master key, using HKDF-SHA256.
+     * <p>
+     * Using the same key for both encryption and a MAC is unsafe. If you're combining a cipher
+     * with an HMAC (for example AES-CBC followed by {@link HashMac}), this lets you keep a single
+     * master key and derive a distinct key for each purpose. The keys are derived with the HKDF info
+     * labels "{@value #ENCRYPTION_KEY_INFO}" and "{@value #MAC_KEY_INFO}", so the split is deterministic.
+     *
+     * @param master The master key material. This should be at least {@value #SPLIT_KEY_BYTES} random bytes.
+     * @return A two-element array containing a {@value #SPLIT_KEY_BYTES}-byte {@value #SYMMETRIC_ALGORITHM}
+     * encryption key followed by a {@value #SPLIT_KEY_BYTES}-byte {@value HashMac#ALGORITHM} key.
+     * @throws IllegalArgumentException If the master key is null or empty.
+     */
+    public static SecretKey[] splitKeys(byte[] master) {
+
+        if (master == null || master.length == 0) {
+            throw new IllegalArgumentException("Please provide master key material to split.");
+        }
+
+        SecretKey encryptionKey = new SecretKeySpec(hkdf(master, ENCRYPTION_KEY_INFO, SPLIT_KEY_BYTES), SYMMETRIC_ALGORITHM);
+        SecretKey macKey = new SecretKeySpec(hkdf(master, MAC_KEY_INFO, SPLIT_KEY_BYTES), HashMac.ALGORITHM);
+        return new SecretKey[]{encryptionKey, macKey};
+    }
+
+    /**
+     * Derives a per-tenant key from a single root key, using HKDF-SHA256 with the tenant identifier as the info label.
+     * <p>
+     * This is for multi-tenant services: each tenant gets its own key without any per-tenant key storage, and
+     * compromising one tenant's key reveals nothing about the root or any other tenant's key. The same root and
+     * tenant identifier always produce the same key. The info label is prefixed with
+     * "{@value #TENANT_KEY_INFO_PREFIX}", so tenant keys can't collide with the keys returned by {@link #splitKeys(byte[])}.
+     *
+     * @param root     The root key material. This should be at least {@value #SPLIT_KEY_BYTES} random bytes.
+     * @param tenantId The tenant identifier.
+     * @return A {@value #SYMMETRIC_ALGORITHM} key of {@link #SYMMETRIC_KEY_SIZE} bits for the tenant.
+     * @throws IllegalArgumentException If the root key is null or empty, or the tenant identifier is empty.
+     */
+    public static SecretKey tenantKey(byte[] root, String tenantId) {
+
+        if (root == null || root.length == 0) {
+            throw new IllegalArgumentException("Please provide root key material to derive from.");
+        }
+        if (StringUtils.isEmpty(tenantId)) {
+            throw new IllegalArgumentException("Please provide a tenant identifier.");
+        }
+
+        byte[] keyBytes = hkdf(root, TENANT_KEY_INFO_PREFIX + tenantId, SYMMETRIC_KEY_SIZE / 8);
+        SecretKey key = new SecretKeySpec(keyBytes, SYMMETRIC_ALGORITHM);
+        ByteArray.zeroize(keyBytes);
+        return key;
+    }
+
+    /**
+     * Derives a child key from a master seed, following a slash-separated path
+     * such as <code>user/42/device/3</code>.
+     * <p>
+     * This is useful for managing a hierarchy of keys (e.g. per-user, per-device) from a single
+     * master seed. Each path segment is applied in turn, in the style of BIP32 hardened derivation:
+     * the key for a segment is the {@value HashMac#ALGORITHM} of the segment, keyed with the key of
+     * its parent. This means each path gives an independent, reproducible key and a child key
+     * can be derived from its parent's key without knowing the master seed, but not the other way round.
+     *
+     * @param master The master seed. This should be at least {@value #SPLIT_KEY_BYTES} random bytes.
+     * @param path   The derivation path. Segments must not be empty.
+     * @return A {@value #SPLIT_KEY_BYTES}-byte child key.
+     * @throws IllegalArgumentException If the master seed is empty or the path is blank or contains an empty segment.
+     */
+    public static byte[] deriveChild(byte[] master, String path) {
+
+        if (master == null || master.length == 0) {
+            throw new IllegalArgumentException("Please provide a master seed to derive from.");
+        }
+        if (StringUtils.isBlank(path)) {
+            throw new IllegalArgumentException("Please provide a derivation path.");
+        }
+
+        byte[] key = master;
+        for (String segment : path.split("/", -1)) {
+            if (segment.isEmpty()) {
+                throw new IllegalArgumentException("Derivation path contains an empty segment: " + path);
+            }
+            key = hmac(key, ByteArray.fromString(segment));
+        }
+        return key;
+    }
+
+    /**
+     * @param key     The HMAC key.
+     * @param message The message.
+     * @return The {@value HashMac#ALGORITHM} of the message.
+     */
+    private static byte[] hmac(byte[] key, byte[] message) {
+        try {
+            Mac mac = Mac.getInstance(HashMac.ALGORITHM);
+            mac.init(new SecretKeySpec(key, HashMac.ALGORITHM));
+            return mac.doFinal(message);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return hmac(key, message);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + HashMac.ALGORITHM, e);
+            }
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Unable to construct key for " + HashMac.ALGORITHM, e);
+        }
+    }
+
+    /**
+     * @param master The input key material.
+     * @param info   The HKDF info label.
+     * @param length The number of bytes to derive.
+     * @return Key material derived with HKDF-SHA256 and no salt.
+     */
+    private static byte[] hkdf(byte[] master, String info, int length) {
+        HKDFBytesGenerator generator = new HKDFBytesGenerator(new SHA256Digest());
+        generator.init(new HKDFParameters(master, null, ByteArray.fromString(info)));
+        byte[] result = new byte[length];
+        generator.generateBytes(result, 0, length);
+        return result;
+    }
+
+    /**
+     * Generates a new public-private key pair for use with {@value #SIGNING_ALGORITHM}.
+     * <p>
+     * These keys are much smaller than {@value #ASYMMETRIC_ALGORITHM} keys, which makes them
+     * a good fit for compact signed values such as those produced by {@link SignedToken}.
+     *
+     * @return A new, randomly generated signing key pair.
+     */
+    public static KeyPair newSigningKeyPair() {
+
+        // Construct a key generator
+        KeyPairGenerator keyPairGenerator;
+        try {
+            keyPairGenerator = KeyPairGenerator.getInstance(SIGNING_ALGORITHM);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return newSigningKeyPair();
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + SIGNING_ALGORITHM, e);
+            }
+        }
+
+        // Generate a key:
+        return keyPairGenerator.generateKeyPair();
+    }
+
+    /**
+     * Generates a new elliptic curve key pair on the named curve.
+     * <p>
+     * This is for integrating with systems that mandate a particular curve. The keys can be used
+     * for signing with {@link DigitalSignature#forKey(java.security.Key)}.
+     *
+     * @param curveName One of <code>P-256</code>, <code>P-384</code> or <code>P-521</code>.
+     * @return A new, randomly generated EC key pair.
+     * @throws IllegalArgumentException If the curve name isn't supported.
+     */
+    public static KeyPair newEcKeyPair(String curveName) {
+
+        ECParameterSpec curve = JsonWebKey.curve(curveName);
+
+        // Construct a key generator
+        KeyPairGenerator keyPairGenerator;
+        try {
+            keyPairGenerator = KeyPairGenerator.getInstance("EC");
+            keyPairGenerator.initialize(curve);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return newEcKeyPair(curveName);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: EC", e);
+            }
+        } catch (InvalidAlgorithmParameterException e) {
+            throw new IllegalStateException("Curve unavailable: " + curveName, e);
+        }
+
+        // Generate a key:
+        return keyPairGenerator.generateKeyPair();
+    }
+
+    /**
+     * Generates a minimal self-signed X.509 certificate for a key pair.
+     * <p>
+     * This is mainly so that a generated key can be exported with {@link #toPkcs12(PrivateKey, X509Certificate, String)},
+     * because PKCS#12 tools expect every private key to come with a certificate. The certificate has a random serial
+     * number and no extensions, and is signed with SHA-256 (RSA PKCS#1 v1.5 or ECDSA) or {@value #SIGNING_ALGORITHM}.
+     *
+     * @param keyPair    The key pair. This can be an RSA, EC or {@value #SIGNING_ALGORITHM} key pair.
+     * @param commonName The common name (CN) to use as the subject and issuer.
+     * @param validDays  The number of days, from now, for which the certificate is valid.
+     * @return A self-signed certificate for the public key.
+     * @throws IllegalArgumentException If the key type is not supported, or the number of days is not positive.
+     */
+    public static X509Certificate newSelfSignedCertificate(KeyPair keyPair, String commonName, int validDays) {
+
+        if (validDays < 1) {
+            throw new IllegalArgumentException("The certificate must be valid for at least one day, but got " + validDays);
+        }
+
+        String keyAlgorithm = keyPair.getPrivate().getAlgorithm();
+        String signatureAlgorithm;
+        AlgorithmIdentifier signatureId;
+        if ("RSA".equals(keyAlgorithm)) {
+            signatureAlgorithm = "SHA256withRSA";
+            signatureId = new AlgorithmIdentifier(PKCSObjectIdentifiers.sha256WithRSAEncryption, DERNull.INSTANCE);
+        } else if ("EC".equals(keyAlgorithm) || "ECDSA".equals(keyAlgorithm)) {
+            signatureAlgorithm = "SHA256withECDSA";
+            signatureId = new AlgorithmIdentifier(X9ObjectIdentifiers.ecdsa_with_SHA256);
+        } else if (SIGNING_ALGORITHM.equals(keyAlgorithm) || "EdDSA".equals(keyAlgorithm)) {
+            signatureAlgorithm = SIGNING_ALGORITHM;
+            signatureId = new AlgorithmIdentifier(EdECObjectIdentifiers.id_Ed25519);
+        } else {
+            throw new IllegalArgumentException("Unsupported key type for a certificate: " + keyAlgorithm);
+        }
+
+        X500Name name = new X500NameBuilder(BCStyle.INSTANCE).addRDN(BCStyle.CN, commonName).build();
+        long now = System.currentTimeMillis();
+        V3TBSCertificateGenerator tbs = new V3TBSCertificateGenerator();
+        tbs.setSerialNumber(new ASN1Integer(new BigInteger(1, Generate.byteArray(16))));
+        tbs.setSignature(signatureId);
+        tbs.setIssuer(name);
+        tbs.setSubject(name);
+        tbs.setStartDate(new Time(new Date(now)));
+        tbs.setEndDate(new Time(new Date(now + validDays * 24L * 60 * 60 * 1000)));
+        tbs.setSubjectPublicKeyInfo(SubjectPublicKeyInfo.getInstance(keyPair.getPublic().getEncoded()));
+        TBSCertificate certificate = tbs.generateTBSCertificate();
+
+        try {
+            Signature signer = Signature.getInstance(signatureAlgorithm);
+            signer.initSign(keyPair.getPrivate());
+            signer.update(certificate.getEncoded(ASN1Encoding.DER));
+            ASN1EncodableVector fields = new ASN1EncodableVector();
+            fields.add(certificate);
+            fields.add(signatureId);
+            fields.add(new DERBitString(signer.sign()));
+            byte[] encoded = new DERSequence(fields).getEncoded(ASN1Encoding.DER);
+            return (X509Certificate) CertificateFactory.getInstance("X.509")
+                    .generateCertificate(new ByteArrayInputStream(encoded));
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return newSelfSignedCertificate(keyPair, commonName, validDays);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + signatureAlgorithm, e);
+            }
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Error initialising certificate signature - invalid key", e);
+        } catch (SignatureException | IOException | CertificateException e) {
+            throw new IllegalStateException("Error generating certificate", e);
+        }
+    }
+
+    /**
+     * Exports a private key and its certificate as a password-protected PKCS#12 (.pfx / .p12) bundle,
+     * for use with Windows and other tools that consume that format.
+     * <p>
+     * The key is stored under the alias {@value #PKCS12_ALIAS}. If you've generated a key pair with this class,
+     * you can create a certificate for it with {@link #newSelfSignedCertificate(KeyPair, String, int)}.
+     *
+     * @param privateKey  The private key.
+     * @param certificate The certificate for the corresponding public key.
+     * @param password    The password to protect the bundle with. This must not be null.
+     * @return The PKCS#12 bundle.
+     * @throws IllegalArgumentException If the password is null, or the key and certificate can't be stored.
+     * @see #fromPkcs12(byte[], String)
+     */
+    public static byte[] toPkcs12(PrivateKey privateKey, X509Certificate certificate, String password) {
+
+        if (password == null) {
+            throw new IllegalArgumentException("Please provide a password to protect the PKCS#12 bundle with.");
+        }
+
+        char[] passwordChars = password.toCharArray();
+        try {
+            KeyStore keyStore = KeyStore.getInstance("PKCS12");
+            keyStore.load(null, null);
+            keyStore.setKeyEntry(PKCS12_ALIAS, privateKey, passwordChars, new Certificate[]{certificate});
+            ByteArrayOutputStream bundle = new ByteArrayOutputStream();
+            keyStore.store(bundle, passwordChars);
+            return bundle.toByteArray();
+        } catch (KeyStoreException e) {
+            throw new IllegalArgumentException("Unable to store the key and certificate in a PKCS#12 bundle.", e);
+        } catch (NoSuchAlgorithmException | CertificateException | IOException e) {
+            throw new IllegalStateException("Error generating PKCS#12 bundle", e);
+        } finally {
+            ByteArray.zeroize(passwordChars);
+        }
+    }
+
+    /**
+     * Reads the private key and certificate from a PKCS#12 bundle, such as one produced by
+     * {@link #toPkcs12(PrivateKey, X509Certificate, String)} or exported from another tool.
+     * <p>
+     * If the bundle contains more than one key, the first one found is returned.
+     *
+     * @param bundle   The PKCS#12 bundle.
+     * @param password The password the bundle is protected with. This must not be null.
+     * @return The private key, together with its certificate chain, or null if the bundle is null.
+     * @throws IllegalArgumentException If the password is null, the bundle can't be read, the password is wrong
+     *                                  or there's no key in it.
+     */
+    public static KeyStore.PrivateKeyEntry fromPkcs12(byte[] bundle, String password) {
+
+        if (bundle == null) {
+            return null;
+        }
+        if (password == null) {
+            throw new IllegalArgumentException("Please provide the password the PKCS#12 bundle is protected with.");
+        }
+
+        char[] passwordChars = password.toCharArray();
+        try {
+            KeyStore keyStore = KeyStore.getInstance("PKCS12");
+            keyStore.load(new ByteArrayInputStream(bundle), passwordChars);
+            for (String alias : Collections.list(keyStore.aliases())) {
+                if (keyStore.entryInstanceOf(alias, KeyStore.PrivateKeyEntry.class)) {
+                    return (KeyStore.PrivateKeyEntry) keyStore.getEntry(alias, new KeyStore.PasswordProtection(passwordChars));
+                }
+            }
+            throw new IllegalArgumentException("There's no private key in this PKCS#12 bundle.");
+        } catch (IOException | UnrecoverableEntryException e) {
+            throw new IllegalArgumentException("Unable to read the PKCS#12 bundle. Is the password correct?", e);
+        } catch (KeyStoreException | NoSuchAlgorithmException | CertificateException e) {
+            throw new IllegalStateException("Error reading PKCS#12 bundle", e);
+        } finally {
+            ByteArray.zeroize(passwordChars);
+        }
+    }
+
+    /**
+     * Parses a PEM-encoded private key, detecting whether it's an RSA, EC or {@value #SIGNING_ALGORITHM} key.
+     * <p>
+     * This means you don't need to know the type of a key in advance when loading it from disk.
+     * The following formats are supported:
+     * <ul>
+     * <li>PKCS#8 (<code>BEGIN PRIVATE KEY</code>) containing an RSA, EC or {@value #SIGNING_ALGORITHM} key.</li>
+     * <li>PKCS#1 (<code>BEGIN RSA PRIVATE KEY</code>).</li>
+     * <li>SEC1 (<code>BEGIN EC PRIVATE KEY</code>).</li>
+     * </ul>
+     * Encrypted private keys are not supported.
+     * The returned key can be used to sign with {@link DigitalSignature#forKey(java.security.Key)}.
+     *
+     * @param pem The PEM-encoded private key.
+     * @return The parsed {@link PrivateKey}, or null if the given PEM is null.
+     * @throws IllegalArgumentException If the PEM can't be parsed or contains an unsupported key type.
+     */
+    public static PrivateKey parsePrivateKeyPem(String pem) {
+
+        if (pem == null) {
+            return null;
+        }
+
+        Matcher matcher = PEM.matcher(pem);
+        if (!matcher.find()) {
+            throw new IllegalArgumentException("Are you sure this is a PEM-encoded key? No BEGIN/END lines found.");
+        }
+        String type = matcher.group(1);
+        byte[] der = ByteArray.fromBase64(matcher.group(2).replaceAll("\\s", ""));
+
+        if (!"PRIVATE KEY".equals(type) && !"RSA PRIVATE KEY".equals(type) && !"EC PRIVATE KEY".equals(type)) {
+            throw new IllegalArgumentException("Unsupported PEM type: " + type);
+        }
+
+        // Convert PKCS#1 and SEC1 keys to PKCS#8 so they can all be handled the same way:
+        PrivateKeyInfo info;
+        byte[] encoded;
+        try {
+            if ("RSA PRIVATE KEY".equals(type)) {
+                info = new PrivateKeyInfo(new AlgorithmIdentifier(PKCSObjectIdentifiers.rsaEncryption, DERNull.INSTANCE),
+                        org.bouncycastle.asn1.pkcs.RSAPrivateKey.getInstance(der));
+            } else if ("EC PRIVATE KEY".equals(type)) {
+                org.bouncycastle.asn1.sec.ECPrivateKey ecKey = org.bouncycastle.asn1.sec.ECPrivateKey.getInstance(der);
+                info = new PrivateKeyInfo(new AlgorithmIdentifier(X9ObjectIdentifiers.id_ecPublicKey, ecKey.getParameters()), ecKey);
+            } else {
+                info = PrivateKeyInfo.getInstance(der);
+            }
+            encoded = info.getEncoded();
+        } catch (IOException | RuntimeException e) {
+            throw new IllegalArgumentException("Unable to parse " + type + " PEM.", e);
+        }
+
+        // Detect the key type:
+        ASN1ObjectIdentifier oid = info.getPrivateKeyAlgorithm().getAlgorithm();
+        String algorithm;
+        if (PKCSObjectIdentifiers.rsaEncryption.equals(oid)) {
+            algorithm = "RSA";
+        } else if (X9ObjectIdentifiers.id_ecPublicKey.equals(oid)) {
+            algorithm = "EC";
+        } else if (EdECObjectIdentifiers.id_Ed25519.equals(oid)) {
+            algorithm = SIGNING_ALGORITHM;
+        } else {
+            throw new IllegalArgumentException("Unsupported private key algorithm: " + oid);
+        }
+
+        return generatePrivate(algorithm, new PKCS8EncodedKeySpec(encoded));
+    }
+
+    /**
+     * @param algorithm The key algorithm.
+     * @param spec      The key specification.
+     * @return The {@link PrivateKey} for the given specification.
+     */
+    private static PrivateKey generatePrivate(String algorithm, KeySpec spec) {
+        try {
+            return KeyFactory.getInstance(algorithm).generatePrivate(spec);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return generatePrivate(algorithm, spec);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
+            }
+        } catch (InvalidKeySpecException e) {
+            throw new IllegalArgumentException("Unable to convert PEM to a valid " + algorithm + " private key.", e);
+        }
+    }
+
+    /**
+     * Encodes a public key in OpenSSH <code>authorized_keys</code> format, for example
+     * <code>ssh-ed25519 AAAA... user@host</code>.
+     * <p>
+     * This is useful for provisioning SSH access: the result can be added as a line in an
+     * <code>authorized_keys</code> file. RSA, EC (P-256, P-384 and P-521) and
+     * {@value #SIGNING_ALGORITHM} keys are supported.
+     *
+     * @param key     The public key.
+     * @param comment A comment to append, typically <code>user@host</code>. This can be null or empty.
+     * @return The single-line OpenSSH representation of the key, or null if the key is null.
+     * @throws IllegalArgumentException If the key type is not supported.
+     */
+    public static String publicKeyToAuthorizedKey(PublicKey key, String comment) {
+
+        if (key == null) {
+            return null;
+        }
+
+        String type;
+        ByteArrayOutputStream blob = new ByteArrayOutputStream();
+        DataOutputStream out = new DataOutputStream(blob);
+        try {
+            if (key instanceof RSAPublicKey) {
+                type = SSH_RSA;
+                writeSshString(out, ByteArray.fromString(type));
+                writeSshString(out, ((RSAPublicKey) key).getPublicExponent().toByteArray());
+                writeSshString(out, ((RSAPublicKey) key).getModulus().toByteArray());
+            } else if (key instanceof ECPublicKey) {
+                ECPublicKey ecKey = (ECPublicKey) key;
+                String crv = JsonWebKey.curveName(ecKey.getParams());
+                if (crv == null) {
+                    throw new IllegalArgumentException("Unsupported curve for OpenSSH. Only nistp256, nistp384 and nistp521 are supported.");
+                }
+                int fieldSize = ecKey.getParams().getCurve().getField().getFieldSize();
+                String curve = "nistp" + StringUtils.removeStart(crv, "P-");
+                type = SSH_ECDSA_PREFIX + curve;
+                int length = (fieldSize + 7) / 8;
+                writeSshString(out, ByteArray.fromString(type));
+                writeSshString(out, ByteArray.fromString(curve));
+                writeSshString(out, ByteArray.concat(new byte[]{4},
+                        unsigned(ecKey.getW().getAffineX(), length), unsigned(ecKey.getW().getAffineY(), length)));
+            } else if (SIGNING_ALGORITHM.equals(key.getAlgorithm()) || "EdDSA".equals(key.getAlgorithm())) {
+                type = SSH_ED25519;
+                writeSshString(out, ByteArray.fromString(type));
+                writeSshString(out, SubjectPublicKeyInfo.getInstance(key.getEncoded()).getPublicKeyData().getBytes());
+            } else {
+                throw new IllegalArgumentException("Unsupported key type for OpenSSH: " + key.getAlgorithm());
+            }
+        } catch (IOException e) {
+            throw new IllegalStateException("Error encoding OpenSSH public key", e);
+        }
+
+        String result = type + " " + ByteArray.toBase64(blob.toByteArray());
+        if (StringUtils.isNotBlank(comment)) {
+            result += " " + comment.trim();
+        }
+        return result;
+    }
+
+    /**
+     * Parses a public key in OpenSSH <code>authorized_keys</code> format, as produced by
+     * {@link #publicKeyToAuthorizedKey(PublicKey, String)} or <code>ssh-keygen</code>.
+     * <p>
+     * Any comment is ignored, as are any options at the start of the line.
+     *
+     * @param authorizedKey A single <code>authorized_keys</code> line.
+     * @return The parsed {@link PublicKey}, or null if the given line is null.
+     * @throws IllegalArgumentException If the line can't be parsed or the key type is not supported.
+     */
+    public static PublicKey parseAuthorizedKey(String authorizedKey) {
+
+        if (authorizedKey == null) {
+            return null;
+        }
+
+        // Find the key type, skipping any options:
+        String[] tokens = StringUtils.split(authorizedKey);
+        int index = 0;
+        while (index < tokens.length - 1 && !SSH_RSA.equals(tokens[index]) && !SSH_ED25519.equals(tokens[index])
+                && !tokens[index].startsWith(SSH_ECDSA_PREFIX)) {
+            index++;
+        }
+        if (index >= tokens.length - 1) {
+            throw new IllegalArgumentException("Are you sure this is an OpenSSH public key? No supported key type found.");
+        }
+        String type = tokens[index];
+
+        DataInputStream in = new DataInputStream(new ByteArrayInputStream(ByteArray.fromBase64(tokens[index + 1])));
+        try {
+            String blobType = ByteArray.toString(readSshString(in));
+            if (!type.equals(blobType)) {
+                throw new IllegalArgumentException("OpenSSH key type (" + type + ") doesn't match the encoded key (" + blobType + ").");
+            }
+
+            if (SSH_RSA.equals(type)) {
+                BigInteger exponent = new BigInteger(readSshString(in));
+                BigInteger modulus = new BigInteger(readSshString(in));
+                return generatePublic(ASYMMETRIC_ALGORITHM, new RSAPublicKeySpec(modulus, exponent));
+            } else if (SSH_ED25519.equals(type)) {
+                byte[] raw = readSshString(in);
+                SubjectPublicKeyInfo info = new SubjectPublicKeyInfo(new AlgorithmIdentifier(EdECObjectIdentifiers.id_Ed25519), raw);
+                return generatePublic(SIGNING_ALGORITHM, new X509EncodedKeySpec(info.getEncoded()));
+            } else {
+                String curve = ByteArray.toString(readSshString(in));
+                ECParameterSpec params = JsonWebKey.curve("P-" + StringUtils.removeStart(curve, "nistp"));
+                byte[] point = readSshString(in);
+                if (point.length < 1 || point[0] != 4) {
+                    throw new IllegalArgumentException("Only uncompressed EC points are supported.");
+                }
+                int length = (point.length - 1) / 2;
+                BigInteger x = new BigInteger(1, Arrays.copyOfRange(point, 1, 1 + length));
+                BigInteger y = new BigInteger(1, Arrays.copyOfRange(point, 1 + length, point.length));
+                return generatePublic("EC", new ECPublicKeySpec(new ECPoint(x, y), params));
+            }
+        } catch (IOException e) {
+            throw new IllegalArgumentException("Are you sure this is an OpenSSH public key? The encoded key is truncated.", e);
+        }
+    }
+
+    private static void writeSshString(DataOutputStream out, byte[] value) throws IOException {
+        out.writeInt(value.length);
+        out.write(value);
+    }
+
+    private static byte[] readSshString(DataInputStream in) throws IOException {
+        int length = in.readInt();
+        if (length < 0 || length > in.available()) {
+            throw new IOException("Invalid length: " + length);
+        }
+        byte[] value = new byte[length];
+        in.readFully(value);
+        return value;
+    }
+
+    /**
+     * @param value  A positive value.
+     * @param length The number of bytes to encode the value in.
+     * @return The unsigned, big-endian representation of the value, left-padded to the given length.
+     */
+    private static byte[] unsigned(BigInteger value, int length) {
+        byte[] bytes = value.toByteArray();
+        if (bytes.length > length) {
+            bytes = Arrays.copyOfRange(bytes, bytes.length - length, bytes.length);
+        }
+        return ByteArray.concat(new byte[length - bytes.length], bytes);
+    }
+
+    /**
+     * @param algorithm The key algorithm.
+     * @param spec      The key specification.
+     * @return The {@link PublicKey} for the given specification.
+     */
+    private static PublicKey generatePublic(String algorithm, KeySpec spec) {
+        try {
+            return KeyFactory.getInstance(algorithm).generatePublic(spec);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return generatePublic(algorithm, spec);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + algorithm, e);
+            }
+        } catch (InvalidKeySpecException e) {
+            throw new IllegalArgumentException("Unable to convert OpenSSH key to a valid " + algorithm + " public key.", e);
+        }
+    }
+
+    /**
+     * Generates a short, stable identifier for a public key.
+     * <p>
+     * This is useful for referring to keys in a keyring or in logs without printing the whole key.
+     * The identifier is the first {@value #KEY_ID_BYTES} bytes of the {@value Digest#SHA256} digest of
+     * the encoded key, in base-32 (see {@link ByteArray#toBase32(byte[])}) for readability,
+     * so the same key always has the same identifier.
+     *
+     * @param key The public key.
+     * @return The key identifier, or null if the key is null.
+     */
+    public static String keyId(PublicKey key) {
+
+        if (key == null) {
+            return null;
+        }
+
+        byte[] digest = Digest.sha256(key.getEncoded());
+        return ByteArray.toBase32(Arrays.copyOf(digest, KEY_ID_BYTES));
+    }
+
+    /**
+     * Checks whether two public keys are the same key.
+     * <p>
+     * Key objects loaded in different ways (e.g. by different providers) don't necessarily
+     * implement <code>equals</code> consistently, so this compares the key values:
+     * the modulus and exponent for RSA keys, the curve point and parameters for EC keys and
+     * the encoded key for any other type (such as {@value #SIGNING_ALGORITHM}).
+     *
+     * @param a A public key.
+     * @param b Another public key.
+     * @return If both keys are the same, or both are null, true.
+     */
+    public static boolean publicKeyEquals(PublicKey a, PublicKey b) {
+
+        if (a == null || b == null) {
+            return a == b;
+        }
+
+        if (a instanceof RSAPublicKey && b instanceof RSAPublicKey) {
+            RSAPublicKey rsaA = (RSAPublicKey) a;
+            RSAPublicKey rsaB = (RSAPublicKey) b;
+            return rsaA.getModulus().equals(rsaB.getModulus())
+                    && rsaA.getPublicExponent().equals(rsaB.getPublicExponent());
+        } else if (a instanceof ECPublicKey && b instanceof ECPublicKey) {
+            ECParameterSpec paramsA = ((ECPublicKey) a).getParams();
+            ECParameterSpec paramsB = ((ECPublicKey) b).getParams();
+            return ((ECPublicKey) a).getW().equals(((ECPublicKey) b).getW())
+                    && paramsA.getCurve().equals(paramsB.getCurve())
+                    && paramsA.getGenerator().equals(paramsB.getGenerator())
+                    && paramsA.getOrder().equals(paramsB.getOrder())
+                    && paramsA.getCofactor() == paramsB.getCofactor();
+        }
+
+        return a.getAlgorithm().equals(b.getAlgorithm()) && Arrays.equals(a.getEncoded(), b.getEncoded());
+    }
+
     /**
      * If the "Java Cryptography Extension (JCE) Unlimited Strength Jurisdiction Policy Files" is
      * correctly installed for your JVM, it's possible to use strong (256-bit) keys.
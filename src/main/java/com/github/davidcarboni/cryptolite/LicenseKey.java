@@ -0,0 +1,96 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+import java.nio.ByteBuffer;
+import java.security.MessageDigest;
+import java.util.Arrays;
+
+/**
+ * Generates and validates license keys such as <code>ABCDEF-GHIJKL-MNOPQR-STUVWX</code>.
+ * <p>
+ * Each key encodes a {@value #PAYLOAD_BYTES}-byte payload of your choosing (for example an edition and
+ * expiry date packed into a <code>long</code>) followed by a {@value #CHECK_BYTES}-byte check value,
+ * which is a truncated {@link HashMac} of the payload. This means keys can be validated offline,
+ * but can't be forged without the secret. The same secret and payload always produce the same key.
+ * <p>
+ * Keys are base-32 encoded, so they're case-insensitive and avoid easily confused characters,
+ * and grouped with hyphens for readability.
+ *
+ * @author David Carboni
+ */
+public class LicenseKey {
+
+    /**
+     * The number of payload bytes in a license key.
+     */
+    public static final int PAYLOAD_BYTES = 8;
+
+    /**
+     * The number of check bytes in a license key.
+     */
+    public static final int CHECK_BYTES = 7;
+
+    /**
+     * The number of characters in each hyphen-separated group of a license key.
+     */
+    public static final int GROUP = 6;
+
+    // 15 bytes is exactly 24 base-32 characters:
+    private static final int KEY_CHARACTERS = (PAYLOAD_BYTES + CHECK_BYTES) * 8 / 5;
+
+    /**
+     * Generates a license key for the given payload.
+     *
+     * @param secret  The secret used to compute the check value. Anyone with this can generate keys.
+     * @param payload The value to encode in the key.
+     * @return A license key.
+     */
+    public static String generate(SecretKey secret, long payload) {
+
+        byte[] payloadBytes = ByteBuffer.allocate(PAYLOAD_BYTES).putLong(payload).array();
+        String encoded = ByteArray.toBase32(ByteArray.concat(payloadBytes, check(secret, payloadBytes)));
+
+        StringBuilder result = new StringBuilder();
+        for (int i = 0; i < encoded.length(); i += GROUP) {
+            if (i > 0) {
+                result.append('-');
+            }
+            result.append(encoded, i, Math.min(i + GROUP, encoded.length()));
+        }
+        return result.toString();
+    }
+
+    /**
+     * Validates the given license key and returns its payload.
+     *
+     * @param secret The secret used to generate the key.
+     * @param key    The license key. Hyphens, whitespace and case are ignored.
+     * @return The payload, or null if the key is null, malformed or has been altered.
+     */
+    public static Long validate(SecretKey secret, String key) {
+
+        if (key == null) {
+            return null;
+        }
+
+        String encoded = key.replaceAll("[\\s-]", "").toUpperCase();
+        if (!encoded.matches("[A-Z2-7]{" + KEY_CHARACTERS + "}")) {
+            return null;
+        }
+
+        byte[][] parts = ByteArray.splitAt(ByteArray.fromBase32(encoded), PAYLOAD_BYTES);
+        if (!MessageDigest.isEqual(parts[1], check(secret, parts[0]))) {
+            return null;
+        }
+        return ByteBuffer.wrap(parts[0]).getLong();
+    }
+
+    /**
+     * @param secret  The secret.
+     * @param payload The payload bytes.
+     * @return The truncated HMAC of the payload.
+     */
+    private static byte[] check(SecretKey secret, byte[] payload) {
+        return Arrays.copyOf(new HashMac(secret).digest(payload), CHECK_BYTES);
+    }
+}
@@ -0,0 +1,25 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown when encrypted data are not in the expected format, for example because they have been
+ * truncated or were not produced by Cryptolite.
+ *
+ * @author David Carboni
+ */
+public class MalformedDataException extends DecryptionException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public MalformedDataException(String message) {
+        super(message);
+    }
+
+    /**
+     * @param message A description of the error.
+     * @param cause   The underlying cause of the error.
+     */
+    public MalformedDataException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
@@ -0,0 +1,107 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+import java.util.List;
+
+/**
+ * Encrypts a message once so that any of several recipients can decrypt it with their own key.
+ * <p>
+ * This is useful for sharing a document with several people without encrypting the content separately
+ * for each of them. The content is encrypted once with {@link AuthenticatedCrypto} and a random data key,
+ * then the data key is encrypted to each recipient's public key with a {@link SealedBox}.
+ * The output is a {@link Frame} containing one sealed data key per recipient, followed by the
+ * encrypted content.
+ * <p>
+ * Sealed boxes are anonymous, so the output doesn't say which recipients it's for.
+ * {@link #open(byte[], byte[], byte[])} tries every sealed data key to find the one it can open.
+ * Keys are the raw X25519 keys generated by {@link SealedBox#newKeyPair()}.
+ *
+ * @author David Carboni
+ */
+public class MultiRecipientBox {
+
+    private static final AuthenticatedCrypto crypto = new AuthenticatedCrypto();
+
+    /**
+     * Encrypts a message for the given recipients.
+     *
+     * @param recipientPublicKeys The public keys of the recipients, each {@value SealedBox#PUBLIC_KEY_BYTES} bytes.
+     * @param message             The message to encrypt.
+     * @return The encrypted message, or null if the message is null.
+     * @throws IllegalArgumentException If there are no recipients or a key is invalid.
+     */
+    public static byte[] seal(List<byte[]> recipientPublicKeys, byte[] message) {
+
+        if (message == null) {
+            return null;
+        }
+        if (recipientPublicKeys.isEmpty()) {
+            throw new IllegalArgumentException("Please provide at least one recipient.");
+        }
+
+        SecretKey dataKey = Keys.newSecretKey();
+        Frame frame = new Frame();
+        byte[] dataKeyBytes = Keys.copyKeyBytes(dataKey);
+        try {
+            for (byte[] recipientPublicKey : recipientPublicKeys) {
+                frame.addField(SealedBox.seal(recipientPublicKey, dataKeyBytes));
+            }
+        } finally {
+            ByteArray.zeroize(dataKeyBytes);
+        }
+
+        return frame.addField(crypto.encrypt(message, dataKey)).toByteArray();
+    }
+
+    /**
+     * Decrypts a message encrypted by {@link #seal(List, byte[])}.
+     *
+     * @param recipientPublicKey  The recipient's public key.
+     * @param recipientPrivateKey The recipient's private key.
+     * @param box                 The encrypted message.
+     * @return The decrypted message, or null if the box is null.
+     * @throws MalformedDataException  If the box is not in the expected format.
+     * @throws AuthenticationException If the box wasn't sealed for this recipient, or has been altered.
+     */
+    public static byte[] open(byte[] recipientPublicKey, byte[] recipientPrivateKey, byte[] box) {
+
+        if (box == null) {
+            return null;
+        }
+
+        byte[][] fields;
+        try {
+            fields = ByteArray.unframe(box);
+        } catch (IllegalArgumentException e) {
+            throw new MalformedDataException("Are you sure this is a multi-recipient box? " + e.getMessage(), e);
+        }
+        if (fields.length < 2) {
+            throw new MalformedDataException("Are you sure this is a multi-recipient box? Expected at least 2 fields but got "
+                    + fields.length + ".");
+        }
+
+        // Try every sealed data key, so the time taken doesn't reveal which one is ours:
+        byte[] dataKeyBytes = null;
+        for (int i = 0; i < fields.length - 1; i++) {
+            try {
+                byte[] opened = SealedBox.open(recipientPublicKey, recipientPrivateKey, fields[i]);
+                if (dataKeyBytes == null) {
+                    dataKeyBytes = opened;
+                } else {
+                    ByteArray.zeroize(opened);
+                }
+            } catch (DecryptionException e) {
+                // Not sealed for this recipient.
+            }
+        }
+        if (dataKeyBytes == null) {
+            throw new AuthenticationException("This box wasn't sealed for the given key, or has been altered.");
+        }
+
+        try {
+            return crypto.decrypt(fields[fields.length - 1], Keys.secretKeyFromBytes(dataKeyBytes));
+        } finally {
+            ByteArray.zeroize(dataKeyBytes);
+        }
+    }
+}
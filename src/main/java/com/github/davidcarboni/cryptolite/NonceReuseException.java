@@ -0,0 +1,16 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown by {@link NonceTracker} when a nonce is used more than once with the same key.
+ *
+ * @author David Carboni
+ */
+public class NonceReuseException extends IllegalStateException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public NonceReuseException(String message) {
+        super(message);
+    }
+}
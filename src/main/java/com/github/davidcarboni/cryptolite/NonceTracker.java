@@ -0,0 +1,68 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+/**
+ * Detects accidental nonce reuse during the lifetime of a process.
+ * <p>
+ * Reusing a nonce with the same key is catastrophic for GCM: it can reveal the plaintext
+ * and allow forgery. Randomly generated nonces are fine, but if you're providing your own
+ * nonces (see {@link AuthenticatedCrypto#encrypt(byte[], SecretKey, byte[])}) you can set an
+ * instance of this class on {@link AuthenticatedCrypto#setNonceTracker(NonceTracker)} as a
+ * safety net in testing or staging environments.
+ * <p>
+ * Only the most recently used nonces are remembered, so memory use is bounded. Key/nonce pairs
+ * are recorded as a {@value Digest#SHA256} digest, so no key material is retained.
+ * This class is thread-safe.
+ *
+ * @author David Carboni
+ */
+public class NonceTracker {
+
+    /**
+     * The default number of nonces to remember.
+     */
+    public static final int DEFAULT_CAPACITY = 100000;
+
+    private final Map<String, Boolean> used;
+
+    /**
+     * Initialises the instance to remember up to {@value #DEFAULT_CAPACITY} nonces.
+     */
+    public NonceTracker() {
+        this(DEFAULT_CAPACITY);
+    }
+
+    /**
+     * @param capacity The maximum number of nonces to remember. Once this is reached,
+     *                 the least recently used nonce is forgotten.
+     */
+    public NonceTracker(final int capacity) {
+        if (capacity < 1) {
+            throw new IllegalArgumentException("Capacity must be at least 1, but got " + capacity);
+        }
+        used = new LinkedHashMap<String, Boolean>(16, 0.75f, true) {
+            @Override
+            protected boolean removeEldestEntry(Map.Entry<String, Boolean> eldest) {
+                return size() > capacity;
+            }
+        };
+    }
+
+    /**
+     * Records the use of a nonce with a key.
+     *
+     * @param key   The key the nonce is being used with.
+     * @param nonce The nonce.
+     * @throws NonceReuseException If the nonce has already been used with this key.
+     */
+    public synchronized void record(SecretKey key, byte[] nonce) {
+        String fingerprint = Digest.sha256Hex(ByteArray.concat(key.getEncoded(), nonce));
+        if (used.put(fingerprint, Boolean.TRUE) != null) {
+            throw new NonceReuseException("Nonce reuse detected: " + ByteArray.toHex(nonce)
+                    + " has already been used with this key.");
+        }
+    }
+}
@@ -2,9 +2,26 @@ package com.github.davidcarboni.cryptolite;
 
 import org.apache.commons.lang.ArrayUtils;
 import org.apache.commons.lang.StringUtils;
+import org.bouncycastle.crypto.generators.Argon2BytesGenerator;
+import org.bouncycastle.crypto.params.Argon2Parameters;
 
+import java.io.Console;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.PrintStream;
+import java.io.PushbackReader;
+import java.io.Reader;
+import java.nio.ByteBuffer;
+import java.nio.CharBuffer;
+import java.nio.charset.CharsetDecoder;
+import java.nio.charset.CodingErrorAction;
+import java.nio.charset.StandardCharsets;
 import java.security.Key;
+import java.security.MessageDigest;
 import java.util.Arrays;
+import java.util.concurrent.TimeUnit;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
 
 /**
  * This class provides password hashing and verification. The returned hashes
@@ -14,7 +31,7 @@ import java.util.Arrays;
  * produced by {@link #hash(String)}.
  * <p>
  * This password hashing and verification is done in the same way as Jasypt, but
- * uses {@value #ALGORITHM}, rather than MD5.
+ * uses {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM}, rather than MD5.
  *
  * @author David Carboni
  */
@@ -22,7 +39,11 @@ public class Password {
 
     /**
      * The password hashing function.
+     *
+     * @deprecated This doesn't reflect the function {@link #hash(String)} actually uses, which is
+     * {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} (see {@link Keys#SYMMETRIC_PASSWORD_ALGORITHM}).
      */
+    @Deprecated
     public static final String ALGORITHM = "PBKDF2WithHmacSHA1";
 
     /**
@@ -32,11 +53,107 @@ public class Password {
 
     /**
      * The number of bytes to produce in the hash.
+     *
+     * @deprecated This is a number of bits, not bytes, and {@link #hash(String)} actually produces a hash
+     * of {@link Keys#SYMMETRIC_KEY_SIZE} bits.
      */
+    @Deprecated
     public static final int HASH_SIZE = 256;
 
     /**
-     * Produces a good hash of the given password, using {@value #ALGORITHM}, an
+     * The prefix of hashes produced by {@link #hash(String, int)}.
+     */
+    public static final String PBKDF2_PREFIX = "$pbkdf2-sha256$";
+
+    /**
+     * The number of bytes to produce in a hash from {@link #hash(String, int)}.
+     */
+    public static final int PBKDF2_HASH_BYTES = 32;
+
+    /**
+     * The shortest hash, in bytes, that {@link #hash(String, int, int)} will produce.
+     */
+    public static final int PBKDF2_MIN_HASH_BYTES = 16;
+
+    /**
+     * The longest hash, in bytes, that {@link #hash(String, int, int)} will produce, or {@link #verify(String, String)}
+     * will accept. Each additional 32 bytes costs a full set of iterations, so this stops a tampered hash from tying
+     * up the CPU.
+     */
+    public static final int PBKDF2_MAX_HASH_BYTES = 128;
+
+    /**
+     * The largest iteration count {@link #hash(String, int)} will use, or {@link #verify(String, String)} will accept.
+     * This stops a tampered hash from tying up the CPU.
+     */
+    public static final int PBKDF2_MAX_ITERATIONS = 10000000;
+
+    /**
+     * The name of the algorithm used by {@link #hashArgon2(String)}.
+     */
+    public static final String ARGON2_ALGORITHM = "Argon2id";
+
+    /**
+     * The prefix of hashes produced by {@link #hashArgon2(String)}.
+     */
+    public static final String ARGON2_PREFIX = "$argon2id$";
+
+    /**
+     * The Argon2id memory cost, in kibibytes, for {@link #hashArgon2(String)}.
+     */
+    public static final int ARGON2_MEMORY_KB = 19456;
+
+    /**
+     * The Argon2id iteration count for {@link #hashArgon2(String)}.
+     */
+    public static final int ARGON2_ITERATIONS = 2;
+
+    /**
+     * The Argon2id degree of parallelism for {@link #hashArgon2(String)}.
+     */
+    public static final int ARGON2_PARALLELISM = 1;
+
+    /**
+     * The number of bytes to produce in an Argon2id hash.
+     */
+    public static final int ARGON2_HASH_BYTES = 32;
+
+    /**
+     * The shortest Argon2id hash, in bytes, that {@link #hashArgon2(String, int)} will produce.
+     */
+    public static final int ARGON2_MIN_HASH_BYTES = 4;
+
+    /**
+     * The longest password {@link #readPassword(String)} will accept.
+     */
+    public static final int MAX_PASSWORD_LENGTH = 1024;
+
+    /**
+     * Matches a hash produced by {@link #hashArgon2(String)}, capturing the parameters, salt and hash.
+     */
+    private static final Pattern ARGON2_HASH = Pattern.compile(
+            "\\$argon2id\\$v=19\\$m=(\\d{1,9}),t=(\\d{1,9}),p=(\\d{1,9})\\$([A-Za-z0-9+/]+)\\$([A-Za-z0-9+/]+)");
+
+    /**
+     * Matches a hash produced by {@link #hash(String, int)}, capturing the iteration count, salt and hash.
+     */
+    private static final Pattern PBKDF2_PHC_HASH = Pattern.compile(
+            "\\$pbkdf2-sha256\\$i=(\\d{1,9})\\$([A-Za-z0-9+/]+)\\$([A-Za-z0-9+/]+)");
+
+    /**
+     * Matches a hash produced by {@link #hash(String)}: base-64 encoded salt and hash bytes.
+     */
+    private static final Pattern PBKDF2_HASH = Pattern.compile("[A-Za-z0-9+/]+={0,2}");
+
+    /**
+     * The iteration count to start from when calibrating.
+     */
+    private static final int CALIBRATION_ITERATIONS = 1000;
+
+    private static final String CALIBRATION_PASSWORD = "calibration";
+
+    /**
+     * Produces a good hash of the given password, using {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM}, an
      * iteration count of {@value #ITERATION_COUNT} and a random salt value of
      * {@value Generate#SALT_BYTES} bytes. The returned value is a concatenation of the
      * salt value and the password hash and this should be passed as returned to
@@ -68,13 +185,151 @@ public class Password {
         return result;
     }
 
+    /**
+     * Produces a hash of the given password with a specific iteration count, for example one chosen with
+     * {@link #calibrateIterations(long, TimeUnit)}.
+     * <p>
+     * Unlike {@link #hash(String)}, the iteration count is recorded in the hash, in the PHC string format
+     * (<code>$pbkdf2-sha256$i=...$salt$hash</code>), so you can raise it in future without invalidating
+     * existing hashes. The hash can be checked with {@link #verify(String, String)}.
+     *
+     * @param password   The password to be hashed.
+     * @param iterations The iteration count. This must be between 1 and {@value #PBKDF2_MAX_ITERATIONS}.
+     * @return The password hash, or null if the given password is null.
+     */
+    public static String hash(String password, int iterations) {
+        return hash(password, iterations, PBKDF2_HASH_BYTES);
+    }
+
+    /**
+     * Produces a hash of the given password, as per {@link #hash(String, int)}, with a specific hash length.
+     * <p>
+     * This is useful for interoperating with systems that store a fixed digest length. The length is recorded
+     * in the hash string (it's the length of the final, base-64 encoded field), so {@link #verify(String, String)}
+     * re-derives a hash of the same length.
+     *
+     * @param password   The password to be hashed.
+     * @param iterations The iteration count. This must be between 1 and {@value #PBKDF2_MAX_ITERATIONS}.
+     * @param hashBytes  The number of bytes to produce in the hash. This must be between
+     *                   {@value #PBKDF2_MIN_HASH_BYTES} and {@value #PBKDF2_MAX_HASH_BYTES}.
+     * @return The password hash, or null if the given password is null.
+     */
+    public static String hash(String password, int iterations, int hashBytes) {
+
+        if (password == null) {
+            return null;
+        }
+        if (iterations < 1 || iterations > PBKDF2_MAX_ITERATIONS) {
+            throw new IllegalArgumentException("Iteration count must be between 1 and " + PBKDF2_MAX_ITERATIONS
+                    + ", but got " + iterations);
+        }
+        if (hashBytes < PBKDF2_MIN_HASH_BYTES || hashBytes > PBKDF2_MAX_HASH_BYTES) {
+            throw new IllegalArgumentException("Hash length must be between " + PBKDF2_MIN_HASH_BYTES + " and "
+                    + PBKDF2_MAX_HASH_BYTES + " bytes, but got " + hashBytes);
+        }
+
+        String salt = Generate.salt();
+        byte[] hash = hash(password, salt, iterations, hashBytes);
+        return pbkdf2String(iterations, ByteArray.fromBase64(salt), hash);
+    }
+
+    /**
+     * Formats a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} hash as a PHC string.
+     *
+     * @param iterations The iteration count.
+     * @param salt       The salt.
+     * @param hash       The hash.
+     * @return <code>$pbkdf2-sha256$i=...$salt$hash</code>
+     */
+    static String pbkdf2String(int iterations, byte[] salt, byte[] hash) {
+        return PBKDF2_PREFIX + "i=" + iterations + "$" + unpadded(salt) + "$" + unpadded(hash);
+    }
+
+    /**
+     * Produces a hash of the given password using Argon2id, in the standard PHC string format
+     * (<code>$argon2id$v=19$m=...,t=...,p=...$salt$hash</code>).
+     * <p>
+     * Argon2id is memory-hard, which makes brute-force attacks with specialised hardware much
+     * more expensive than with PBKDF2. The hash can be checked with {@link #verify(String, String)}.
+     * The parameters are stored in the hash, so they can be changed in future without
+     * invalidating existing hashes.
+     *
+     * @param password The password to be hashed.
+     * @return The password hash, or null if the given password is null.
+     */
+    public static String hashArgon2(String password) {
+        return hashArgon2(password, ARGON2_HASH_BYTES);
+    }
+
+    /**
+     * Produces an Argon2id hash of the given password, as per {@link #hashArgon2(String)}, with a specific hash length.
+     * <p>
+     * This is useful for interoperating with systems that store a fixed digest length. The length is recorded
+     * in the hash string (it's the length of the final, base-64 encoded field), so {@link #verify(String, String)}
+     * re-derives a hash of the same length.
+     *
+     * @param password  The password to be hashed.
+     * @param hashBytes The number of bytes to produce in the hash. This must be at least {@value #ARGON2_MIN_HASH_BYTES}.
+     * @return The password hash, or null if the given password is null.
+     */
+    public static String hashArgon2(String password, int hashBytes) {
+
+        if (password == null) {
+            return null;
+        }
+        if (hashBytes < ARGON2_MIN_HASH_BYTES) {
+            throw new IllegalArgumentException("Argon2id hash length must be at least " + ARGON2_MIN_HASH_BYTES
+                    + " bytes, but got " + hashBytes);
+        }
+
+        byte[] salt = Generate.byteArray(Generate.SALT_BYTES);
+        byte[] hash = argon2(password, salt, ARGON2_MEMORY_KB, ARGON2_ITERATIONS, ARGON2_PARALLELISM, hashBytes);
+        return argon2String(new KdfParameters(ARGON2_MEMORY_KB, ARGON2_ITERATIONS, ARGON2_PARALLELISM), salt, hash);
+    }
+
+    /**
+     * Formats an Argon2id hash as a PHC string.
+     *
+     * @param parameters The cost parameters.
+     * @param salt       The salt.
+     * @param hash       The hash.
+     * @return <code>$argon2id$v=19$m=...,t=...,p=...$salt$hash</code>
+     */
+    static String argon2String(KdfParameters parameters, byte[] salt, byte[] hash) {
+        return ARGON2_PREFIX + "v=19$m=" + parameters.getMemoryKb() + ",t=" + parameters.getIterations()
+                + ",p=" + parameters.getParallelism() + "$" + unpadded(salt) + "$" + unpadded(hash);
+    }
+
+    /**
+     * Verifies the given plaintext password and, if the stored hash uses a deprecated algorithm,
+     * provides an upgraded hash.
+     * <p>
+     * This lets you migrate existing hashes produced by {@link #hash(String)} to Argon2id
+     * (see {@link #hashArgon2(String)}) as users log in: if the result has an upgraded hash,
+     * store it in place of the old one.
+     *
+     * @param password A plaintext password.
+     * @param hash     A value previously produced by {@link #hash(String)} or {@link #hashArgon2(String)}.
+     * @return The result of the verification, including an upgraded hash if one is needed.
+     */
+    public static PasswordVerification verifyAndUpgrade(String password, String hash) {
+
+        boolean valid = verify(password, hash);
+        String upgradedHash = null;
+        if (valid && !isArgon2(hash)) {
+            upgradedHash = hashArgon2(password);
+        }
+        return new PasswordVerification(valid, upgradedHash);
+    }
+
     /**
      * Verifies the given plaintext password against a value that
-     * {@link #hash(String)} produced.
+     * {@link #hash(String)}, {@link #hash(String, int)} or {@link #hashArgon2(String)} produced.
      *
      * @param password A plaintext password. If this is null, false will be returned.
-     * @param hash     A value previously produced by {@link #hash(String)}. If this
-     *                 is empty or shorter than expected, false will be returned.
+     * @param hash     A value previously produced by {@link #hash(String)}, {@link #hash(String, int)} or
+     *                 {@link #hashArgon2(String)}. If this is empty, shorter than expected or has
+     *                 unsupported parameters, false will be returned.
      * @return If the password hashes to the same value as that contained in the
      * hash parameter, true.
      */
@@ -82,7 +337,11 @@ public class Password {
 
         boolean result = false;
 
-        if (StringUtils.isNotBlank(hash) && password != null) {
+        if (isArgon2(hash) && password != null) {
+            result = verifyArgon2(password, hash);
+        } else if (isPbkdf2(hash) && password != null) {
+            result = verifyPbkdf2(password, hash);
+        } else if (StringUtils.isNotBlank(hash) && password != null) {
             // Get the salt and hash from the input string:
             byte[] bytes = ByteArray.fromBase64(hash);
 
@@ -94,18 +353,440 @@ public class Password {
                 String salt = getSalt(bytes);
                 byte[] existingHash = getHash(bytes);
 
-                // Hash the password with the same salt in order to get the same
-                // result:
-                byte[] comparisonHash = hash(password, salt);
+                // Hash the password with the same salt and length in order to get the same
+                // result (the length depends on the key size in use when the hash was produced):
+                if (isSupportedHashLength(existingHash.length)) {
+                    byte[] comparisonHash = hash(password, salt, Keys.SYMMETRIC_PASSWORD_ITERATIONS, existingHash.length);
 
-                // See whether they match:
-                result = Arrays.equals(existingHash, comparisonHash);
+                    // See whether they match:
+                    result = Arrays.equals(existingHash, comparisonHash);
+                }
             }
         }
 
         return result;
     }
 
+    /**
+     * Reads a password, up to the end of the line, into the given buffer without creating a String.
+     * <p>
+     * Strings are immutable, so a password held in a String can't be wiped. Reading into a char array
+     * means you can wipe the password with {@link ByteArray#zeroize(char[])} as soon as you've used it,
+     * for example with {@link Keys#generateSecretKey(char[], String)}. There's deliberately no way
+     * to wipe a String: doing so would rely on JVM internals and could corrupt other Strings.
+     *
+     * @param reader The source of the password. This is read up to and including the end of the line.
+     * @param buffer The buffer to read into. Make this at least as long as the longest password you'll accept.
+     * @return The number of characters read into the buffer, not including the line ending.
+     * @throws IOException              If an error occurs reading the password.
+     * @throws IllegalArgumentException If the password is longer than the buffer.
+     */
+    public static int readPassword(Reader reader, char[] buffer) throws IOException {
+
+        int length = 0;
+        int c;
+        while ((c = reader.read()) != -1 && c != '\n') {
+            if (c == '\r') {
+                continue;
+            }
+            if (length == buffer.length) {
+                ByteArray.zeroize(buffer);
+                throw new IllegalArgumentException("Password is longer than the buffer (" + buffer.length + " characters).");
+            }
+            buffer[length++] = (char) c;
+        }
+        return length;
+    }
+
+    /**
+     * Prompts for a password and reads it from the terminal without echoing it.
+     * <p>
+     * The password is returned as a char array, rather than a String, so you can wipe it with
+     * {@link ByteArray#zeroize(char[])} as soon as you've used it.
+     * <p>
+     * If there's no terminal (for example, if input is piped in) the prompt is written to standard error
+     * and the password is read from standard input, up to the end of the line.
+     *
+     * @param prompt The prompt to display, for example "Password: ".
+     * @return The password, not including the line ending, or null if the end of the input has been reached.
+     * @throws IOException              If an error occurs reading the password.
+     * @throws IllegalArgumentException If the password is longer than {@value #MAX_PASSWORD_LENGTH} characters.
+     */
+    public static char[] readPassword(String prompt) throws IOException {
+
+        Console console = System.console();
+        if (console != null) {
+            return console.readPassword("%s", prompt);
+        }
+        return readPassword(prompt, System.in, System.err);
+    }
+
+    /**
+     * Writes the prompt and reads a password from the given stream, decoded as UTF-8.
+     * <p>
+     * The stream is read one byte at a time, so nothing after the end of the line is consumed. Wrapping it
+     * in an {@link java.io.InputStreamReader} would read ahead, swallowing any following lines (for example,
+     * a confirmation of the password) and leaving them in a buffer that can't be wiped.
+     *
+     * @param prompt The prompt to display.
+     * @param in     The source of the password.
+     * @param out    Where to write the prompt.
+     * @return The password, or null if the end of the input has been reached.
+     * @throws IOException If an error occurs reading the password.
+     */
+    static char[] readPassword(String prompt, InputStream in, PrintStream out) throws IOException {
+        UnbufferedReader reader = new UnbufferedReader(in);
+        try {
+            return readPassword(prompt, reader, out);
+        } finally {
+            reader.close();
+        }
+    }
+
+    /**
+     * Writes the prompt and reads a password from the given reader.
+     *
+     * @param prompt The prompt to display.
+     * @param reader The source of the password.
+     * @param out    Where to write the prompt.
+     * @return The password, or null if the end of the input has been reached.
+     * @throws IOException If an error occurs reading the password.
+     */
+    static char[] readPassword(String prompt, Reader reader, PrintStream out) throws IOException {
+
+        out.print(prompt);
+        out.flush();
+
+        // Use a buffer, rather than a BufferedReader, so there's no String to linger in memory:
+        char[] buffer = new char[MAX_PASSWORD_LENGTH];
+        try {
+            PushbackReader pushback = new PushbackReader(reader);
+            int c = pushback.read();
+            if (c == -1) {
+                return null;
+            }
+            pushback.unread(c);
+            int length = readPassword(pushback, buffer);
+            return Arrays.copyOf(buffer, length);
+        } finally {
+            ByteArray.zeroize(buffer);
+        }
+    }
+
+    /**
+     * Checks whether the given value is in a format this class can verify.
+     * <p>
+     * This is useful if you're importing hashes from another system: hashes this returns false
+     * for (for example, bare MD5 values) can be routed to a migration path instead of
+     * {@link #verify(String, String)}, which would simply return false for every password.
+     *
+     * @param hash A password hash.
+     * @return If the hash looks like one produced by {@link #hash(String)} or {@link #hashArgon2(String)}, true.
+     * This accepts {@link #hash(String)} values produced with either {@link Keys#useStrongKeys()} or
+     * {@link Keys#useStandardKeys()}, and Argon2id values whose parameters {@link #verify(String, String)} accepts.
+     */
+    public static boolean canVerify(String hash) {
+
+        if (isArgon2(hash)) {
+            return parseArgon2(hash) != null;
+        }
+        if (isPbkdf2(hash)) {
+            return parsePbkdf2(hash) != null;
+        }
+
+        if (hash == null || !PBKDF2_HASH.matcher(hash).matches()) {
+            return false;
+        }
+        return isKeySizeHash(ByteArray.fromBase64(hash).length - Generate.SALT_BYTES);
+    }
+
+    /**
+     * Separates a value that {@link #hash(String)} or {@link #hashArgon2(String)} produced into its components.
+     *
+     * @param hash A value previously produced by {@link #hash(String)} or {@link #hashArgon2(String)}.
+     * @return A {@link PasswordHash} containing the salt and hash, or null if the given value is blank.
+     * @throws IllegalArgumentException If the value is shorter than expected, or is an Argon2id hash
+     *                                  that {@link #verify(String, String)} wouldn't accept.
+     */
+    public static PasswordHash parse(String hash) {
+
+        if (StringUtils.isBlank(hash)) {
+            return null;
+        }
+
+        if (isArgon2(hash)) {
+            PasswordHash result = parseArgon2(hash);
+            if (result == null) {
+                throw new IllegalArgumentException("Are you sure this is an Argon2id hash? The format, parameters "
+                        + "or hash length aren't supported.");
+            }
+            return result;
+        }
+        if (isPbkdf2(hash)) {
+            PasswordHash result = parsePbkdf2(hash);
+            if (result == null) {
+                throw new IllegalArgumentException("Are you sure this is a password hash? The iteration count "
+                        + "or hash length aren't supported.");
+            }
+            return result;
+        }
+
+        byte[] bytes = ByteArray.fromBase64(hash);
+        if (bytes.length < Generate.SALT_BYTES) {
+            throw new IllegalArgumentException("Are you sure this is a password hash? Byte length (" + bytes.length
+                    + ") is shorter than a salt value.");
+        }
+
+        return new PasswordHash(getSalt(bytes), ByteArray.toBase64(getHash(bytes)));
+    }
+
+    /**
+     * Estimates the average time an attacker would need to guess a password by brute force.
+     * <p>
+     * This is a rough figure for security reviews. On average, an attacker has to try half of the
+     * possible passwords, so the estimate is <code>2^(entropyBits - 1) / hashesPerSecond</code>.
+     * You can get the entropy of a generated password from {@link Generate#passwordEntropyBits(int, int)}.
+     * For the hash rate, bear in mind that attackers use specialised hardware: a figure derived from
+     * {@link #calibrateIterations(long, TimeUnit)} on your own server is an optimistic lower bound.
+     *
+     * @param entropyBits     The entropy of the password, in bits.
+     * @param hashesPerSecond The number of password guesses the attacker can check per second.
+     * @param unit            The unit of the returned time.
+     * @return The estimated average time to guess the password, or {@link Long#MAX_VALUE} if it's too long to represent.
+     */
+    public static long estimateCrackTime(double entropyBits, double hashesPerSecond, TimeUnit unit) {
+
+        if (entropyBits < 0 || hashesPerSecond <= 0) {
+            throw new IllegalArgumentException("Please specify non-negative entropy and a positive hash rate.");
+        }
+
+        double attempts = Math.pow(2, Math.max(0, entropyBits - 1));
+        double nanos = attempts / hashesPerSecond * TimeUnit.SECONDS.toNanos(1);
+        double result = nanos / unit.toNanos(1);
+        return result >= Long.MAX_VALUE ? Long.MAX_VALUE : (long) result;
+    }
+
+    /**
+     * Measures how many {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} iterations can be computed in the
+     * given time on the current machine.
+     * <p>
+     * This lets you choose an iteration count that hits a target latency (for example, around 250ms)
+     * on your production hardware, rather than guessing. The result is an estimate, so run this on the
+     * hardware you'll deploy to, ideally when it's not under load, and round the result to a sensible value.
+     * Pass the result to {@link #hash(String, int)}, which records it in the hash.
+     *
+     * @param target The target time for deriving a key.
+     * @param unit   The unit of the target time.
+     * @return The estimated number of iterations that fit in the target time. This is at least 1.
+     */
+    public static int calibrateIterations(long target, TimeUnit unit) {
+
+        long targetNanos = unit.toNanos(target);
+        if (targetNanos <= 0) {
+            throw new IllegalArgumentException("Please specify a positive target time.");
+        }
+
+        String salt = Generate.salt();
+        int iterations = CALIBRATION_ITERATIONS;
+
+        // Warm up, so that JIT compilation doesn't skew the measurement:
+        Keys.generateSecretKey(CALIBRATION_PASSWORD, salt, iterations);
+
+        // Double the iterations until the measurement is long enough to be meaningful:
+        long elapsed;
+        do {
+            long start = System.nanoTime();
+            Keys.generateSecretKey(CALIBRATION_PASSWORD, salt, iterations);
+            elapsed = Math.max(System.nanoTime() - start, 1);
+            if (elapsed < targetNanos / 4 && iterations <= Integer.MAX_VALUE / 2) {
+                iterations *= 2;
+            } else {
+                break;
+            }
+        } while (true);
+
+        // Scale up (or down) to the target:
+        double result = (double) iterations * targetNanos / elapsed;
+        return (int) Math.max(1, Math.min(Integer.MAX_VALUE, result));
+    }
+
+    /**
+     * @param hash A password hash.
+     * @return If the hash was produced by {@link #hashArgon2(String)}, true.
+     */
+    private static boolean isArgon2(String hash) {
+        return StringUtils.startsWith(hash, ARGON2_PREFIX);
+    }
+
+    /**
+     * @param hashBytes The length of a hash produced by {@link #hash(String)}.
+     * @return If the length matches {@link Keys#useStrongKeys()} or {@link Keys#useStandardKeys()}, true.
+     */
+    private static boolean isKeySizeHash(int hashBytes) {
+        return hashBytes == 256 / 8 || hashBytes == 128 / 8;
+    }
+
+    /**
+     * @param hashBytes The length of a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} hash.
+     * @return If the length is between {@value #PBKDF2_MIN_HASH_BYTES} and {@value #PBKDF2_MAX_HASH_BYTES} bytes, true.
+     */
+    private static boolean isSupportedHashLength(int hashBytes) {
+        return hashBytes >= PBKDF2_MIN_HASH_BYTES && hashBytes <= PBKDF2_MAX_HASH_BYTES;
+    }
+
+    /**
+     * @param hash A password hash.
+     * @return If the hash was produced by {@link #hash(String, int)}, true.
+     */
+    private static boolean isPbkdf2(String hash) {
+        return StringUtils.startsWith(hash, PBKDF2_PREFIX);
+    }
+
+    /**
+     * Parses a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} PHC string, checking the iteration count
+     * against {@value #PBKDF2_MAX_ITERATIONS}.
+     *
+     * @param hash The hash.
+     * @return The components of the hash, or null if the format, iteration count or hash length are invalid.
+     */
+    private static PasswordHash parsePbkdf2(String hash) {
+
+        Matcher matcher = PBKDF2_PHC_HASH.matcher(hash);
+        if (!matcher.matches()) {
+            return null;
+        }
+
+        int iterations = Integer.parseInt(matcher.group(1));
+        if (iterations < 1 || iterations > PBKDF2_MAX_ITERATIONS) {
+            return null;
+        }
+        byte[] salt = ByteArray.fromBase64(matcher.group(2));
+        byte[] existingHash = ByteArray.fromBase64(matcher.group(3));
+        if (salt.length == 0 || !isSupportedHashLength(existingHash.length)) {
+            return null;
+        }
+
+        return new PasswordHash(ByteArray.toBase64(salt), ByteArray.toBase64(existingHash), iterations);
+    }
+
+    /**
+     * Verifies a password against a hash produced by {@link #hash(String, int)}.
+     *
+     * @param password The plaintext password.
+     * @param hash     The hash.
+     * @return If the password matches the hash, true. If the hash is invalid, false.
+     */
+    private static boolean verifyPbkdf2(String password, String hash) {
+
+        PasswordHash parsed = parsePbkdf2(hash);
+        if (parsed == null) {
+            return false;
+        }
+
+        byte[] existingHash = ByteArray.fromBase64(parsed.getHash());
+        byte[] comparisonHash = hash(password, parsed.getSalt(), parsed.getIterations(), existingHash.length);
+        return MessageDigest.isEqual(existingHash, comparisonHash);
+    }
+
+    /**
+     * Parses an Argon2id PHC string.
+     * <p>
+     * The parameters come from stored data, so they're checked against {@link KdfParameters#maximum()}:
+     * otherwise a tampered hash could make verification allocate gigabytes of memory or run for hours.
+     *
+     * @param hash The Argon2id hash.
+     * @return The components of the hash, or null if the format, parameters or hash length are invalid.
+     */
+    private static PasswordHash parseArgon2(String hash) {
+
+        Matcher matcher = ARGON2_HASH.matcher(hash);
+        if (!matcher.matches()) {
+            return null;
+        }
+
+        KdfParameters parameters;
+        try {
+            parameters = new KdfParameters(Integer.parseInt(matcher.group(1)),
+                    Integer.parseInt(matcher.group(2)), Integer.parseInt(matcher.group(3)));
+        } catch (IllegalArgumentException e) {
+            return null;
+        }
+        if (!parameters.isWithin(KdfParameters.maximum())) {
+            return null;
+        }
+
+        byte[] salt = ByteArray.fromBase64(matcher.group(4));
+        byte[] existingHash = ByteArray.fromBase64(matcher.group(5));
+        if (existingHash.length < ARGON2_MIN_HASH_BYTES) {
+            return null;
+        }
+
+        return new PasswordHash(ByteArray.toBase64(salt), ByteArray.toBase64(existingHash), parameters);
+    }
+
+    /**
+     * Verifies a password against a hash produced by {@link #hashArgon2(String)}.
+     *
+     * @param password The plaintext password.
+     * @param hash     The Argon2id hash.
+     * @return If the password matches the hash, true. If the hash is invalid, false.
+     */
+    private static boolean verifyArgon2(String password, String hash) {
+
+        PasswordHash parsed = parseArgon2(hash);
+        if (parsed == null) {
+            return false;
+        }
+
+        KdfParameters parameters = parsed.getArgon2Parameters();
+        byte[] salt = ByteArray.fromBase64(parsed.getSalt());
+        byte[] existingHash = ByteArray.fromBase64(parsed.getHash());
+
+        byte[] comparisonHash;
+        try {
+            comparisonHash = argon2(password, salt, parameters.getMemoryKb(), parameters.getIterations(),
+                    parameters.getParallelism(), existingHash.length);
+        } catch (IllegalArgumentException | IllegalStateException e) {
+            // Bouncy Castle rejects some parameter combinations (e.g. a short salt) that the format allows:
+            return false;
+        }
+        return MessageDigest.isEqual(existingHash, comparisonHash);
+    }
+
+    /**
+     * Computes an Argon2id hash.
+     *
+     * @param password    The plaintext password.
+     * @param salt        The salt.
+     * @param memoryKb    The memory cost, in kibibytes.
+     * @param iterations  The number of iterations.
+     * @param parallelism The degree of parallelism.
+     * @param length      The hash length, in bytes.
+     * @return The hash.
+     */
+    private static byte[] argon2(String password, byte[] salt, int memoryKb, int iterations, int parallelism, int length) {
+        Argon2Parameters parameters = new Argon2Parameters.Builder(Argon2Parameters.ARGON2_id)
+                .withVersion(Argon2Parameters.ARGON2_VERSION_13)
+                .withSalt(salt)
+                .withMemoryAsKB(memoryKb)
+                .withIterations(iterations)
+                .withParallelism(parallelism)
+                .build();
+        Argon2BytesGenerator generator = new Argon2BytesGenerator();
+        generator.init(parameters);
+        byte[] result = new byte[length];
+        generator.generateBytes(ByteArray.fromString(password), result);
+        return result;
+    }
+
+    /**
+     * @param bytes The bytes to encode.
+     * @return Base-64 without padding, as used by the PHC string format.
+     */
+    private static String unpadded(byte[] bytes) {
+        return StringUtils.stripEnd(ByteArray.toBase64(bytes), "=");
+    }
+
     /**
      * This method does the actual work of hashing a plaintext password string,
      * using {@link Keys#generateSecretKey(String, String)}.
@@ -120,6 +801,25 @@ public class Password {
         return key.getEncoded();
     }
 
+    /**
+     * Hashes a plaintext password with a specific iteration count and hash length.
+     *
+     * @param password   The plaintext password.
+     * @param salt       The salt value to use in the hash.
+     * @param iterations The iteration count.
+     * @param hashBytes  The number of bytes to produce.
+     * @return The hash of the password.
+     */
+    private static byte[] hash(String password, String salt, int iterations, int hashBytes) {
+
+        char[] chars = password.toCharArray();
+        try {
+            return Keys.generateSecretKey(chars, salt, iterations, hashBytes * 8).getEncoded();
+        } finally {
+            ByteArray.zeroize(chars);
+        }
+    }
+
     /**
      * Converts the given password to a char array.
      * <p>
@@ -167,4 +867,68 @@ public class Password {
         return hash;
     }
 
+    /**
+     * Decodes UTF-8 from a stream without reading ahead, so it never consumes bytes beyond the characters it has
+     * returned. Closing it wipes its buffers, but doesn't close the stream.
+     */
+    private static class UnbufferedReader extends Reader {
+
+        private final InputStream in;
+        private final CharsetDecoder decoder = StandardCharsets.UTF_8.newDecoder()
+                .onMalformedInput(CodingErrorAction.REPLACE)
+                .onUnmappableCharacter(CodingErrorAction.REPLACE);
+        // A UTF-8 sequence is at most 4 bytes, which decodes to at most 2 chars (a surrogate pair):
+        private final ByteBuffer bytes = ByteBuffer.allocate(4);
+        private final CharBuffer chars = CharBuffer.allocate(2);
+
+        UnbufferedReader(InputStream in) {
+            this.in = in;
+            chars.flip();
+        }
+
+        @Override
+        public int read(char[] buffer, int offset, int length) throws IOException {
+
+            if (length == 0) {
+                return 0;
+            }
+
+            if (!chars.hasRemaining()) {
+                chars.clear();
+                while (chars.position() == 0) {
+                    int b = in.read();
+                    if (b == -1) {
+                        if (bytes.position() == 0) {
+                            chars.flip();
+                            return -1;
+                        }
+                        // An incomplete sequence at the end of the input is decoded as a replacement character:
+                        bytes.flip();
+                        decoder.decode(bytes, chars, true);
+                        decoder.flush(chars);
+                        bytes.clear();
+                        break;
+                    }
+                    bytes.put((byte) b);
+                    bytes.flip();
+                    decoder.decode(bytes, chars, false);
+                    bytes.compact();
+                }
+                if (bytes.position() == 0) {
+                    ByteArray.zeroize(bytes.array());
+                }
+                chars.flip();
+            }
+
+            int count = Math.min(length, chars.remaining());
+            chars.get(buffer, offset, count);
+            return count;
+        }
+
+        @Override
+        public void close() {
+            ByteArray.zeroize(bytes.array());
+            ByteArray.zeroize(chars.array());
+        }
+    }
 }
@@ -0,0 +1,111 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Represents the components of a password hash produced by {@link Password#hash(String)}
+ * or {@link Password#hashArgon2(String)}.
+ * <p>
+ * Use {@link Password#parse(String)} to get an instance from a hash string. This is useful if you'd
+ * like to inspect the components, or store them individually (e.g. in separate database columns).
+ * You can reconstruct the original hash string by calling {@link #toString()}.
+ *
+ * @author David Carboni
+ */
+public class PasswordHash {
+
+    private final String salt;
+    private final String hash;
+    // Zero for the original format, which doesn't record the iteration count:
+    private final int iterations;
+    private final KdfParameters argon2Parameters;
+
+    /**
+     * @param salt The base64-encoded salt value.
+     * @param hash The base64-encoded password hash.
+     */
+    public PasswordHash(String salt, String hash) {
+        this(salt, hash, 0, null);
+    }
+
+    /**
+     * Represents a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} hash with a specific iteration count,
+     * as produced by {@link Password#hash(String, int)}.
+     *
+     * @param salt       The base64-encoded salt value.
+     * @param hash       The base64-encoded password hash.
+     * @param iterations The iteration count.
+     */
+    public PasswordHash(String salt, String hash, int iterations) {
+        this(salt, hash, iterations, null);
+    }
+
+    /**
+     * @param salt             The base64-encoded salt value.
+     * @param hash             The base64-encoded password hash.
+     * @param argon2Parameters The Argon2id cost parameters, or null for a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} hash.
+     */
+    public PasswordHash(String salt, String hash, KdfParameters argon2Parameters) {
+        this(salt, hash, 0, argon2Parameters);
+    }
+
+    private PasswordHash(String salt, String hash, int iterations, KdfParameters argon2Parameters) {
+        this.salt = salt;
+        this.hash = hash;
+        this.iterations = iterations;
+        this.argon2Parameters = argon2Parameters;
+    }
+
+    /**
+     * @return The password hashing algorithm: {@value Password#ARGON2_ALGORITHM} or {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM}.
+     */
+    public String getAlgorithm() {
+        return argon2Parameters != null ? Password.ARGON2_ALGORITHM : Keys.SYMMETRIC_PASSWORD_ALGORITHM;
+    }
+
+    /**
+     * @return The iteration count for the hashing algorithm. For a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM}
+     * hash produced by {@link Password#hash(String)} this is {@value Keys#SYMMETRIC_PASSWORD_ITERATIONS}.
+     */
+    public int getIterations() {
+        if (argon2Parameters != null) {
+            return argon2Parameters.getIterations();
+        }
+        return iterations != 0 ? iterations : Keys.SYMMETRIC_PASSWORD_ITERATIONS;
+    }
+
+    /**
+     * @return The Argon2id cost parameters, or null if this is a {@value Keys#SYMMETRIC_PASSWORD_ALGORITHM} hash.
+     */
+    public KdfParameters getArgon2Parameters() {
+        return argon2Parameters;
+    }
+
+    /**
+     * @return The base64-encoded salt value.
+     */
+    public String getSalt() {
+        return salt;
+    }
+
+    /**
+     * @return The base64-encoded password hash.
+     */
+    public String getHash() {
+        return hash;
+    }
+
+    /**
+     * @return The hash string, in the format produced by {@link Password#hash(String)},
+     * {@link Password#hash(String, int)} or {@link Password#hashArgon2(String)}.
+     */
+    @Override
+    public String toString() {
+        if (argon2Parameters != null) {
+            return Password.argon2String(argon2Parameters, ByteArray.fromBase64(salt), ByteArray.fromBase64(hash));
+        }
+        if (iterations != 0) {
+            return Password.pbkdf2String(iterations, ByteArray.fromBase64(salt), ByteArray.fromBase64(hash));
+        }
+        byte[] concatenated = ByteArray.concat(ByteArray.fromBase64(salt), ByteArray.fromBase64(hash));
+        return ByteArray.toBase64(concatenated);
+    }
+}
@@ -0,0 +1,36 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * The result of {@link Password#verifyAndUpgrade(String, String)}.
+ *
+ * @author David Carboni
+ */
+public class PasswordVerification {
+
+    private final boolean valid;
+    private final String upgradedHash;
+
+    /**
+     * @param valid        Whether the password matched the hash.
+     * @param upgradedHash A replacement hash, or null if no upgrade is needed.
+     */
+    public PasswordVerification(boolean valid, String upgradedHash) {
+        this.valid = valid;
+        this.upgradedHash = upgradedHash;
+    }
+
+    /**
+     * @return If the password matched the hash, true.
+     */
+    public boolean isValid() {
+        return valid;
+    }
+
+    /**
+     * @return If the password was valid and the stored hash used a deprecated algorithm,
+     * a new hash of the password that you should store in place of the old one. Otherwise, null.
+     */
+    public String getUpgradedHash() {
+        return upgradedHash;
+    }
+}
@@ -0,0 +1,20 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Implement this interface to use decrypted data without keeping a copy
+ * (see {@link AuthenticatedCrypto#decryptScoped(byte[], javax.crypto.SecretKey, PlaintextConsumer)}).
+ * <p>
+ * This keeps the lifetime of sensitive plaintext as short as possible.
+ *
+ * @author David Carboni
+ */
+public interface PlaintextConsumer {
+
+    /**
+     * Called with the decrypted data. These are wiped as soon as this method returns,
+     * so don't keep a reference to them.
+     *
+     * @param plaintext The decrypted data.
+     */
+    void accept(byte[] plaintext);
+}
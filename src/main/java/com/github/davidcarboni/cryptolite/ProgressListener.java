@@ -0,0 +1,19 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Implement this interface to receive progress updates from streaming encryption
+ * (see {@link Crypto#encrypt(java.io.OutputStream, javax.crypto.SecretKey, ProgressListener)}).
+ * <p>
+ * This is useful for rendering a progress bar when encrypting large files.
+ *
+ * @author David Carboni
+ */
+public interface ProgressListener {
+
+    /**
+     * Called periodically as data are written and once more when the stream is closed.
+     *
+     * @param bytesProcessed The total number of plaintext bytes processed so far.
+     */
+    void onProgress(long bytesProcessed);
+}
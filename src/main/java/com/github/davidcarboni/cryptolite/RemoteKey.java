@@ -0,0 +1,31 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Implement this interface to use a key held by a key management service (KMS) or hardware security module (HSM)
+ * with {@link Envelope}.
+ * <p>
+ * The remote key never leaves the service: it's only used to wrap and unwrap data keys, which are small.
+ * Bulk encryption happens locally with the data key, so only a single short request to the service is needed
+ * for each message, however large it is.
+ *
+ * @author David Carboni
+ */
+public interface RemoteKey {
+
+    /**
+     * Encrypts a data key with the remote key, for example by calling a KMS "encrypt" operation.
+     *
+     * @param dataKey The raw data key bytes. These are wiped after this method returns, so don't keep a reference to them.
+     * @return The wrapped data key, which will be stored alongside the encrypted message.
+     */
+    byte[] wrap(byte[] dataKey);
+
+    /**
+     * Decrypts a data key with the remote key, for example by calling a KMS "decrypt" operation.
+     *
+     * @param wrappedDataKey A wrapped data key, as returned by {@link #wrap(byte[])}.
+     * @return The raw data key bytes. These are wiped once the message has been decrypted.
+     * @throws RuntimeException If the data key can't be unwrapped. Any exception is passed on to the caller.
+     */
+    byte[] unwrap(byte[] wrappedDataKey);
+}
@@ -0,0 +1,116 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.SecretKey;
+import java.util.Date;
+import java.util.regex.Matcher;
+import java.util.regex.Pattern;
+
+/**
+ * An application's root encryption key, together with the bookkeeping needed to manage it:
+ * an identifier and the time it was created.
+ * <p>
+ * This is returned by {@link Keys#newRootKey()}. Use {@link #toJson()} to serialise it for secure storage
+ * (for example, a secrets manager) and {@link #fromJson(String)} to load it again. The JSON contains the key
+ * itself, base-64 encoded, so it must be protected accordingly.
+ *
+ * @author David Carboni
+ */
+public class RootKey {
+
+    /**
+     * Matches a <code>"name": "value"</code> or <code>"name": number</code> pair in a JSON object.
+     */
+    private static final Pattern FIELD = Pattern.compile("\"([^\"\\\\]*)\"\\s*:\\s*(?:\"([^\"\\\\]*)\"|(-?[0-9]+))");
+
+    private final SecretKey key;
+    private final String keyId;
+    private final Date createdAt;
+
+    /**
+     * @param key       The key.
+     * @param keyId     The identifier for the key.
+     * @param createdAt The time the key was created.
+     */
+    public RootKey(SecretKey key, String keyId, Date createdAt) {
+        this.key = key;
+        this.keyId = keyId;
+        this.createdAt = new Date(createdAt.getTime());
+    }
+
+    /**
+     * @return The key.
+     */
+    public SecretKey getKey() {
+        return key;
+    }
+
+    /**
+     * @return The identifier for the key. This is random, rather than derived from the key, so it reveals nothing about it.
+     */
+    public String getKeyId() {
+        return keyId;
+    }
+
+    /**
+     * @return The time the key was created.
+     */
+    public Date getCreatedAt() {
+        return new Date(createdAt.getTime());
+    }
+
+    /**
+     * Serialises this root key as JSON, with the fields <code>kid</code>, <code>createdAt</code>
+     * (milliseconds since the epoch) and <code>key</code> (base-64 encoded).
+     *
+     * @return A JSON representation of this root key, including the key itself.
+     */
+    public String toJson() {
+        return "{\"kid\":\"" + keyId + "\"," +
+                "\"createdAt\":" + createdAt.getTime() + "," +
+                "\"key\":\"" + ByteArray.toBase64(key.getEncoded()) + "\"}";
+    }
+
+    /**
+     * Loads a root key serialised by {@link #toJson()}.
+     *
+     * @param json A JSON representation of a root key.
+     * @return The root key, or null if the given JSON is null.
+     * @throws IllegalArgumentException If a field is missing or the key is not a valid length.
+     */
+    public static RootKey fromJson(String json) {
+
+        if (json == null) {
+            return null;
+        }
+
+        String keyId = null;
+        String createdAt = null;
+        String key = null;
+        Matcher matcher = FIELD.matcher(json);
+        while (matcher.find()) {
+            String value = matcher.group(2) != null ? matcher.group(2) : matcher.group(3);
+            switch (matcher.group(1)) {
+                case "kid":
+                    keyId = value;
+                    break;
+                case "createdAt":
+                    createdAt = value;
+                    break;
+                case "key":
+                    key = value;
+                    break;
+                default:
+                    // Ignore any other fields
+            }
+        }
+
+        if (keyId == null || createdAt == null || key == null) {
+            throw new IllegalArgumentException("Are you sure this is a root key? Expected 'kid', 'createdAt' and 'key' fields.");
+        }
+        try {
+            return new RootKey(Keys.secretKeyFromBytes(ByteArray.fromBase64(key)), keyId, new Date(Long.parseLong(createdAt)));
+        } catch (NumberFormatException e) {
+            throw new IllegalArgumentException("Are you sure this is a root key? Unable to read 'createdAt': " + createdAt, e);
+        }
+    }
+}
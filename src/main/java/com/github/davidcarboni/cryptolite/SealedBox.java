@@ -0,0 +1,284 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.bouncycastle.crypto.agreement.X25519Agreement;
+import org.bouncycastle.crypto.digests.Blake2bDigest;
+import org.bouncycastle.crypto.engines.Salsa20Engine;
+import org.bouncycastle.crypto.engines.XSalsa20Engine;
+import org.bouncycastle.crypto.macs.Poly1305;
+import org.bouncycastle.crypto.params.KeyParameter;
+import org.bouncycastle.crypto.params.ParametersWithIV;
+import org.bouncycastle.crypto.params.X25519PrivateKeyParameters;
+import org.bouncycastle.crypto.params.X25519PublicKeyParameters;
+import org.bouncycastle.util.Pack;
+
+import java.security.MessageDigest;
+import java.security.SecureRandom;
+
+/**
+ * Provides "sealed boxes", compatible with libsodium's <code>crypto_box_seal</code>.
+ * <p>
+ * A sealed box encrypts a message to a recipient's public key, anonymously: the sender generates
+ * a throwaway key pair for each message, so the recipient can decrypt the message but can't tell
+ * who sent it. This is the same format produced and consumed by libsodium, libsodium.js and
+ * PyNaCl's <code>SealedBox</code>, so you can exchange messages with JavaScript or Python.
+ * <p>
+ * The construction is:
+ * <ul>
+ * <li>An ephemeral X25519 key pair is generated for each message.</li>
+ * <li>The nonce is the 24-byte BLAKE2b hash of the ephemeral public key followed by the recipient's public key.</li>
+ * <li>The message is encrypted with <code>crypto_box</code> (X25519, XSalsa20 and Poly1305).</li>
+ * <li>The output is the ephemeral public key ({@value #PUBLIC_KEY_BYTES} bytes),
+ * followed by the Poly1305 tag ({@value #TAG_BYTES} bytes) and the ciphertext.</li>
+ * </ul>
+ * Note that <code>crypto_box_seal</code> uses XSalsa20 rather than XChaCha20. Using XChaCha20 would
+ * produce a format that none of the standard libsodium bindings can open.
+ * <p>
+ * Intermediate secrets (the ephemeral private key, the shared secret and the derived keys) are
+ * zeroized before {@link #seal(byte[], byte[])} and {@link #open(byte[], byte[], byte[])} return,
+ * including when an exception is thrown, to limit how long they stay in memory.
+ * <p>
+ * Keys are raw {@value #PUBLIC_KEY_BYTES}-byte arrays, as used by libsodium. You can generate a key
+ * pair with {@link #newKeyPair()}.
+ *
+ * @author David Carboni
+ */
+public class SealedBox {
+
+    /**
+     * The size of an X25519 public key, in bytes.
+     */
+    public static final int PUBLIC_KEY_BYTES = 32;
+
+    /**
+     * The size of an X25519 private key, in bytes.
+     */
+    public static final int PRIVATE_KEY_BYTES = 32;
+
+    /**
+     * The size of the Poly1305 authentication tag, in bytes.
+     */
+    public static final int TAG_BYTES = 16;
+
+    /**
+     * The number of bytes a sealed box adds to the size of the message.
+     */
+    public static final int OVERHEAD_BYTES = PUBLIC_KEY_BYTES + TAG_BYTES;
+
+    private static final int NONCE_BYTES = 24;
+
+    // The first 32 bytes of the XSalsa20 key stream are used as the Poly1305 key:
+    private static final int POLY1305_KEY_BYTES = 32;
+
+    // "expand 32-byte k", used to initialise HSalsa20:
+    private static final int[] SIGMA = {0x61707865, 0x3320646e, 0x79622d32, 0x6b206574};
+
+    private static final SecureRandom secureRandom = new SecureRandom();
+
+    /**
+     * Generates a new X25519 key pair for receiving sealed boxes.
+     *
+     * @return A two-element array containing the {@value #PUBLIC_KEY_BYTES}-byte public key,
+     * followed by the {@value #PRIVATE_KEY_BYTES}-byte private key.
+     */
+    public static byte[][] newKeyPair() {
+        X25519PrivateKeyParameters privateKey = new X25519PrivateKeyParameters(secureRandom);
+        return new byte[][]{privateKey.generatePublicKey().getEncoded(), privateKey.getEncoded()};
+    }
+
+    /**
+     * Seals the given message to the given recipient.
+     *
+     * @param recipientPublicKey The recipient's {@value #PUBLIC_KEY_BYTES}-byte public key.
+     * @param message            The message.
+     * @return The sealed box, or null if the message is null.
+     * @throws IllegalArgumentException If the public key is not the correct length.
+     */
+    public static byte[] seal(byte[] recipientPublicKey, byte[] message) {
+
+        if (message == null) {
+            return null;
+        }
+        checkLength("public key", recipientPublicKey, PUBLIC_KEY_BYTES);
+
+        // Generate an ephemeral key pair:
+        return seal(recipientPublicKey, message, newKeyPair());
+    }
+
+    /**
+     * Seals the given message with the given ephemeral key pair. The ephemeral private key is zeroized
+     * before this returns. This is separate from {@link #seal(byte[], byte[])} so that tests can supply
+     * a fixed key pair; a real ephemeral key pair must never be reused.
+     *
+     * @param recipientPublicKey The recipient's {@value #PUBLIC_KEY_BYTES}-byte public key.
+     * @param message            The message.
+     * @param ephemeral          The ephemeral key pair, as returned by {@link #newKeyPair()}.
+     * @return The sealed box.
+     */
+    static byte[] seal(byte[] recipientPublicKey, byte[] message, byte[][] ephemeral) {
+
+        byte[] nonce = nonce(ephemeral[0], recipientPublicKey);
+        byte[] key = boxKey(ephemeral[1], recipientPublicKey);
+        byte[] polyKey = new byte[POLY1305_KEY_BYTES];
+
+        try {
+            // Encrypt, then MAC the ciphertext:
+            XSalsa20Engine cipher = cipher(key, nonce);
+            cipher.processBytes(polyKey, 0, polyKey.length, polyKey, 0);
+            byte[] ciphertext = new byte[message.length];
+            cipher.processBytes(message, 0, message.length, ciphertext, 0);
+            byte[] tag = poly1305(polyKey, ciphertext);
+
+            return ByteArray.concat(ephemeral[0], tag, ciphertext);
+        } finally {
+            ByteArray.zeroize(ephemeral[1], key, polyKey);
+        }
+    }
+
+    /**
+     * Opens a sealed box.
+     *
+     * @param recipientPublicKey  The recipient's {@value #PUBLIC_KEY_BYTES}-byte public key.
+     * @param recipientPrivateKey The recipient's {@value #PRIVATE_KEY_BYTES}-byte private key.
+     * @param box                 The sealed box, as returned by {@link #seal(byte[], byte[])}.
+     * @return The message, or null if the box is null.
+     * @throws MalformedDataException   If the box is too short.
+     * @throws AuthenticationException  If the box wasn't sealed to this key or has been altered.
+     * @throws IllegalArgumentException If the keys are not the correct length.
+     */
+    public static byte[] open(byte[] recipientPublicKey, byte[] recipientPrivateKey, byte[] box) {
+
+        if (box == null) {
+            return null;
+        }
+        checkLength("public key", recipientPublicKey, PUBLIC_KEY_BYTES);
+        checkLength("private key", recipientPrivateKey, PRIVATE_KEY_BYTES);
+        if (box.length < OVERHEAD_BYTES) {
+            throw new MalformedDataException("Are you sure this is a sealed box? Byte length (" + box.length
+                    + ") is shorter than the overhead (" + OVERHEAD_BYTES + ").");
+        }
+
+        // Separate the ephemeral public key, tag and ciphertext:
+        byte[][] split = ByteArray.splitAt(box, PUBLIC_KEY_BYTES);
+        byte[] ephemeralPublicKey = split[0];
+        split = ByteArray.splitAt(split[1], TAG_BYTES);
+        byte[] tag = split[0];
+        byte[] ciphertext = split[1];
+
+        byte[] nonce = nonce(ephemeralPublicKey, recipientPublicKey);
+        byte[] key = boxKey(recipientPrivateKey, ephemeralPublicKey);
+        byte[] polyKey = new byte[POLY1305_KEY_BYTES];
+
+        try {
+            // Check the MAC before decrypting:
+            XSalsa20Engine cipher = cipher(key, nonce);
+            cipher.processBytes(polyKey, 0, polyKey.length, polyKey, 0);
+            if (!MessageDigest.isEqual(tag, poly1305(polyKey, ciphertext))) {
+                throw new AuthenticationException("Unable to authenticate the sealed box. " +
+                        "Either it wasn't sealed to this key or it has been altered.");
+            }
+
+            byte[] message = new byte[ciphertext.length];
+            cipher.processBytes(ciphertext, 0, ciphertext.length, message, 0);
+            return message;
+        } finally {
+            ByteArray.zeroize(key, polyKey);
+        }
+    }
+
+    /**
+     * @param senderPublicKey    The ephemeral public key.
+     * @param recipientPublicKey The recipient's public key.
+     * @return The BLAKE2b hash of the two keys, used as the nonce.
+     */
+    private static byte[] nonce(byte[] senderPublicKey, byte[] recipientPublicKey) {
+        Blake2bDigest digest = new Blake2bDigest(NONCE_BYTES * 8);
+        digest.update(senderPublicKey, 0, senderPublicKey.length);
+        digest.update(recipientPublicKey, 0, recipientPublicKey.length);
+        byte[] nonce = new byte[NONCE_BYTES];
+        digest.doFinal(nonce, 0);
+        return nonce;
+    }
+
+    /**
+     * Computes the <code>crypto_box</code> key: HSalsa20 of the X25519 shared secret.
+     *
+     * @param privateKey The private key.
+     * @param publicKey  The other party's public key.
+     * @return The symmetric key.
+     */
+    private static byte[] boxKey(byte[] privateKey, byte[] publicKey) {
+        X25519Agreement agreement = new X25519Agreement();
+        agreement.init(new X25519PrivateKeyParameters(privateKey, 0));
+        byte[] shared = new byte[agreement.getAgreementSize()];
+        try {
+            agreement.calculateAgreement(new X25519PublicKeyParameters(publicKey, 0), shared, 0);
+            return hsalsa20(shared, new byte[16]);
+        } catch (IllegalStateException e) {
+            throw new IllegalArgumentException("Invalid public key for X25519.", e);
+        } finally {
+            ByteArray.zeroize(shared);
+        }
+    }
+
+    /**
+     * HSalsa20, as defined in "Extending the Salsa20 nonce".
+     *
+     * @param key   A 32-byte key.
+     * @param input A 16-byte input.
+     * @return A 32-byte derived key.
+     */
+    private static byte[] hsalsa20(byte[] key, byte[] input) {
+        int[] state = new int[16];
+        state[0] = SIGMA[0];
+        state[5] = SIGMA[1];
+        state[10] = SIGMA[2];
+        state[15] = SIGMA[3];
+        for (int i = 0; i < 4; i++) {
+            state[1 + i] = Pack.littleEndianToInt(key, i * 4);
+            state[11 + i] = Pack.littleEndianToInt(key, 16 + i * 4);
+            state[6 + i] = Pack.littleEndianToInt(input, i * 4);
+        }
+
+        // salsaCore adds the input back in at the end, which HSalsa20 doesn't, so subtract it:
+        int[] x = new int[16];
+        Salsa20Engine.salsaCore(20, state, x);
+        int[] words = {0, 5, 10, 15, 6, 7, 8, 9};
+        byte[] result = new byte[32];
+        for (int i = 0; i < words.length; i++) {
+            Pack.intToLittleEndian(x[words[i]] - state[words[i]], result, i * 4);
+        }
+        return result;
+    }
+
+    /**
+     * @param key   The symmetric key.
+     * @param nonce The nonce.
+     * @return An XSalsa20 cipher, initialised with the key and nonce.
+     */
+    private static XSalsa20Engine cipher(byte[] key, byte[] nonce) {
+        XSalsa20Engine cipher = new XSalsa20Engine();
+        cipher.init(true, new ParametersWithIV(new KeyParameter(key), nonce));
+        return cipher;
+    }
+
+    /**
+     * @param key  The one-time Poly1305 key.
+     * @param data The data to authenticate.
+     * @return The Poly1305 tag.
+     */
+    private static byte[] poly1305(byte[] key, byte[] data) {
+        Poly1305 mac = new Poly1305();
+        mac.init(new KeyParameter(key));
+        mac.update(data, 0, data.length);
+        byte[] tag = new byte[TAG_BYTES];
+        mac.doFinal(tag, 0);
+        return tag;
+    }
+
+    private static void checkLength(String name, byte[] value, int length) {
+        if (value == null || value.length != length) {
+            throw new IllegalArgumentException("The " + name + " must be " + length + " bytes, but got "
+                    + (value == null ? null : value.length));
+        }
+    }
+}
@@ -0,0 +1,105 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.apache.commons.lang.ArrayUtils;
+
+import javax.crypto.SecretKey;
+import java.nio.ByteBuffer;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * This class provides encrypted, tamper-proof cookie values with an embedded expiry time.
+ * <p>
+ * Unlike {@link SignedToken}, the value is encrypted, so it can't be read by the client. A sealed cookie
+ * is a single URL-safe base-64 segment (so it's safe to use as a cookie value without further encoding) made up of:
+ * <ul>
+ * <li>An 8-byte expiry time (milliseconds since the epoch).</li>
+ * <li>The value, encrypted by {@link AuthenticatedCrypto#encryptWithAssociatedData(byte[], SecretKey, byte[])}
+ * with the expiry time as associated data.</li>
+ * </ul>
+ * The expiry time isn't secret, but it's authenticated, so it can't be extended without the key.
+ *
+ * @author David Carboni
+ */
+public class SecureCookie {
+
+    /**
+     * The number of bytes used to encode the expiry time.
+     */
+    private static final int EXPIRY_BYTES = 8;
+
+    private AuthenticatedCrypto crypto = new AuthenticatedCrypto();
+
+    /**
+     * Encrypts the given value as a cookie, which will expire after the given time.
+     *
+     * @param value The content of the cookie.
+     * @param key   The key with which the cookie is to be encrypted.
+     * @param ttl   How long the cookie should be valid for. This must be greater than zero.
+     * @param unit  The unit of the ttl parameter.
+     * @return The sealed cookie value. If the value is null, null is returned.
+     * @throws IllegalArgumentException If the ttl is not greater than zero, or is too long to represent as an
+     *                                  expiry time.
+     */
+    public String seal(byte[] value, SecretKey key, long ttl, TimeUnit unit) {
+
+        if (value == null) {
+            return null;
+        }
+        if (ttl <= 0) {
+            throw new IllegalArgumentException("Please provide a ttl greater than zero, but got " + ttl);
+        }
+
+        // TimeUnit.toMillis saturates at Long.MAX_VALUE, so check the addition can't overflow:
+        long now = System.currentTimeMillis();
+        long millis = unit.toMillis(ttl);
+        if (millis > Long.MAX_VALUE - now) {
+            throw new IllegalArgumentException("The ttl is too long to represent as an expiry time: " + ttl + " " + unit);
+        }
+        long expiry = now + millis;
+        byte[] expiryBytes = ByteBuffer.allocate(EXPIRY_BYTES).putLong(expiry).array();
+        byte[] encrypted = crypto.encryptWithAssociatedData(value, key, expiryBytes);
+        return Base64.encodeBase64URLSafeString(ArrayUtils.addAll(expiryBytes, encrypted));
+    }
+
+    /**
+     * Decrypts the given cookie and returns its value.
+     *
+     * @param cookie A cookie value, as returned by {@link #seal(byte[], SecretKey, long, TimeUnit)}.
+     * @param key    The key that was used to seal the cookie.
+     * @return The value of the cookie, or null if the cookie is null, has been tampered with (or sealed with
+     * a different key), can't be decrypted or has expired.
+     * @throws IllegalArgumentException If the cookie is not in the expected format.
+     */
+    public byte[] open(String cookie, SecretKey key) {
+
+        if (cookie == null) {
+            return null;
+        }
+
+        byte[] sealed = Base64.decodeBase64(cookie);
+        if (sealed.length < EXPIRY_BYTES) {
+            throw new IllegalArgumentException("Are you sure this is a sealed cookie? Byte length (" + sealed.length
+                    + ") is shorter than an expiry time.");
+        }
+        byte[][] split = ByteArray.splitAt(sealed, EXPIRY_BYTES);
+
+        // Authenticate the expiry time together with the value:
+        byte[] value;
+        try {
+            value = crypto.decryptWithAssociatedData(split[1], key, split[0]);
+        } catch (DecryptionException e) {
+            // This includes tampering with the format of the encrypted value, not just its content:
+            return null;
+        }
+
+        // Check the expiry time:
+        long expiry = ByteBuffer.wrap(split[0]).getLong();
+        if (System.currentTimeMillis() > expiry) {
+            ByteArray.zeroize(value);
+            return null;
+        }
+
+        return value;
+    }
+}
@@ -0,0 +1,117 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.apache.commons.lang.ArrayUtils;
+import org.apache.commons.lang.StringUtils;
+
+import java.nio.ByteBuffer;
+import java.security.PrivateKey;
+import java.security.PublicKey;
+import java.util.concurrent.TimeUnit;
+
+/**
+ * This class provides a minimal signed token with an embedded expiry time,
+ * along the lines of a (much simplified) JWT.
+ * <p>
+ * A token is made up of two URL-safe base-64 segments, separated by a '.':
+ * <ul>
+ * <li>The body: an 8-byte expiry time (milliseconds since the epoch) followed by the payload.</li>
+ * <li>The {@value #ALGORITHM} signature of the body segment.</li>
+ * </ul>
+ * <p>
+ * The payload is signed, not encrypted, so anyone holding a token can read it.
+ * Use {@link Keys#newSigningKeyPair()} to generate keys.
+ *
+ * @author David Carboni
+ */
+public class SignedToken {
+
+    /**
+     * The digital signature algorithm to use: {@value #ALGORITHM}.
+     */
+    public static final String ALGORITHM = Keys.SIGNING_ALGORITHM;
+
+    /**
+     * The separator between the body and signature segments of a token.
+     */
+    public static final String SEPARATOR = ".";
+
+    /**
+     * The number of bytes used to encode the expiry time.
+     */
+    private static final int EXPIRY_BYTES = 8;
+
+    private DigitalSignature digitalSignature = new DigitalSignature(ALGORITHM);
+
+    /**
+     * Issues a signed token containing the given payload, which will expire after the given time.
+     *
+     * @param payload    The content of the token.
+     * @param privateKey The {@link PrivateKey} with which the token is to be signed. This can be obtained
+     *                   via {@link Keys#newSigningKeyPair()}.
+     * @param ttl        How long the token should be valid for.
+     * @param unit       The unit of the ttl parameter.
+     * @return The signed token. If the payload is null, null is returned.
+     */
+    public String issue(String payload, PrivateKey privateKey, long ttl, TimeUnit unit) {
+
+        if (payload == null) {
+            return null;
+        }
+
+        // Prepend the expiry time to the payload:
+        long expiry = System.currentTimeMillis() + unit.toMillis(ttl);
+        byte[] expiryBytes = ByteBuffer.allocate(EXPIRY_BYTES).putLong(expiry).array();
+        byte[] body = ArrayUtils.addAll(expiryBytes, ByteArray.fromString(payload));
+        String encodedBody = Base64.encodeBase64URLSafeString(body);
+
+        // Sign the encoded body:
+        String signature = digitalSignature.sign(encodedBody, privateKey);
+        String encodedSignature = Base64.encodeBase64URLSafeString(ByteArray.fromBase64(signature));
+
+        return encodedBody + SEPARATOR + encodedSignature;
+    }
+
+    /**
+     * Verifies the given token and returns its payload.
+     *
+     * @param token     A token, as returned by {@link #issue(String, PrivateKey, long, TimeUnit)}.
+     * @param publicKey The {@link PublicKey} corresponding to the {@link PrivateKey} that was used to
+     *                  issue the token.
+     * @return The payload of the token, or null if the token is null, the signature is not valid or the token has
+     * expired.
+     * @throws IllegalArgumentException If the token is not in the expected format.
+     */
+    public String verify(String token, PublicKey publicKey) {
+
+        if (token == null) {
+            return null;
+        }
+
+        // Separate the body and signature:
+        String encodedBody = StringUtils.substringBefore(token, SEPARATOR);
+        String encodedSignature = StringUtils.substringAfter(token, SEPARATOR);
+        if (StringUtils.isEmpty(encodedBody) || StringUtils.isEmpty(encodedSignature)) {
+            throw new IllegalArgumentException("Are you sure this is a signed token? Expected two segments separated by '" + SEPARATOR + "'");
+        }
+
+        // Check the signature:
+        String signature = ByteArray.toBase64(Base64.decodeBase64(encodedSignature));
+        if (!digitalSignature.verify(encodedBody, publicKey, signature)) {
+            return null;
+        }
+
+        // Check the expiry time:
+        byte[] body = Base64.decodeBase64(encodedBody);
+        if (body.length < EXPIRY_BYTES) {
+            throw new IllegalArgumentException("Are you sure this is a signed token? Byte length (" + body.length
+                    + ") is shorter than an expiry time.");
+        }
+        long expiry = ByteBuffer.wrap(body, 0, EXPIRY_BYTES).getLong();
+        if (System.currentTimeMillis() > expiry) {
+            return null;
+        }
+
+        return ByteArray.toString(ArrayUtils.subarray(body, EXPIRY_BYTES, body.length));
+    }
+}
@@ -0,0 +1,374 @@
+package com.github.davidcarboni.cryptolite;
+
+import javax.crypto.BadPaddingException;
+import javax.crypto.Cipher;
+import javax.crypto.IllegalBlockSizeException;
+import javax.crypto.NoSuchPaddingException;
+import javax.crypto.SecretKey;
+import javax.crypto.spec.GCMParameterSpec;
+import java.io.DataInputStream;
+import java.io.EOFException;
+import java.io.IOException;
+import java.io.InputStream;
+import java.io.OutputStream;
+import java.io.PushbackInputStream;
+import java.nio.ByteBuffer;
+import java.security.InvalidAlgorithmParameterException;
+import java.security.InvalidKeyException;
+import java.security.NoSuchAlgorithmException;
+import java.util.Arrays;
+import java.util.concurrent.CancellationException;
+import java.util.concurrent.atomic.AtomicBoolean;
+
+/**
+ * This class provides authenticated encryption of streams, using {@value AuthenticatedCrypto#CIPHER_NAME}
+ * in chunks, so streams of any size can be encrypted and decrypted without holding them in memory.
+ * <p>
+ * Unlike the streams provided by {@link Crypto}, every chunk is authenticated, and the stream can't be
+ * reordered or truncated without detection. The format is language-neutral, so it can be read by other
+ * implementations. All multi-byte integers are big-endian. The stream starts with a header:
+ * <ul>
+ * <li>{@value #MAGIC_BYTES} bytes of magic: the ASCII characters "{@value #MAGIC}".</li>
+ * <li>One byte giving the format version ({@value #STREAM_VERSION}).</li>
+ * <li>One byte identifying the cipher ({@value AuthenticatedCrypto#CIPHER_ID} for {@value AuthenticatedCrypto#CIPHER_NAME}).</li>
+ * <li>The chunk size, as an unsigned 32-bit integer: the number of plaintext bytes in each chunk.</li>
+ * <li>The {@value AuthenticatedCrypto#NONCE_SIZE}-byte random base nonce.</li>
+ * </ul>
+ * This is followed by the chunks. Each chunk is the ciphertext of exactly chunk-size plaintext bytes,
+ * followed by a {@value AuthenticatedCrypto#TAG_BITS}-bit authentication tag, except the last chunk, which may
+ * be shorter (and is only a tag if the plaintext is empty or a whole number of chunks). The last chunk is the one
+ * followed by the end of the stream.
+ * <p>
+ * The nonce for chunk <code>i</code> (counting from zero) is the base nonce with its last four bytes XORed with
+ * <code>i</code> as an unsigned 32-bit integer. Each chunk is authenticated with associated data consisting of the
+ * whole header, followed by one byte: 1 for the last chunk and 0 for any other.
+ *
+ * @author David Carboni
+ */
+public class StreamingCrypto {
+
+    /**
+     * The magic at the start of an encrypted stream.
+     */
+    public static final String MAGIC = "CLST";
+
+    /**
+     * The number of bytes of magic.
+     */
+    public static final int MAGIC_BYTES = 4;
+
+    /**
+     * The format version written to the header.
+     */
+    public static final int STREAM_VERSION = 1;
+
+    /**
+     * The default number of plaintext bytes in each chunk.
+     */
+    public static final int DEFAULT_CHUNK_SIZE = 64 * 1024;
+
+    /**
+     * The largest chunk size that will be written, and the default limit on the chunk size that will be read.
+     * This limits the memory used for each chunk.
+     */
+    public static final int MAX_CHUNK_SIZE = 16 * 1024 * 1024;
+
+    /**
+     * The number of bytes in the header: magic, version, cipher, chunk size and base nonce.
+     */
+    public static final int HEADER_SIZE = MAGIC_BYTES + 1 + 1 + 4 + AuthenticatedCrypto.NONCE_SIZE;
+
+    private static final int TAG_BYTES = AuthenticatedCrypto.TAG_BITS / 8;
+
+    private static final byte[] MAGIC_VALUE = ByteArray.fromString(MAGIC);
+
+    private int chunkSize;
+    private int maxChunkSize = MAX_CHUNK_SIZE;
+
+    /**
+     * Initialises the instance with the default chunk size of {@value #DEFAULT_CHUNK_SIZE} bytes.
+     */
+    public StreamingCrypto() {
+        this(DEFAULT_CHUNK_SIZE);
+    }
+
+    /**
+     * Initialises the instance with a specific chunk size for encryption. Decryption uses the
+     * chunk size given in the header.
+     *
+     * @param chunkSize The number of plaintext bytes in each chunk. This must be between 1 and {@value #MAX_CHUNK_SIZE}.
+     */
+    public StreamingCrypto(int chunkSize) {
+        if (chunkSize < 1 || chunkSize > MAX_CHUNK_SIZE) {
+            throw new IllegalArgumentException("Chunk size must be between 1 and " + MAX_CHUNK_SIZE + " bytes, but got " + chunkSize);
+        }
+        this.chunkSize = chunkSize;
+    }
+
+    /**
+     * @return The chunk size, in bytes, used by this instance for encryption.
+     */
+    public int getChunkSize() {
+        return chunkSize;
+    }
+
+    /**
+     * Sets the largest chunk size that will be accepted when decrypting. The default is {@value #MAX_CHUNK_SIZE} bytes.
+     * <p>
+     * The chunk size is read from the stream header, so if you decrypt streams from untrusted sources,
+     * you can lower this limit to bound the memory allocated for each chunk. A header that declares a larger
+     * chunk size is rejected before any buffer is allocated.
+     *
+     * @param maxChunkSize The largest chunk size, in bytes. This must be between 1 and {@value #MAX_CHUNK_SIZE}.
+     */
+    public void setMaxChunkSize(int maxChunkSize) {
+        if (maxChunkSize < 1 || maxChunkSize > MAX_CHUNK_SIZE) {
+            throw new IllegalArgumentException("Maximum chunk size must be between 1 and " + MAX_CHUNK_SIZE + " bytes, but got " + maxChunkSize);
+        }
+        this.maxChunkSize = maxChunkSize;
+    }
+
+    /**
+     * @return The largest chunk size, in bytes, that this instance will accept when decrypting.
+     */
+    public int getMaxChunkSize() {
+        return maxChunkSize;
+    }
+
+    /**
+     * Encrypts the given stream.
+     *
+     * @param source      The data to encrypt. This is read to the end, but not closed.
+     * @param destination Where the encrypted stream will be written. This is flushed, but not closed.
+     * @param key         The key to encrypt with.
+     * @throws IOException If an error occurs reading or writing.
+     */
+    public void encrypt(InputStream source, OutputStream destination, SecretKey key) throws IOException {
+        encrypt(source, destination, key, new AtomicBoolean());
+    }
+
+    /**
+     * Encrypts the given stream and can be cancelled part-way through.
+     * <p>
+     * This is intended for long-running jobs, such as encrypting large files in the background.
+     * The cancellation flag is checked before each chunk is read, so cancellation takes effect within one chunk.
+     * <p>
+     * If encryption is cancelled, a {@link CancellationException} is thrown and the destination is left with
+     * a truncated stream. The last chunk written isn't marked as the final chunk, so
+     * {@link #decrypt(InputStream, OutputStream, SecretKey)} rejects it with an {@link AuthenticationException}
+     * rather than returning part of the data.
+     *
+     * @param source      The data to encrypt. This is read up to the point of cancellation, but not closed.
+     * @param destination Where the encrypted stream will be written. This is flushed, but not closed.
+     * @param key         The key to encrypt with.
+     * @param cancelled   Set this to true, from any thread, to cancel encryption.
+     * @throws IOException           If an error occurs reading or writing.
+     * @throws CancellationException If encryption is cancelled.
+     */
+    public void encrypt(InputStream source, OutputStream destination, SecretKey key, AtomicBoolean cancelled) throws IOException {
+
+        byte[] baseNonce = Generate.byteArray(AuthenticatedCrypto.NONCE_SIZE);
+        byte[] header = ByteBuffer.allocate(HEADER_SIZE)
+                .put(MAGIC_VALUE)
+                .put((byte) STREAM_VERSION)
+                .put((byte) AuthenticatedCrypto.CIPHER_ID)
+                .putInt(chunkSize)
+                .put(baseNonce)
+                .array();
+        destination.write(header);
+
+        PushbackInputStream in = new PushbackInputStream(source);
+        byte[] buffer = new byte[chunkSize];
+        try {
+            long index = 0;
+            boolean last;
+            do {
+                if (cancelled.get()) {
+                    destination.flush();
+                    throw new CancellationException("Encryption cancelled. " +
+                            "The destination contains a truncated stream and should be discarded.");
+                }
+                int read = readChunk(in, buffer);
+                last = isEnd(in);
+                Cipher cipher = getCipher(Cipher.ENCRYPT_MODE, key, chunkNonce(baseNonce, index++));
+                destination.write(doFinal(cipher, header, last, buffer, read));
+            } while (!last);
+        } finally {
+            ByteArray.zeroize(buffer);
+        }
+
+        destination.flush();
+    }
+
+    /**
+     * Decrypts a stream encrypted by {@link #encrypt(InputStream, OutputStream, SecretKey)}.
+     * <p>
+     * Each chunk is written to the destination as soon as it has been authenticated. If an exception is thrown,
+     * the destination may already have some of the data, so you should discard it.
+     *
+     * @param source      The encrypted stream. This is read to the end, but not closed.
+     * @param destination Where the decrypted data will be written. This is flushed, but not closed.
+     * @param key         The key to decrypt with.
+     * @throws IOException                 If an error occurs reading or writing.
+     * @throws MalformedDataException      If the stream is not in the expected format, or declares a chunk size
+     *                                     larger than {@link #getMaxChunkSize()}.
+     * @throws UnsupportedVersionException If the stream specifies a format version this class can't handle.
+     * @throws UnsupportedCipherException  If the stream specifies a cipher other than {@value AuthenticatedCrypto#CIPHER_NAME}.
+     * @throws AuthenticationException     If the key is wrong or the stream has been altered, reordered or truncated.
+     */
+    public void decrypt(InputStream source, OutputStream destination, SecretKey key) throws IOException {
+
+        PushbackInputStream in = new PushbackInputStream(source);
+        byte[] header = new byte[HEADER_SIZE];
+        try {
+            new DataInputStream(in).readFully(header);
+        } catch (EOFException e) {
+            throw new MalformedDataException("Are you sure this is an encrypted stream? It's shorter than a header.", e);
+        }
+
+        // Validate the header:
+        ByteBuffer fields = ByteBuffer.wrap(header);
+        byte[] magic = new byte[MAGIC_BYTES];
+        fields.get(magic);
+        if (!Arrays.equals(magic, MAGIC_VALUE)) {
+            throw new MalformedDataException("Are you sure this is an encrypted stream? It doesn't start with " + MAGIC + ".");
+        }
+        int version = fields.get() & 0xff;
+        if (version != STREAM_VERSION) {
+            throw new UnsupportedVersionException("Unsupported stream version: " + version + ". Expected " + STREAM_VERSION + ".");
+        }
+        int cipherId = fields.get() & 0xff;
+        if (cipherId != AuthenticatedCrypto.CIPHER_ID) {
+            throw new UnsupportedCipherException("Unsupported cipher identifier: " + cipherId
+                    + ". Expected " + AuthenticatedCrypto.CIPHER_ID + " (" + AuthenticatedCrypto.CIPHER_NAME + ").");
+        }
+        long streamChunkSize = fields.getInt() & 0xffffffffL;
+        if (streamChunkSize < 1 || streamChunkSize > maxChunkSize) {
+            throw new MalformedDataException("Stream chunk size (" + streamChunkSize + ") is outside the range 1-"
+                    + maxChunkSize + ".");
+        }
+        byte[] baseNonce = new byte[AuthenticatedCrypto.NONCE_SIZE];
+        fields.get(baseNonce);
+
+        // Decrypt the chunks:
+        byte[] buffer = new byte[(int) streamChunkSize + TAG_BYTES];
+        long index = 0;
+        boolean last;
+        do {
+            int read = readChunk(in, buffer);
+            if (read < TAG_BYTES) {
+                throw new AuthenticationException("The encrypted stream has been truncated.");
+            }
+            last = isEnd(in);
+            Cipher cipher = getCipher(Cipher.DECRYPT_MODE, key, chunkNonce(baseNonce, index++));
+            byte[] plaintext = doFinal(cipher, header, last, buffer, read);
+            destination.write(plaintext);
+            ByteArray.zeroize(plaintext);
+        } while (!last);
+
+        destination.flush();
+    }
+
+    /**
+     * Encrypts or decrypts a chunk, authenticating the header and whether it's the last chunk.
+     *
+     * @param cipher The initialised cipher.
+     * @param header The stream header.
+     * @param last   Whether this is the last chunk.
+     * @param buffer The chunk.
+     * @param length The number of bytes in the chunk.
+     * @return The result.
+     */
+    private static byte[] doFinal(Cipher cipher, byte[] header, boolean last, byte[] buffer, int length) {
+        cipher.updateAAD(header);
+        cipher.updateAAD(new byte[]{(byte) (last ? 1 : 0)});
+        try {
+            return cipher.doFinal(buffer, 0, length);
+        } catch (IllegalBlockSizeException e) {
+            throw new IllegalStateException("Block-size exception when processing a chunk.", e);
+        } catch (BadPaddingException e) {
+            // In GCM mode this means the authentication tag didn't match:
+            throw new AuthenticationException("Unable to authenticate the encrypted stream. " +
+                    "Either the key is wrong or the stream has been altered, reordered or truncated.", e);
+        }
+    }
+
+    /**
+     * @param baseNonce The base nonce from the header.
+     * @param index     The chunk index.
+     * @return The base nonce, with its last four bytes XORed with the chunk index.
+     */
+    private static byte[] chunkNonce(byte[] baseNonce, long index) {
+        if (index > 0xffffffffL) {
+            throw new IllegalStateException("Too many chunks for a single stream. Please use a larger chunk size.");
+        }
+        byte[] nonce = baseNonce.clone();
+        for (int i = 0; i < 4; i++) {
+            nonce[nonce.length - 1 - i] ^= (byte) (index >>> (8 * i));
+        }
+        return nonce;
+    }
+
+    /**
+     * @param in The stream.
+     * @return If the stream has no more data, true.
+     * @throws IOException If an error occurs reading the stream.
+     */
+    private static boolean isEnd(PushbackInputStream in) throws IOException {
+        int next = in.read();
+        if (next == -1) {
+            return true;
+        }
+        in.unread(next);
+        return false;
+    }
+
+    /**
+     * Reads as many bytes as are available, up to the size of the buffer.
+     *
+     * @param in     The stream to read from.
+     * @param buffer The buffer to fill.
+     * @return The number of bytes read, which is only less than the buffer size at the end of the stream.
+     * @throws IOException If an error occurs reading the stream.
+     */
+    private static int readChunk(InputStream in, byte[] buffer) throws IOException {
+        int total = 0;
+        int read;
+        while (total < buffer.length && (read = in.read(buffer, total, buffer.length - total)) != -1) {
+            total += read;
+        }
+        return total;
+    }
+
+    /**
+     * @param mode  The cipher mode.
+     * @param key   The key.
+     * @param nonce The nonce for the chunk.
+     * @return A {@link Cipher}, initialised for the chunk.
+     */
+    private static Cipher getCipher(int mode, SecretKey key, byte[] nonce) {
+
+        Cipher cipher;
+        try {
+            cipher = Cipher.getInstance(AuthenticatedCrypto.CIPHER_NAME);
+        } catch (NoSuchAlgorithmException e) {
+            if (SecurityProvider.addProvider()) {
+                return getCipher(mode, key, nonce);
+            } else {
+                throw new IllegalStateException("Algorithm unavailable: " + AuthenticatedCrypto.CIPHER_NAME, e);
+            }
+        } catch (NoSuchPaddingException e) {
+            throw new IllegalStateException("Padding method unavailable: " + AuthenticatedCrypto.CIPHER_NAME, e);
+        }
+
+        try {
+            cipher.init(mode, key, new GCMParameterSpec(AuthenticatedCrypto.TAG_BITS, nonce));
+        } catch (InvalidKeyException e) {
+            throw new IllegalArgumentException("Invalid key for " + AuthenticatedCrypto.CIPHER_NAME, e);
+        } catch (InvalidAlgorithmParameterException e) {
+            throw new IllegalArgumentException("Invalid parameter passed to initialise cipher: " +
+                    "GCMParameterSpec containing a " + nonce.length + "-byte nonce.", e);
+        }
+        return cipher;
+    }
+}
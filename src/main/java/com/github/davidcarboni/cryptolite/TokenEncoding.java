@@ -0,0 +1,25 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * The encodings available for tokens generated by {@link Generate#token(TokenEncoding)}.
+ *
+ * @author David Carboni
+ */
+public enum TokenEncoding {
+
+    /**
+     * Lowercase hexadecimal, as returned by {@link Generate#token()}.
+     */
+    HEX,
+
+    /**
+     * URL-safe base64, without padding. This is the most compact and is safe to use in URLs and filenames.
+     */
+    BASE64URL,
+
+    /**
+     * Uppercase base32 (RFC 4648), without padding. This is case-insensitive and avoids punctuation,
+     * which is useful if a token may be read aloud or typed.
+     */
+    BASE32
+}
@@ -0,0 +1,24 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown when encrypted data specify a cipher that isn't supported by this version of Cryptolite.
+ *
+ * @author David Carboni
+ */
+public class UnsupportedCipherException extends DecryptionException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public UnsupportedCipherException(String message) {
+        super(message);
+    }
+
+    /**
+     * @param message A description of the error.
+     * @param cause   The underlying cause of the error.
+     */
+    public UnsupportedCipherException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
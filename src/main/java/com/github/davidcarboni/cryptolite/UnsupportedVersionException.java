@@ -0,0 +1,26 @@
+package com.github.davidcarboni.cryptolite;
+
+/**
+ * Thrown when encrypted data specify a format version that isn't supported by this version of Cryptolite.
+ * <p>
+ * This usually means the data were produced by a newer version of the library.
+ *
+ * @author David Carboni
+ */
+public class UnsupportedVersionException extends DecryptionException {
+
+    /**
+     * @param message A description of the error.
+     */
+    public UnsupportedVersionException(String message) {
+        super(message);
+    }
+
+    /**
+     * @param message A description of the error.
+     * @param cause   The underlying cause of the error.
+     */
+    public UnsupportedVersionException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
@@ -0,0 +1,87 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Before;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+import java.io.ByteArrayInputStream;
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.io.InputStream;
+import java.util.LinkedHashMap;
+import java.util.Map;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+
+/**
+ * Test for {@link Archive}.
+ *
+ * @author David Carboni
+ */
+public class ArchiveTest {
+
+    SecretKey key;
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    @Before
+    public void setup() {
+        key = Keys.newSecretKey();
+    }
+
+    /**
+     * Verifies that files can be sealed into an archive and opened back byte-for-byte.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldSealAndOpen() throws IOException {
+
+        // Given
+        byte[] small = ByteArray.fromString("A small file.");
+        byte[] large = Generate.byteArray(Archive.CHUNK_SIZE * 2 + 1);
+        Map<String, InputStream> files = new LinkedHashMap<>();
+        files.put("small.txt", new ByteArrayInputStream(small));
+        files.put("dir/large.bin", new ByteArrayInputStream(large));
+
+        // When
+        ByteArrayOutputStream archive = new ByteArrayOutputStream();
+        Archive.seal(key, files, archive);
+        Map<String, byte[]> opened = Archive.open(key, new ByteArrayInputStream(archive.toByteArray()));
+
+        // Then
+        assertEquals(2, opened.size());
+        assertArrayEquals(small, opened.get("small.txt"));
+        assertArrayEquals(large, opened.get("dir/large.bin"));
+    }
+
+    /**
+     * Verifies that an archive with a block removed is rejected.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test(expected = DecryptionException.class)
+    public void shouldDetectTruncatedArchive() throws IOException {
+
+        // Given
+        Map<String, InputStream> files = new LinkedHashMap<>();
+        files.put("file.txt", new ByteArrayInputStream(ByteArray.fromString("Don't truncate me.")));
+        ByteArrayOutputStream archive = new ByteArrayOutputStream();
+        Archive.seal(key, files, archive);
+        byte[] bytes = archive.toByteArray();
+        byte[] truncated = ByteArray.splitAt(bytes, bytes.length - 1)[0];
+
+        // When
+        Archive.open(key, new ByteArrayInputStream(truncated));
+
+        // Then
+        // We should get a DecryptionException because
+        // the manifest is incomplete.
+    }
+}
@@ -0,0 +1,822 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Before;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import javax.crypto.Cipher;
+import javax.crypto.SecretKey;
+import javax.crypto.spec.GCMParameterSpec;
+import javax.crypto.spec.SecretKeySpec;
+import java.nio.ByteBuffer;
+import java.util.Arrays;
+import java.util.Date;
+import java.util.HashMap;
+import java.util.Map;
+
+import static org.junit.Assert.*;
+
+/**
+ * Test for {@link AuthenticatedCrypto}.
+ *
+ * @author David Carboni
+ */
+public class AuthenticatedCryptoTest {
+
+    static final AuthenticatedCrypto crypto = new AuthenticatedCrypto();
+    SecretKey key;
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    @Before
+    public void setup() {
+        key = Keys.newSecretKey();
+    }
+
+    /**
+     * Verifies that a String can be encrypted and decrypted.
+     */
+    @Test
+    public void shouldEncryptAndDecrypt() {
+
+        // Given
+        String plaintext = "The quick brown fox jumped over the lazy dog.";
+
+        // When
+        String ciphertext = crypto.encrypt(plaintext, key);
+
+        // Then
+        assertNotEquals(plaintext, ciphertext);
+        assertEquals(plaintext, crypto.decrypt(ciphertext, key));
+    }
+
+    /**
+     * Verifies that a 16-byte nonce can be used for encryption and decryption.
+     */
+    @Test
+    public void shouldEncryptAndDecryptWithSixteenByteNonce() {
+
+        // Given
+        AuthenticatedCrypto crypto16 = new AuthenticatedCrypto(16);
+        byte[] plaintext = Generate.byteArray(100);
+
+        // When
+        byte[] ciphertext = crypto16.encrypt(plaintext, key);
+
+        // Then
+        assertEquals(16, ciphertext[2]);
+        assertArrayEquals(plaintext, crypto16.decrypt(ciphertext, key));
+    }
+
+    /**
+     * Verifies that a nonce size mismatch is detected.
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldDetectNonceSizeMismatch() {
+
+        // Given
+        byte[] ciphertext = new AuthenticatedCrypto(16).encrypt(Generate.byteArray(100), key);
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get a MalformedDataException because
+        // the header specifies a different nonce size.
+    }
+
+    /**
+     * Verifies that altered data are detected.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectAlteredData() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+        ciphertext[ciphertext.length - 1] ^= 1;
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get an AuthenticationException because
+        // the authentication tag won't match.
+    }
+
+    /**
+     * Verifies that messages of different lengths are padded to the same bucket and decrypt back exactly.
+     */
+    @Test
+    public void shouldPadToSameLength() {
+
+        // Given
+        int blockSize = 64;
+        byte[] shortMessage = Generate.byteArray(3);
+        byte[] longMessage = Generate.byteArray(30);
+
+        // When
+        byte[] shortCiphertext = crypto.encryptPadded(shortMessage, key, blockSize);
+        byte[] longCiphertext = crypto.encryptPadded(longMessage, key, blockSize);
+
+        // Then
+        assertEquals(shortCiphertext.length, longCiphertext.length);
+        assertArrayEquals(shortMessage, crypto.decryptPadded(shortCiphertext, key));
+        assertArrayEquals(longMessage, crypto.decryptPadded(longCiphertext, key));
+    }
+
+    /**
+     * Verifies that truncated data are reported as malformed.
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldDetectTruncatedData() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+        byte[] truncated = ByteArray.splitAt(ciphertext, 10)[0];
+
+        // When
+        crypto.decrypt(truncated, key);
+
+        // Then
+        // We should get a MalformedDataException because
+        // the data are too short to contain a nonce and tag.
+    }
+
+    /**
+     * Verifies that an unknown cipher identifier is reported as unsupported.
+     */
+    @Test(expected = UnsupportedCipherException.class)
+    public void shouldDetectUnknownCipher() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+        ciphertext[1] = (byte) 0xff;
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get an UnsupportedCipherException because
+        // the header specifies an unknown cipher.
+    }
+
+    /**
+     * Verifies that a {@link NonceTracker} detects a nonce being used twice with the same key.
+     */
+    @Test(expected = NonceReuseException.class)
+    public void shouldDetectNonceReuse() {
+
+        // Given
+        AuthenticatedCrypto tracked = new AuthenticatedCrypto();
+        tracked.setNonceTracker(new NonceTracker(10));
+        byte[] nonce = Generate.byteArray(AuthenticatedCrypto.NONCE_SIZE);
+        byte[] ciphertext = tracked.encrypt(Generate.byteArray(100), key, nonce);
+        assertNotNull(tracked.decrypt(ciphertext, key));
+
+        // When
+        tracked.encrypt(Generate.byteArray(100), key, nonce);
+
+        // Then
+        // We should get a NonceReuseException because
+        // the nonce has already been used with this key.
+    }
+
+    /**
+     * Verifies that the header is authenticated: the same nonce and ciphertext under a header
+     * that wasn't the one authenticated on encryption fail to decrypt.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldAuthenticateHeader() throws Exception {
+
+        // Given
+        byte[] data = Generate.byteArray(100);
+        byte[] nonce = Generate.byteArray(AuthenticatedCrypto.NONCE_SIZE);
+        byte[] header = ByteArray.splitAt(crypto.encrypt(data, key, nonce), 3)[0];
+        Cipher cipher = Cipher.getInstance(AuthenticatedCrypto.CIPHER_NAME);
+        cipher.init(Cipher.ENCRYPT_MODE, key, new GCMParameterSpec(AuthenticatedCrypto.TAG_BITS, nonce));
+        cipher.updateAAD(new byte[]{header[0], header[1], (byte) (header[2] ^ 0x80)});
+        byte[] ciphertext = ByteArray.concat(header, nonce, cipher.doFinal(data));
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get an AuthenticationException because
+        // the ciphertext was authenticated with a different header.
+    }
+
+    /**
+     * Verifies that the calculated ciphertext length matches the actual output for several input sizes.
+     */
+    @Test
+    public void shouldCalculateCiphertextLength() {
+
+        // Given
+        int[] sizes = {0, 1, 15, 16, 17, 100, 4096};
+        AuthenticatedCrypto longNonce = new AuthenticatedCrypto(16);
+
+        for (int size : sizes) {
+
+            // When
+            byte[] ciphertext = crypto.encrypt(Generate.byteArray(size), key);
+            byte[] longNonceCiphertext = longNonce.encrypt(Generate.byteArray(size), key);
+
+            // Then
+            assertEquals(ciphertext.length, crypto.ciphertextLength(size));
+            assertEquals(longNonceCiphertext.length, longNonce.ciphertextLength(size));
+        }
+    }
+
+    /**
+     * Verifies that data encrypted with a password can be decrypted with the same password.
+     */
+    @Test
+    public void shouldEncryptAndDecryptWithPassword() {
+
+        // Given
+        byte[] data = ByteArray.fromString("Encrypt this with a passphrase.");
+        KdfParameters params = new KdfParameters(1024, 1, 1);
+
+        // When
+        byte[] ciphertext = crypto.encryptWithPassword(data, "correct horse", params);
+        byte[] plaintext = crypto.decryptWithPassword(ciphertext, "correct horse");
+
+        // Then
+        assertArrayEquals(data, plaintext);
+    }
+
+    /**
+     * Verifies that data encrypted with a password can't be decrypted with a different password.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldNotDecryptWithWrongPassword() {
+
+        // Given
+        byte[] data = ByteArray.fromString("Encrypt this with a passphrase.");
+        byte[] ciphertext = crypto.encryptWithPassword(data, "correct horse", new KdfParameters(1024, 1, 1));
+
+        // When
+        crypto.decryptWithPassword(ciphertext, "battery staple");
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Verifies that data in the original format, which has no version byte, can still be decrypted.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test
+    public void shouldDecryptVersion1Format() throws Exception {
+
+        // Given
+        byte[] data = Generate.byteArray(100);
+        byte[] nonce = Generate.byteArray(AuthenticatedCrypto.NONCE_SIZE);
+        byte[] header = {(byte) AuthenticatedCrypto.CIPHER_ID, (byte) AuthenticatedCrypto.NONCE_SIZE};
+        Cipher cipher = Cipher.getInstance(AuthenticatedCrypto.CIPHER_NAME);
+        cipher.init(Cipher.ENCRYPT_MODE, key, new GCMParameterSpec(AuthenticatedCrypto.TAG_BITS, nonce));
+        cipher.updateAAD(header);
+        byte[] ciphertext = ByteArray.concat(header, nonce, cipher.doFinal(data));
+
+        // When
+        byte[] plaintext = crypto.decrypt(ciphertext, key);
+
+        // Then
+        assertArrayEquals(data, plaintext);
+    }
+
+    /**
+     * Verifies that an unknown format version is reported as unsupported.
+     */
+    @Test(expected = UnsupportedVersionException.class)
+    public void shouldDetectUnknownVersion() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+        ciphertext[0] = (byte) 0x7f;
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get an UnsupportedVersionException because
+        // the header specifies a version this library doesn't know about.
+    }
+
+    /**
+     * Verifies that data can be decrypted when the matching key is one of several candidates.
+     */
+    @Test
+    public void shouldDecryptWithAnyCandidateKey() {
+
+        // Given
+        SecretKey otherKey = Keys.newSecretKey();
+        byte[] data = Generate.byteArray(100);
+        byte[] ciphertext = crypto.encrypt(data, key);
+
+        // When
+        byte[] plaintext = crypto.decryptAny(ciphertext, otherKey, key);
+
+        // Then
+        assertArrayEquals(data, plaintext);
+    }
+
+    /**
+     * Verifies that an {@link AuthenticationException} is thrown if none of the candidate keys match.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldNotDecryptWithWrongCandidateKeys() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+
+        // When
+        crypto.decryptAny(ciphertext, Keys.newSecretKey(), Keys.newSecretKey());
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Verifies that a record can be encrypted and decrypted with its header.
+     */
+    @Test
+    public void shouldEncryptAndDecryptRecord() {
+
+        // Given
+        byte[] data = ByteArray.fromString("Sensitive column value");
+        Map<String, String> header = new HashMap<>();
+        header.put("tenant", "acme");
+        header.put("table", "customers");
+        header.put("id", "42");
+
+        // When
+        byte[] record = crypto.encryptRecord(data, key, header);
+        DecryptedRecord decrypted = crypto.decryptRecord(record, key);
+
+        // Then
+        assertEquals(header, decrypted.getHeader());
+        assertArrayEquals(data, decrypted.getData());
+    }
+
+    /**
+     * Verifies that altering a header value after encryption is detected.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectAlteredRecordHeader() {
+
+        // Given
+        Map<String, String> header = new HashMap<>();
+        header.put("tenant", "acme");
+        header.put("id", "42");
+        byte[] record = crypto.encryptRecord(ByteArray.fromString("Sensitive column value"), key, header);
+        byte[][] fields = ByteArray.unframe(record);
+        byte[] alteredHeader = new Frame()
+                .addField(ByteArray.fromString("id")).addField(ByteArray.fromString("43"))
+                .addField(ByteArray.fromString("tenant")).addField(ByteArray.fromString("acme"))
+                .toByteArray();
+        byte[] altered = new Frame().addField(alteredHeader).addField(fields[1]).toByteArray();
+
+        // When
+        crypto.decryptRecord(altered, key);
+
+        // Then
+        // We should get an AuthenticationException because
+        // the header doesn't match the one that was authenticated.
+    }
+
+    /**
+     * Verifies that a creation timestamp can be read back and the data still decrypt.
+     */
+    @Test
+    public void shouldRecordTimestamp() {
+
+        // Given
+        byte[] data = Generate.byteArray(100);
+        long before = System.currentTimeMillis();
+
+        // When
+        byte[] ciphertext = crypto.encryptWithTimestamp(data, key);
+        long after = System.currentTimeMillis();
+
+        // Then
+        Date encryptedAt = AuthenticatedCrypto.encryptedAt(ciphertext);
+        assertTrue(encryptedAt.getTime() >= before);
+        assertTrue(encryptedAt.getTime() <= after);
+        assertArrayEquals(data, crypto.decrypt(ciphertext, key));
+        assertNull(AuthenticatedCrypto.encryptedAt(crypto.encrypt(data, key)));
+    }
+
+    /**
+     * Verifies that altering the creation timestamp causes decryption to fail.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectTamperedTimestamp() {
+
+        // Given
+        byte[] ciphertext = crypto.encryptWithTimestamp(Generate.byteArray(100), key);
+        ciphertext[AuthenticatedCrypto.TIMESTAMP_BYTES] ^= 1;
+
+        // When
+        crypto.decrypt(ciphertext, key);
+
+        // Then
+        // We should get an AuthenticationException because
+        // the timestamp doesn't match the one that was authenticated.
+    }
+
+    /**
+     * Verifies that compact encryption round-trips with the reduced overhead.
+     */
+    @Test
+    public void shouldEncryptCompact() {
+
+        // Given
+        byte[] data = ByteArray.fromString("42");
+
+        // When
+        byte[] ciphertext = crypto.encryptCompact(data, key);
+        byte[] plaintext = crypto.decryptCompact(ciphertext, key);
+
+        // Then
+        assertArrayEquals(data, plaintext);
+        assertEquals(1 + AuthenticatedCrypto.COMPACT_NONCE_SIZE + AuthenticatedCrypto.TAG_BITS / 8,
+                ciphertext.length - data.length);
+        assertTrue(ciphertext.length < crypto.ciphertextLength(data.length));
+        assertEquals(AuthenticatedCrypto.COMPACT_HEADER, ciphertext[0] & 0xff);
+    }
+
+    /**
+     * Verifies that data in the default format are rejected by compact decryption.
+     */
+    @Test(expected = UnsupportedVersionException.class)
+    public void shouldDetectNonCompactData() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+
+        // When
+        crypto.decryptCompact(ciphertext, key);
+
+        // Then
+        // We should get an UnsupportedVersionException because
+        // the data aren't in the compact format.
+    }
+
+    /**
+     * Verifies that a tagged value decrypts with its own tag.
+     */
+    @Test
+    public void shouldDecryptWithSameTag() {
+
+        // Given
+        String ssn = "078-05-1120";
+
+        // When
+        String encrypted = crypto.encryptTagged(ssn, key, "ssn");
+
+        // Then
+        assertEquals(ssn, crypto.decryptTagged(encrypted, key, "ssn"));
+    }
+
+    /**
+     * Verifies that a value encrypted for one field can't be decrypted as another.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldNotDecryptWithDifferentTag() {
+
+        // Given
+        String encrypted = crypto.encryptTagged("078-05-1120", key, "ssn");
+
+        // When
+        crypto.decryptTagged(encrypted, key, "email");
+
+        // Then
+        // We should get an AuthenticationException because
+        // the tag doesn't match the one that was authenticated.
+    }
+
+    /**
+     * Checks that scoped decryption passes the plaintext to the consumer and wipes it afterwards.
+     */
+    @Test
+    public void shouldWipePlaintextAfterScopedDecryption() {
+
+        // Given
+        final byte[] data = Generate.byteArray(100);
+        byte[] ciphertext = crypto.encrypt(data, key);
+        final byte[][] lent = new byte[1][];
+
+        // When
+        crypto.decryptScoped(ciphertext, key, new PlaintextConsumer() {
+            @Override
+            public void accept(byte[] plaintext) {
+                assertArrayEquals(data, plaintext);
+                // Keep a reference, only so we can check it afterwards:
+                lent[0] = plaintext;
+            }
+        });
+
+        // Then
+        assertNotNull(lent[0]);
+        assertArrayEquals(new byte[data.length], lent[0]);
+    }
+
+    /**
+     * Checks that empty, one-byte and nonce-only inputs are rejected as malformed, rather than causing
+     * an index-out-of-bounds error.
+     */
+    @Test
+    public void shouldRejectShortInputsAsMalformed() {
+
+        // Given
+        byte[] ciphertext = crypto.encrypt(Generate.byteArray(100), key);
+        byte[][] inputs = {
+                new byte[0],
+                new byte[]{ciphertext[0]},
+                Arrays.copyOf(ciphertext, crypto.ciphertextLength(0) - AuthenticatedCrypto.TAG_BITS / 8)
+        };
+
+        for (byte[] input : inputs) {
+
+            // When
+            DecryptionException error = null;
+            try {
+                crypto.decrypt(input, key);
+            } catch (DecryptionException e) {
+                error = e;
+            }
+
+            // Then
+            assertTrue("Byte length " + input.length, error instanceof MalformedDataException);
+        }
+    }
+
+    /**
+     * Checks that data can be encrypted and decrypted with a 12-byte tag, which is recorded in the header.
+     */
+    @Test
+    public void shouldEncryptWithShorterTag() {
+
+        // Given
+        AuthenticatedCrypto shortTag = new AuthenticatedCrypto(AuthenticatedCrypto.NONCE_SIZE, 96);
+        byte[] data = Generate.byteArray(100);
+
+        // When
+        byte[] ciphertext = shortTag.encrypt(data, key);
+
+        // Then
+        assertEquals(AuthenticatedCrypto.FORMAT_VERSION_TAG_LENGTH, ciphertext[0]);
+        assertEquals(12, ciphertext[1]);
+        assertEquals(shortTag.ciphertextLength(data.length), ciphertext.length);
+        assertArrayEquals(data, shortTag.decrypt(ciphertext, key));
+    }
+
+    /**
+     * Checks that data encrypted with one tag length can't be decrypted by an instance configured for another.
+     */
+    @Test
+    public void shouldRejectTagLengthMismatch() {
+
+        // Given
+        AuthenticatedCrypto shortTag = new AuthenticatedCrypto(AuthenticatedCrypto.NONCE_SIZE, 96);
+        byte[][] ciphertexts = {shortTag.encrypt(Generate.byteArray(100), key), crypto.encrypt(Generate.byteArray(100), key)};
+        AuthenticatedCrypto[] decrypters = {crypto, shortTag};
+
+        for (int i = 0; i < ciphertexts.length; i++) {
+
+            // When
+            DecryptionException error = null;
+            try {
+                decrypters[i].decrypt(ciphertexts[i], key);
+            } catch (DecryptionException e) {
+                error = e;
+            }
+
+            // Then
+            assertTrue(error instanceof MalformedDataException);
+        }
+    }
+
+    /**
+     * Checks that an instance configured from custom options round-trips data and enforces the key size.
+     */
+    @Test
+    public void shouldEncryptWithCustomOptions() {
+
+        // Given
+        AuthenticatedCrypto configured = new AuthenticatedCrypto(new CryptoOptions(128, 16, 112));
+        SecretKey key128 = new SecretKeySpec(Generate.byteArray(16), Keys.SYMMETRIC_ALGORITHM);
+        SecretKey key256 = new SecretKeySpec(Generate.byteArray(32), Keys.SYMMETRIC_ALGORITHM);
+        byte[] data = Generate.byteArray(100);
+
+        // When
+        byte[] ciphertext = configured.encrypt(data, key128);
+        IllegalArgumentException wrongKeySize = null;
+        try {
+            configured.encrypt(data, key256);
+        } catch (IllegalArgumentException e) {
+            wrongKeySize = e;
+        }
+
+        // Then
+        assertEquals(16, configured.getNonceSize());
+        assertEquals(112, configured.getTagBits());
+        assertArrayEquals(data, configured.decrypt(ciphertext, key128));
+        assertNotNull(wrongKeySize);
+    }
+
+    /**
+     * Checks that invalid options are rejected when they're created.
+     */
+    @Test
+    public void shouldRejectInvalidOptions() {
+
+        // Given
+        int[][] invalid = {{100, 12, 128}, {-1, 12, 128}, {256, 0, 128}, {256, 256, 128}, {256, -1, 128},
+                {256, 12, 64}, {256, 12, 100}, {256, 12, 136}, {0, 0, 0}};
+
+        for (int[] options : invalid) {
+
+            // When
+            IllegalArgumentException error = null;
+            try {
+                new CryptoOptions(options[0], options[1], options[2]);
+            } catch (IllegalArgumentException e) {
+                error = e;
+            }
+
+            // Then
+            assertNotNull(Arrays.toString(options), error);
+        }
+    }
+
+    /**
+     * Verifies that forged key derivation parameters are rejected as malformed, before a key is derived.
+     */
+    @Test
+    public void shouldRejectForgedKdfParameters() {
+
+        // Given
+        byte[] ciphertext = crypto.encryptWithPassword(Generate.byteArray(10), "correct horse", new KdfParameters(1024, 1, 1));
+        int[][] forged = {
+                {Integer.MAX_VALUE, 1, 1},
+                {1024, Integer.MAX_VALUE, 1},
+                {1024, 1, 1000},
+                {1024, 0, 1},
+                {1024, 1, 0},
+                {4, 1, 1},
+                {-1, 1, 1}
+        };
+
+        for (int[] params : forged) {
+            byte[] tampered = ciphertext.clone();
+            ByteBuffer.wrap(tampered, Generate.SALT_BYTES, 3 * AuthenticatedCrypto.KDF_PARAMETER_BYTES)
+                    .putInt(params[0]).putInt(params[1]).putInt(params[2]);
+
+            // When
+            DecryptionException error = null;
+            try {
+                crypto.decryptWithPassword(tampered, "correct horse");
+            } catch (DecryptionException e) {
+                error = e;
+            }
+
+            // Then
+            assertTrue(Arrays.toString(params), error instanceof MalformedDataException);
+        }
+    }
+
+    /**
+     * Verifies that caller-supplied limits are applied when decrypting with a password.
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldApplyCallerKdfLimits() {
+
+        // Given
+        byte[] ciphertext = crypto.encryptWithPassword(Generate.byteArray(10), "correct horse", new KdfParameters(2048, 2, 1));
+
+        // When
+        crypto.decryptWithPassword(ciphertext, "correct horse", new KdfParameters(1024, 1, 1));
+
+        // Then
+        // We should get a MalformedDataException
+    }
+
+    /**
+     * Checks that a fixed key size is enforced for every kind of key passed in, but not for
+     * keys derived from a password, which are always {@link Keys#SYMMETRIC_KEY_SIZE} bits.
+     */
+    @Test
+    public void shouldApplyKeySizeOnlyToCallerKeys() {
+
+        // Given
+        int otherKeyBits = Keys.SYMMETRIC_KEY_SIZE == 256 ? 128 : 256;
+        AuthenticatedCrypto configured = new AuthenticatedCrypto(new CryptoOptions(otherKeyBits, 12, 128));
+        SecretKey wrongSize = new SecretKeySpec(Generate.byteArray(Keys.SYMMETRIC_KEY_SIZE / 8), Keys.SYMMETRIC_ALGORITHM);
+        byte[] data = Generate.byteArray(10);
+        byte[] encrypted = crypto.encrypt(data, wrongSize);
+        byte[] compact = crypto.encryptCompact(data, wrongSize);
+
+        // When
+        byte[] passwordEncrypted = configured.encryptWithPassword(data, "correct horse", new KdfParameters(1024, 1, 1));
+        byte[] passwordDecrypted = configured.decryptWithPassword(passwordEncrypted, "correct horse");
+        int rejected = 0;
+        try {
+            configured.decrypt(encrypted, wrongSize);
+        } catch (IllegalArgumentException e) {
+            rejected++;
+        }
+        try {
+            configured.decryptCompact(compact, wrongSize);
+        } catch (IllegalArgumentException e) {
+            rejected++;
+        }
+        try {
+            configured.encryptWithTimestamp(data, wrongSize);
+        } catch (IllegalArgumentException e) {
+            rejected++;
+        }
+
+        // Then
+        assertArrayEquals(data, passwordDecrypted);
+        assertEquals(3, rejected);
+    }
+
+    /**
+     * Checks that the default options don't fix the key size, so they don't depend on
+     * {@link Keys#SYMMETRIC_KEY_SIZE} at the time they were created.
+     */
+    @Test
+    public void shouldAcceptAnyKeySizeWithDefaultOptions() {
+
+        // Given
+        CryptoOptions options = new CryptoOptions();
+        AuthenticatedCrypto configured = new AuthenticatedCrypto(options);
+        SecretKey key128 = new SecretKeySpec(Generate.byteArray(16), Keys.SYMMETRIC_ALGORITHM);
+        SecretKey key256 = new SecretKeySpec(Generate.byteArray(32), Keys.SYMMETRIC_ALGORITHM);
+        byte[] data = Generate.byteArray(10);
+
+        // When
+        byte[] decrypted128 = configured.decrypt(configured.encrypt(data, key128), key128);
+        byte[] decrypted256 = configured.decrypt(configured.encrypt(data, key256), key256);
+
+        // Then
+        assertEquals(CryptoOptions.ANY_KEY_SIZE, options.getKeyBits());
+        assertArrayEquals(data, decrypted128);
+        assertArrayEquals(data, decrypted256);
+    }
+
+    /**
+     * Checks that the timestamped and compact formats, which can't record a tag length, are rejected
+     * by an instance with a shorter tag, both for encryption and decryption.
+     */
+    @Test
+    public void shouldRejectShorterTagForTimestampedAndCompactFormats() {
+
+        // Given
+        AuthenticatedCrypto shortTag = new AuthenticatedCrypto(AuthenticatedCrypto.NONCE_SIZE, 96);
+        byte[] data = Generate.byteArray(100);
+        byte[] timestamped = crypto.encryptWithTimestamp(data, key);
+        byte[] compact = crypto.encryptCompact(data, key);
+
+        // When
+        IllegalStateException encryptTimestamped = null;
+        try {
+            shortTag.encryptWithTimestamp(data, key);
+        } catch (IllegalStateException e) {
+            encryptTimestamped = e;
+        }
+        IllegalStateException encryptCompact = null;
+        try {
+            shortTag.encryptCompact(data, key);
+        } catch (IllegalStateException e) {
+            encryptCompact = e;
+        }
+        DecryptionException decryptTimestamped = null;
+        try {
+            shortTag.decrypt(timestamped, key);
+        } catch (DecryptionException e) {
+            decryptTimestamped = e;
+        }
+        DecryptionException decryptCompact = null;
+        try {
+            shortTag.decryptCompact(compact, key);
+        } catch (DecryptionException e) {
+            decryptCompact = e;
+        }
+
+        // Then
+        assertNotNull(encryptTimestamped);
+        assertNotNull(encryptCompact);
+        assertTrue(decryptTimestamped instanceof MalformedDataException);
+        assertTrue(decryptCompact instanceof MalformedDataException);
+    }
+}
@@ -1,11 +1,14 @@
 package com.github.davidcarboni.cryptolite;
 
 import org.junit.BeforeClass;
+import org.junit.Ignore;
 import org.junit.Test;
 
 import java.nio.charset.StandardCharsets;
+import java.util.concurrent.TimeUnit;
 
 import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
 import static org.junit.Assert.assertNull;
 
 /**
@@ -123,4 +126,248 @@ public class ByteArrayTest {
         assertNull(b);
     }
 
+    /**
+     * Verifies that valid UTF-8 is converted to a string.
+     */
+    @Test
+    public void testStringValid() {
+
+        // Given
+        byte[] data = "Mary had a little Café".getBytes(StandardCharsets.UTF_8);
+
+        // When
+        String string = ByteArray.toStringValid(data);
+
+        // Then
+        assertEquals("Mary had a little Café", string);
+    }
+
+    /**
+     * Verifies that invalid UTF-8 is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testStringValidInvalid() {
+
+        // Given
+        // A lone continuation byte and a truncated multi-byte sequence
+        byte[] data = {'a', (byte) 0x80, 'b', (byte) 0xc3};
+
+        // When
+        ByteArray.toStringValid(data);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies byte arrays can be concatenated and split back again.
+     */
+    @Test
+    public void testConcatAndSplitAt() {
+
+        // Given
+        byte[] head = Generate.byteArray(12);
+        byte[] tail = Generate.byteArray(100);
+
+        // When
+        byte[] concatenated = ByteArray.concat(head, tail);
+        byte[][] split = ByteArray.splitAt(concatenated, head.length);
+
+        // Then
+        assertEquals(head.length + tail.length, concatenated.length);
+        assertArrayEquals(head, split[0]);
+        assertArrayEquals(tail, split[1]);
+    }
+
+    /**
+     * Verifies that concatenating no parts gives an empty array.
+     */
+    @Test
+    public void testConcatEmpty() {
+
+        // When
+        byte[] concatenated = ByteArray.concat();
+
+        // Then
+        assertEquals(0, concatenated.length);
+    }
+
+    /**
+     * Verifies that splitting at the full length gives an empty tail.
+     */
+    @Test
+    public void testSplitAtEnd() {
+
+        // Given
+        byte[] data = Generate.byteArray(10);
+
+        // When
+        byte[][] split = ByteArray.splitAt(data, data.length);
+
+        // Then
+        assertArrayEquals(data, split[0]);
+        assertEquals(0, split[1].length);
+    }
+
+    /**
+     * Verifies that splitting input shorter than the index throws an exception.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testSplitAtShortInput() {
+
+        // Given
+        byte[] data = Generate.byteArray(5);
+
+        // When
+        ByteArray.splitAt(data, 6);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies XOR against a known result.
+     */
+    @Test
+    public void testXor() {
+
+        // Given
+        byte[] a = ByteArray.fromHex("0f0ff0aa");
+        byte[] b = ByteArray.fromHex("ff00f055");
+
+        // When
+        byte[] xor = ByteArray.xor(a, b);
+
+        // Then
+        assertEquals("f00f00ff", ByteArray.toHex(xor));
+    }
+
+    /**
+     * Verifies XOR into one of the input arrays gives the same result as {@link ByteArray#xor(byte[], byte[])}.
+     */
+    @Test
+    public void testXorInto() {
+
+        // Given
+        byte[] a = Generate.byteArray(32);
+        byte[] b = Generate.byteArray(32);
+        byte[] expected = ByteArray.xor(a, b);
+
+        // When
+        ByteArray.xorInto(a, a, b);
+
+        // Then
+        assertArrayEquals(expected, a);
+    }
+
+    /**
+     * Verifies that XOR of arrays with different lengths throws an exception.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testXorMismatchedLength() {
+
+        // Given
+        byte[] a = Generate.byteArray(5);
+        byte[] b = Generate.byteArray(6);
+
+        // When
+        ByteArray.xor(a, b);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies that zeroize overwrites every byte of each array and skips nulls.
+     */
+    @Test
+    public void testZeroize() {
+
+        // Given
+        byte[] key = Generate.byteArray(32);
+        byte[] other = Generate.byteArray(16);
+        key[0] = 1;
+
+        // When
+        ByteArray.zeroize(key, null, other);
+
+        // Then
+        assertArrayEquals(new byte[32], key);
+        assertArrayEquals(new byte[16], other);
+    }
+
+    /**
+     * Verifies Crockford base-32 encoding against a known value and that decoding is forgiving of human entry.
+     */
+    @Test
+    public void testCrockford() {
+
+        // Given
+        byte[] data = ByteArray.fromString("hello");
+
+        // When
+        String encoded = ByteArray.toCrockford(data);
+
+        // Then
+        assertEquals("D1JPRV3F", encoded);
+        assertArrayEquals(data, ByteArray.fromCrockford(encoded));
+        assertArrayEquals(data, ByteArray.fromCrockford("d1jp-rv3f"));
+        assertArrayEquals(ByteArray.fromCrockford("0111"), ByteArray.fromCrockford("oIl1"));
+    }
+
+    /**
+     * Checks that appending base-64 gives identical output to {@link ByteArray#toBase64(byte[])}, for every padding case.
+     */
+    @Test
+    public void shouldAppendBase64IdenticalToBase64() {
+
+        for (int length = 0; length <= 32; length++) {
+
+            // Given
+            byte[] bytes = Generate.byteArray(length);
+            StringBuilder destination = new StringBuilder("prefix:");
+
+            // When
+            ByteArray.appendBase64(destination, bytes);
+
+            // Then
+            assertEquals("prefix:" + ByteArray.toBase64(bytes), destination.toString());
+        }
+    }
+
+    /**
+     * Benchmarks {@link ByteArray#appendBase64(StringBuilder, byte[])}, reusing a builder, against
+     * {@link ByteArray#toBase64(byte[])}. Run this manually to compare timings.
+     */
+    @Test
+    @Ignore("Benchmark")
+    public void benchmarkAppendBase64() {
+
+        // Given
+        int count = 100000;
+        byte[] bytes = Generate.byteArray(32);
+        StringBuilder destination = new StringBuilder();
+        for (int i = 0; i < count; i++) {
+            destination.setLength(0);
+            ByteArray.appendBase64(destination, bytes);
+            ByteArray.toBase64(bytes);
+        }
+
+        // When
+        long start = System.nanoTime();
+        for (int i = 0; i < count; i++) {
+            destination.setLength(0);
+            ByteArray.appendBase64(destination, bytes);
+        }
+        long append = System.nanoTime() - start;
+        start = System.nanoTime();
+        for (int i = 0; i < count; i++) {
+            ByteArray.toBase64(bytes);
+        }
+        long convert = System.nanoTime() - start;
+
+        // Then
+        System.out.println(count + " x appendBase64: " + TimeUnit.NANOSECONDS.toMillis(append) + "ms");
+        System.out.println(count + " x toBase64: " + TimeUnit.NANOSECONDS.toMillis(convert) + "ms");
+    }
 }
@@ -15,7 +15,9 @@ import java.io.IOException;
 import java.io.OutputStream;
 import java.lang.reflect.Field;
 import java.security.KeyPair;
+import java.util.ArrayList;
 import java.util.Arrays;
+import java.util.List;
 
 import static org.junit.Assert.*;
 
@@ -598,4 +600,213 @@ public class CryptoTest {
         assertTrue(Arrays.equals(input, plaintext1));
         assertTrue(Arrays.equals(plaintext1, plaintext2));
     }
+
+    /**
+     * Verifies that convergent encryption produces identical ciphertext and keys for identical plaintext.
+     * <p>
+     * Test method for
+     * {@link com.github.davidcarboni.cryptolite.Crypto#encryptConvergent(java.lang.String)}.
+     */
+    @Test
+    public void shouldEncryptSameStringIdenticallyWithConvergentEncryption() {
+
+        // Given
+        String plaintext = "Identical content should deduplicate.";
+
+        // When
+        String ciphertext1 = crypto.encryptConvergent(plaintext);
+        String ciphertext2 = crypto.encryptConvergent(plaintext);
+        SecretKey key1 = Keys.convergentSecretKey(plaintext);
+        SecretKey key2 = Keys.convergentSecretKey(plaintext);
+
+        // Then
+        assertEquals(ciphertext1, ciphertext2);
+        assertArrayEquals(key1.getEncoded(), key2.getEncoded());
+        assertEquals(plaintext, crypto.decrypt(ciphertext1, key1));
+        assertNotEquals(ciphertext1, crypto.encryptConvergent(plaintext + "."));
+    }
+
+    /**
+     * Verifies that progress is reported with increasing values, ending with the size of the input.
+     * <p>
+     * Test method for
+     * {@link com.github.davidcarboni.cryptolite.Crypto#encrypt(java.io.OutputStream, javax.crypto.SecretKey, ProgressListener)}.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReportProgressWhenEncryptingStream() throws IOException {
+
+        // Given
+        byte[] input = Generate.byteArray(5 * 1024 * 1024);
+        final List<Long> progress = new ArrayList<>();
+        ProgressListener listener = new ProgressListener() {
+            @Override
+            public void onProgress(long bytesProcessed) {
+                progress.add(bytesProcessed);
+            }
+        };
+
+        // When
+        ByteArrayOutputStream destination = new ByteArrayOutputStream();
+        OutputStream encryptor = crypto.encrypt(destination, key, listener);
+        IOUtils.copy(new ByteArrayInputStream(input), encryptor);
+        encryptor.close();
+
+        // Then
+        assertTrue(progress.size() > 1);
+        for (int i = 1; i < progress.size(); i++) {
+            assertTrue(progress.get(i) > progress.get(i - 1));
+        }
+        assertEquals(input.length, progress.get(progress.size() - 1).longValue());
+        ByteArrayOutputStream plaintext = new ByteArrayOutputStream();
+        IOUtils.copy(crypto.decrypt(new ByteArrayInputStream(destination.toByteArray()), key), plaintext);
+        assertArrayEquals(input, plaintext.toByteArray());
+    }
+
+    /**
+     * Verifies that an encrypted stream split into parts can be decrypted, whether the split
+     * falls within the initialisation vector or within the data.
+     * <p>
+     * Test method for
+     * {@link com.github.davidcarboni.cryptolite.Crypto#decrypt(javax.crypto.SecretKey, java.io.InputStream...)}.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldDecryptMultiPartStream() throws IOException {
+
+        // Given
+        byte[] input = Generate.byteArray(10000);
+        ByteArrayOutputStream destination = new ByteArrayOutputStream();
+        OutputStream encryptor = crypto.encrypt(destination, key);
+        IOUtils.copy(new ByteArrayInputStream(input), encryptor);
+        encryptor.close();
+        byte[] ciphertext = destination.toByteArray();
+
+        for (int boundary : new int[]{5, 1234}) {
+            byte[][] parts = ByteArray.splitAt(ciphertext, boundary);
+
+            // When
+            ByteArrayOutputStream plaintext = new ByteArrayOutputStream();
+            IOUtils.copy(crypto.decrypt(key, new ByteArrayInputStream(parts[0]), new ByteArrayInputStream(parts[1])), plaintext);
+
+            // Then
+            assertArrayEquals(input, plaintext.toByteArray());
+        }
+    }
+
+    /**
+     * Verifies that a salt and ciphertext can be packed into a String and unpacked,
+     * even when the bytes contain characters commonly used as delimiters.
+     */
+    @Test
+    public void shouldPackAndUnpackStored() {
+
+        // Given
+        byte[] salt = {'$', ':', '|', 0, '$'};
+        byte[] ciphertext = ByteArray.concat(new byte[]{'$', ':', '|'}, Generate.byteArray(32));
+
+        // When
+        String stored = Crypto.packStored(salt, ciphertext);
+        byte[][] unpacked = Crypto.unpackStored(stored);
+
+        // Then
+        assertArrayEquals(salt, unpacked[0]);
+        assertArrayEquals(ciphertext, unpacked[1]);
+        assertNull(Crypto.packStored(null, ciphertext));
+        assertNull(Crypto.unpackStored(null));
+    }
+
+    /**
+     * Verifies that a value with the wrong number of fields is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectMalformedStored() {
+
+        // Given
+        String stored = ByteArray.toBase64(new Frame().addField(Generate.byteArray(16)).toByteArray());
+
+        // When
+        Crypto.unpackStored(stored);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies that synthetic-IV encryption gives identical ciphertext for identical plaintext,
+     * and that it decrypts correctly.
+     */
+    @Test
+    public void shouldEncryptSyntheticDeterministically() {
+
+        // Given
+        SecretKey key = Keys.newSecretKey();
+        String plaintext = "someone@example.com";
+
+        // When
+        String encrypted = crypto.encryptSynthetic(plaintext, key);
+        String again = crypto.encryptSynthetic(plaintext, key);
+        String other = crypto.encryptSynthetic("someone.else@example.com", key);
+
+        // Then
+        assertEquals(encrypted, again);
+        assertNotEquals(encrypted, other);
+        assertEquals(plaintext, crypto.decryptSynthetic(encrypted, key));
+    }
+
+    /**
+     * Verifies that altered synthetic-IV ciphertext is detected.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectTamperedSynthetic() {
+
+        // Given
+        SecretKey key = Keys.newSecretKey();
+        byte[] encrypted = ByteArray.fromBase64(crypto.encryptSynthetic("someone@example.com", key));
+        encrypted[encrypted.length - 1] ^= 1;
+
+        // When
+        crypto.decryptSynthetic(ByteArray.toBase64(encrypted), key);
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Verifies that the supported ciphers include AES-GCM and AES-CTR.
+     */
+    @Test
+    public void shouldListSupportedCiphers() {
+
+        // When
+        List<AlgorithmInfo> ciphers = Crypto.supportedCiphers();
+
+        // Then
+        List<String> names = new ArrayList<>();
+        for (AlgorithmInfo cipher : ciphers) {
+            names.add(cipher.getName());
+            assertEquals(Keys.SYMMETRIC_KEY_SIZE, cipher.getKeySize());
+        }
+        assertTrue(names.contains("AES/GCM/NoPadding"));
+        assertTrue(names.contains("AES/CTR/NoPadding"));
+        assertEquals("12", ciphers.get(0).getDefaults().get("nonceBytes"));
+    }
+
+    /**
+     * Checks that data shorter than an initialisation vector are rejected as malformed.
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldRejectDataShorterThanIv() {
+
+        // Given
+        String encrypted = ByteArray.toBase64(new byte[]{1});
+
+        // When
+        crypto.decrypt(encrypted, key);
+
+        // Then
+        // We should get a MalformedDataException
+    }
 }
@@ -0,0 +1,102 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Test;
+
+import java.io.IOException;
+
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertNull;
+
+/**
+ * Test for {@link Digest}.
+ *
+ * @author David Carboni
+ */
+public class DigestTest {
+
+    /**
+     * Checks the SHA-256 digest of empty input against the known value.
+     */
+    @Test
+    public void shouldDigestEmptyInputWithSha256() {
+
+        // Given
+        byte[] input = new byte[0];
+
+        // When
+        String digest = Digest.sha256Hex(input);
+
+        // Then
+        assertEquals("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", digest);
+    }
+
+    /**
+     * Checks the SHA-256 digest of "abc" against the known value.
+     */
+    @Test
+    public void shouldDigestAbcWithSha256() {
+
+        // Given
+        byte[] input = ByteArray.fromString("abc");
+
+        // When
+        byte[] digest = Digest.sha256(input);
+
+        // Then
+        assertEquals("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", ByteArray.toHex(digest));
+        assertEquals(ByteArray.toHex(digest), Digest.sha256Hex(input));
+    }
+
+    /**
+     * Checks the SHA-512 digest of "abc" against the known value.
+     */
+    @Test
+    public void shouldDigestAbcWithSha512() {
+
+        // Given
+        byte[] input = ByteArray.fromString("abc");
+
+        // When
+        String digest = Digest.sha512Hex(input);
+
+        // Then
+        assertEquals("ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a"
+                + "2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f", digest);
+    }
+
+    /**
+     * Checks that null input gives null output.
+     */
+    @Test
+    public void shouldNotDigestNull() {
+
+        // When
+        String digest = Digest.sha256Hex(null);
+
+        // Then
+        assertNull(digest);
+    }
+
+    /**
+     * Checks that writing data in chunks to a {@link DigestWriter} gives the same digest as hashing it all at once.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldDigestStreamedData() throws IOException {
+
+        // Given
+        byte[] input = Generate.byteArray(100000);
+        DigestWriter writer = new DigestWriter();
+
+        // When
+        int chunk = 777;
+        for (int offset = 0; offset < input.length; offset += chunk) {
+            writer.write(input, offset, Math.min(chunk, input.length - offset));
+        }
+        writer.write(new byte[0]);
+
+        // Then
+        assertEquals(Digest.sha256Hex(input), writer.sumHex());
+    }
+}
@@ -4,6 +4,7 @@ import org.junit.Before;
 import org.junit.BeforeClass;
 import org.junit.Test;
 
+import java.io.ByteArrayInputStream;
 import java.io.IOException;
 import java.io.InputStream;
 import java.nio.file.Files;
@@ -11,6 +12,11 @@ import java.nio.file.Path;
 import java.security.KeyPair;
 import java.security.PrivateKey;
 import java.security.PublicKey;
+import java.util.ArrayList;
+import java.util.Arrays;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
 
 import static org.junit.Assert.*;
 
@@ -319,4 +325,178 @@ public class DigitalSignatureTest {
         assertFalse(result);
     }
 
+    /**
+     * Verifies a signature produced outside of this library, using the scheme documented on
+     * {@link DigitalSignature}, to make sure implementations stay aligned.
+     * <p>
+     * The fixture was generated with OpenSSL using RSASSA-PSS, SHA-256, MGF1-SHA256 and a 32-byte salt.
+     */
+    @Test
+    public void shouldVerifyFixtureSignature() {
+
+        // Given
+        String content = "Cryptolite signature interoperability fixture.";
+        PublicKey publicKey = KeyWrapper.decodePublicKey("-----BEGIN PUBLIC KEY-----\n" +
+                "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAzXEAtXU6UU7UwyLRgO5V\n" +
+                "OyWKewKgHCFOTkCtxX1J6hG1FJwjZ6YT5EQa9QHee2pNa3G5aMnCIPIOmVtBYZt2\n" +
+                "8I7GnDNjZYlN9aClsf1SjPMJ/95qs7+wGFh2nNZZZ9JOCo+cfbaN2MkNF4m/YNbC\n" +
+                "qRLzl5xGFoM6AH2/Gpx2pwdudf86XYq8uLyHi0DXOvhdNfX+YXx0H5amELr0HDXO\n" +
+                "443rS1joy8iXZ0vygE55gpICfETGErCiimhaM+XAbPx2KeWQs/Z2KeJN55swdV5k\n" +
+                "GDMvnLQHR1qthxJibO/ifcvCfLk4IZQhvxI4bAGrR8ZxhXrYQ8MRNfxXLsUqqXpO\n" +
+                "4QIDAQAB\n" +
+                "-----END PUBLIC KEY-----");
+        String signature = "o6uofAVqrEkamssq3RXUzFsYj7eWn8uOPoXCCZkGNaut9ZdYibFZPB05NY9P7uCn3ksfrPRWu0P8ES7L5N5FFJoz7qS+" +
+                "S028+RIKl329Xcv4lG9+Gvj4MhdBNQmwrR8uotdq2EAsz9/uvF1Pbw1d7f+P+mZmsUJsVNOpKtkMgZ0ibyqHVXvgujnptrX11W38" +
+                "uCZbjHNiwxibb7Vt2LgLlOFF/8h0n0UPlTTwOriyOhsGXuu5o4ujs9wysAHbz+UqpfXv/sfH5tnRUY8UCWwnfBqZ28las7yNmoPw" +
+                "VDiQeyVSkRrDo0KaczjS5L6MwXfb+MbTVVT2LlKCmgmLKxSCyA==";
+
+        // When
+        boolean result = digitalSignature.verify(content, publicKey, signature);
+
+        // Then
+        assertTrue(result);
+    }
+
+    /**
+     * Checks that a detached signature can be verified and records the algorithm and key ID.
+     */
+    @Test
+    public void shouldSignAndVerifyDetached() {
+
+        // Given
+        byte[] artifact = Generate.byteArray(1000);
+        String keyId = Keys.keyId(keyPair.getPublic());
+
+        // When
+        String detached = digitalSignature.signDetached(new ByteArrayInputStream(artifact), keyPair.getPrivate(), keyId);
+        boolean result = digitalSignature.verifyDetached(new ByteArrayInputStream(artifact), keyPair.getPublic(), keyId, detached);
+
+        // Then
+        assertTrue(detached.startsWith(DigitalSignature.DETACHED_HEADER));
+        assertTrue(detached.contains(DigitalSignature.ALGORITHM));
+        assertTrue(detached.contains(keyId));
+        assertTrue(result);
+    }
+
+    /**
+     * Checks that a detached signature recording a different key ID is reported.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldReportDetachedKeyIdMismatch() {
+
+        // Given
+        byte[] artifact = Generate.byteArray(1000);
+        String detached = digitalSignature.signDetached(new ByteArrayInputStream(artifact), keyPair.getPrivate(), "release-2023");
+
+        // When
+        digitalSignature.verifyDetached(new ByteArrayInputStream(artifact), keyPair.getPublic(), "release-2024", detached);
+
+        // Then
+        // We should get an IllegalArgumentException because
+        // the signature records a different key ID.
+    }
+
+    /**
+     * Checks that batch verification reports the result for each item.
+     */
+    @Test
+    public void shouldVerifyBatch() {
+
+        // Given
+        KeyPair signer = Keys.newSigningKeyPair();
+        KeyPair other = Keys.newSigningKeyPair();
+        DigitalSignature ed25519 = DigitalSignature.forKey(signer.getPrivate());
+        List<BatchItem> items = new ArrayList<>();
+        for (int i = 0; i < 5; i++) {
+            byte[] message = ByteArray.fromString("Event " + i);
+            KeyPair keyPair = i % 2 == 0 ? signer : other;
+            String signature = ed25519.sign(new ByteArrayInputStream(message), keyPair.getPrivate());
+            items.add(new BatchItem(keyPair.getPublic(), message, signature));
+        }
+        // Tamper with one message:
+        items.set(3, new BatchItem(other.getPublic(), ByteArray.fromString("Event 3!"), items.get(3).getSignature()));
+
+        // When
+        boolean[] results = ed25519.verifyBatch(items);
+
+        // Then
+        assertArrayEquals(new boolean[]{true, true, true, false, true}, results);
+    }
+
+    /**
+     * Verifies that the order of map entries doesn't affect a JSON signature.
+     * {@value Keys#SIGNING_ALGORITHM} signatures are deterministic, so the signatures can be compared directly.
+     */
+    @Test
+    public void shouldSignJsonIndependentOfKeyOrder() {
+
+        // Given
+        KeyPair signingKeyPair = Keys.newSigningKeyPair();
+        DigitalSignature signature = DigitalSignature.forKey(signingKeyPair.getPrivate());
+        Map<String, Object> first = new LinkedHashMap<>();
+        first.put("amount", 1250);
+        first.put("currency", "GBP");
+        first.put("tags", Arrays.asList("a", "b"));
+        Map<String, Object> second = new LinkedHashMap<>();
+        second.put("tags", Arrays.asList("a", "b"));
+        second.put("currency", "GBP");
+        second.put("amount", 1250);
+
+        // When
+        String firstSignature = signature.signJson(first, signingKeyPair.getPrivate());
+        String secondSignature = signature.signJson(second, signingKeyPair.getPrivate());
+
+        // Then
+        assertEquals("{\"amount\":1250,\"currency\":\"GBP\",\"tags\":[\"a\",\"b\"]}", DigitalSignature.canonicalJson(second));
+        assertEquals(firstSignature, secondSignature);
+        assertTrue(signature.verifyJson(second, signingKeyPair.getPublic(), firstSignature));
+    }
+
+    /**
+     * Verifies that a JSON signature doesn't verify if the value has changed.
+     */
+    @Test
+    public void shouldNotVerifyModifiedJson() {
+
+        // Given
+        Map<String, Object> value = new LinkedHashMap<>();
+        value.put("amount", 1250);
+        String signature = digitalSignature.signJson(value, keyPair.getPrivate());
+
+        // When
+        value.put("amount", 9999);
+        boolean result = digitalSignature.verifyJson(value, keyPair.getPublic(), signature);
+
+        // Then
+        assertFalse(result);
+    }
+
+    /**
+     * Checks that an item whose key doesn't suit the algorithm is reported as invalid, rather than failing
+     * the whole batch, and that {@link DigitalSignature#verifyAll(List)} reports whether every item is valid.
+     */
+    @Test
+    public void shouldReportInvalidKeyInBatch() {
+
+        // Given
+        KeyPair signer = Keys.newSigningKeyPair();
+        DigitalSignature ed25519 = DigitalSignature.forKey(signer.getPrivate());
+        byte[] message = ByteArray.fromString("Event");
+        String signature = ed25519.sign(new ByteArrayInputStream(message), signer.getPrivate());
+        List<BatchItem> valid = new ArrayList<>();
+        valid.add(new BatchItem(signer.getPublic(), message, signature));
+        valid.add(new BatchItem(signer.getPublic(), message, signature));
+        List<BatchItem> mixed = new ArrayList<>(valid);
+        mixed.add(new BatchItem(Keys.newKeyPair().getPublic(), message, signature));
+
+        // When
+        boolean[] results = ed25519.verifyBatch(mixed);
+        boolean allValid = ed25519.verifyAll(valid);
+        boolean allMixed = ed25519.verifyAll(mixed);
+
+        // Then
+        assertArrayEquals(new boolean[]{true, true, false}, results);
+        assertTrue(allValid);
+        assertFalse(allMixed);
+    }
 }
@@ -0,0 +1,81 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+
+/**
+ * Test for {@link Envelope}.
+ *
+ * @author David Carboni
+ */
+public class EnvelopeTest {
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    /**
+     * A {@link RemoteKey} that wraps data keys locally and records how many times it's called.
+     */
+    static class StubRemoteKey implements RemoteKey {
+
+        SecretKey kek = Keys.newSecretKey();
+        int wrapCalls;
+        int unwrapCalls;
+
+        @Override
+        public byte[] wrap(byte[] dataKey) {
+            wrapCalls++;
+            return KeyWrapper.aesKeyWrap(kek, dataKey);
+        }
+
+        @Override
+        public byte[] unwrap(byte[] wrappedDataKey) {
+            unwrapCalls++;
+            return KeyWrapper.aesKeyUnwrap(kek, wrappedDataKey);
+        }
+    }
+
+    /**
+     * Checks that a message round-trips, with one call to the remote key in each direction.
+     */
+    @Test
+    public void shouldSealAndOpen() {
+
+        // Given
+        StubRemoteKey remoteKey = new StubRemoteKey();
+        byte[] message = Generate.byteArray(100000);
+
+        // When
+        byte[] envelope = Envelope.seal(remoteKey, message);
+        byte[] opened = Envelope.open(remoteKey, envelope);
+
+        // Then
+        assertArrayEquals(message, opened);
+        assertEquals(1, remoteKey.wrapCalls);
+        assertEquals(1, remoteKey.unwrapCalls);
+    }
+
+    /**
+     * Checks that an envelope can't be opened with a different remote key.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotOpenWithDifferentRemoteKey() {
+
+        // Given
+        byte[] envelope = Envelope.seal(new StubRemoteKey(), ByteArray.fromString("Secret"));
+
+        // When
+        Envelope.open(new StubRemoteKey(), envelope);
+
+        // Then
+        // We should get an IllegalArgumentException from the remote key
+    }
+}
@@ -0,0 +1,85 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+import java.util.HashMap;
+import java.util.Map;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertNull;
+
+/**
+ * Test for {@link EnvironmentKeyProvider}.
+ *
+ * @author David Carboni
+ */
+public class EnvironmentKeyProviderTest {
+
+    /**
+     * Checks that keys are resolved by identifier and that reloading picks up changes.
+     */
+    @Test
+    public void shouldResolveKeysAndReload() {
+
+        // Given
+        final Map<String, String> environment = new HashMap<>();
+        String v1 = Keys.newSecretKeyBase64();
+        String v2 = Keys.newSecretKeyBase64();
+        environment.put("CRYPTOLITE_KEY_v1", v1);
+        environment.put("CRYPTOLITE_KEY_v2", v2);
+        environment.put("OTHER_VARIABLE", "ignored");
+        EnvironmentKeyProvider provider = new EnvironmentKeyProvider("CRYPTOLITE_KEY_") {
+            @Override
+            protected Map<String, String> getEnvironment() {
+                return environment;
+            }
+        };
+
+        // When
+        SecretKey key1 = provider.getKey("v1");
+        SecretKey key2 = provider.getKey("v2");
+        String v3 = Keys.newSecretKeyBase64();
+        environment.put("CRYPTOLITE_KEY_v3", v3);
+        environment.remove("CRYPTOLITE_KEY_v1");
+        SecretKey beforeReload = provider.getKey("v3");
+        provider.reload();
+
+        // Then
+        assertArrayEquals(ByteArray.fromBase64(v1), key1.getEncoded());
+        assertArrayEquals(ByteArray.fromBase64(v2), key2.getEncoded());
+        assertNull(beforeReload);
+        assertArrayEquals(ByteArray.fromBase64(v3), provider.getKey("v3").getEncoded());
+        assertNull(provider.getKey("v1"));
+        assertNull(provider.getKey("OTHER_VARIABLE"));
+    }
+
+    /**
+     * Checks that keys still resolve correctly with the constant-time lookup.
+     */
+    @Test
+    public void shouldResolveKeysWithConstantTimeLookup() {
+
+        // Given
+        final Map<String, String> environment = new HashMap<>();
+        String v1 = Keys.newSecretKeyBase64();
+        String v2 = Keys.newSecretKeyBase64();
+        environment.put("CRYPTOLITE_KEY_v1", v1);
+        environment.put("CRYPTOLITE_KEY_v2", v2);
+        EnvironmentKeyProvider provider = new EnvironmentKeyProvider("CRYPTOLITE_KEY_") {
+            @Override
+            protected Map<String, String> getEnvironment() {
+                return environment;
+            }
+        };
+
+        // When
+        provider.setConstantTimeLookup(true);
+
+        // Then
+        assertArrayEquals(ByteArray.fromBase64(v1), provider.getKey("v1").getEncoded());
+        assertArrayEquals(ByteArray.fromBase64(v2), provider.getKey("v2").getEncoded());
+        assertNull(provider.getKey("v3"));
+        assertNull(provider.getKey(null));
+    }
+}
@@ -0,0 +1,67 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Test;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+
+/**
+ * Test for {@link Frame} and {@link ByteArray#unframe(byte[])}.
+ *
+ * @author David Carboni
+ */
+public class FrameTest {
+
+    /**
+     * Verifies that framed fields, including an empty one, can be recovered.
+     */
+    @Test
+    public void shouldFrameAndUnframe() {
+
+        // Given
+        byte[] key = Generate.byteArray(32);
+        byte[] empty = new byte[0];
+        byte[] ciphertext = Generate.byteArray(100);
+
+        // When
+        byte[] framed = new Frame().addField(key).addField(empty).addField(ciphertext).toByteArray();
+        byte[][] fields = ByteArray.unframe(framed);
+
+        // Then
+        assertEquals(3 * Frame.LENGTH_BYTES + key.length + ciphertext.length, framed.length);
+        assertEquals(3, fields.length);
+        assertArrayEquals(key, fields[0]);
+        assertArrayEquals(empty, fields[1]);
+        assertArrayEquals(ciphertext, fields[2]);
+    }
+
+    /**
+     * Verifies that an empty frame has no fields.
+     */
+    @Test
+    public void shouldUnframeEmptyFrame() {
+
+        // When
+        byte[][] fields = ByteArray.unframe(new Frame().toByteArray());
+
+        // Then
+        assertEquals(0, fields.length);
+    }
+
+    /**
+     * Verifies that truncated input is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotUnframeTruncatedInput() {
+
+        // Given
+        byte[] framed = new Frame().addField(Generate.byteArray(10)).toByteArray();
+        byte[] truncated = ByteArray.splitAt(framed, framed.length - 1)[0];
+
+        // When
+        ByteArray.unframe(truncated);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+}
@@ -1,7 +1,20 @@
 package com.github.davidcarboni.cryptolite;
 
+import org.apache.commons.io.IOUtils;
+import org.junit.Ignore;
 import org.junit.Test;
 
+import javax.crypto.SecretKey;
+import java.io.IOException;
+import java.io.InputStream;
+import java.math.BigInteger;
+import java.security.ProviderException;
+import java.security.SecureRandom;
+import java.util.Arrays;
+import java.util.HashSet;
+import java.util.Set;
+import java.util.concurrent.TimeUnit;
+
 import static org.junit.Assert.*;
 
 /**
@@ -60,6 +73,22 @@ public class GenerateTest {
         assertEquals("Unexpected salt byte-length", Generate.SALT_BYTES, saltBytes.length);
     }
 
+    /**
+     * Checks that the number of raw salt bytes matches the length specified in
+     * {@link Generate#SALT_BYTES}.
+     */
+    @Test
+    public void testSaltBytesLength() {
+
+        // When
+        // We generate a raw salt
+        byte[] salt = Generate.saltBytes();
+
+        // Then
+        // It should be of the expected length
+        assertEquals("Unexpected salt byte-length", Generate.SALT_BYTES, salt.length);
+    }
+
     /**
      * Checks the number of characters and the content of the returned password matches the expected content.
      */
@@ -142,4 +171,593 @@ public class GenerateTest {
         }
     }
 
+    /**
+     * Checks the content of a password generated from a custom alphabet.
+     */
+    @Test
+    public void testPasswordFromAlphabet() {
+
+        // Given
+        String alphabet = "0123456789abcdef";
+        final int length = 32;
+
+        // When
+        String password = Generate.password(length, alphabet);
+
+        // Then
+        assertEquals("Unexpected password length", length, password.length());
+        assertTrue("Unexpected password content", password.matches("[0-9a-f]+"));
+    }
+
+    /**
+     * Checks that very long passwords, spanning several chunks of random bytes, are generated correctly and quickly.
+     */
+    @Test(timeout = 5000)
+    public void testLongPassword() {
+
+        // Given
+        final int length = 100000;
+
+        // When
+        String password = Generate.password(length);
+
+        // Then
+        assertEquals("Unexpected password length", length, password.length());
+        assertTrue("Unexpected password content", password.matches("[A-Za-z0-9]+"));
+    }
+
+    /**
+     * Checks that deterministic tokens are the same for the same input and different for different input.
+     */
+    @Test
+    public void testDeterministicToken() {
+
+        // Given
+        SecretKey secret = Keys.newSecretKey();
+
+        // When
+        String token1 = Generate.deterministicToken(secret, "order-123");
+        String token2 = Generate.deterministicToken(secret, "order-123");
+        String token3 = Generate.deterministicToken(secret, "order-124");
+
+        // Then
+        assertEquals("Expected identical tokens.", token1, token2);
+        assertNotEquals("Got identical tokens for different input.", token1, token3);
+        assertEquals("Unexpected token bit-length", Generate.TOKEN_BITS, ByteArray.fromHex(token1).length * 8);
+    }
+
+    /**
+     * Checks that recovery codes are generated in the expected number and format and are all different.
+     */
+    @Test
+    public void testRecoveryCodes() {
+
+        // Given
+        final int count = 10;
+        final int length = 8;
+
+        // When
+        String[] codes = Generate.recoveryCodes(count, length);
+
+        // Then
+        assertEquals("Unexpected number of codes", count, codes.length);
+        assertEquals("Got duplicate codes", count, new HashSet<>(Arrays.asList(codes)).size());
+        for (String code : codes) {
+            assertTrue("Unexpected code format: " + code, code.matches("[A-HJKMNP-Z2-9]{4}-[A-HJKMNP-Z2-9]{4}"));
+        }
+    }
+
+    /**
+     * Checks that tokens in each encoding decode back to the expected number of bytes.
+     */
+    @Test
+    public void testTokenEncodings() {
+
+        // When
+        String hex = Generate.token(TokenEncoding.HEX);
+        String base64Url = Generate.token(TokenEncoding.BASE64URL);
+        String base32 = Generate.token(TokenEncoding.BASE32);
+
+        // Then
+        assertEquals(Generate.TOKEN_BITS / 8, ByteArray.fromHex(hex).length);
+        assertEquals(Generate.TOKEN_BITS / 8, ByteArray.fromBase64Url(base64Url).length);
+        assertEquals(Generate.TOKEN_BITS / 8, ByteArray.fromBase32(base32).length);
+        assertTrue("Unexpected base64url content", base64Url.matches("[A-Za-z0-9_-]+"));
+        assertTrue("Unexpected base32 content", base32.matches("[A-Z2-7]+"));
+    }
+
+    /**
+     * Checks that transient failures of the source of randomness are retried until the array is filled.
+     */
+    @Test
+    public void testByteArrayRetriesTransientFailures() {
+
+        // Given
+        SecureRandom flaky = new SecureRandom() {
+            private int calls;
+
+            @Override
+            public synchronized void nextBytes(byte[] bytes) {
+                if (++calls < 3) {
+                    throw new ProviderException("Transient failure");
+                }
+                Arrays.fill(bytes, (byte) 1);
+            }
+        };
+
+        // When
+        byte[] bytes = Generate.byteArray(16, flaky);
+
+        // Then
+        byte[] expected = new byte[16];
+        Arrays.fill(expected, (byte) 1);
+        assertArrayEquals(expected, bytes);
+    }
+
+    /**
+     * Checks that a persistently failing source of randomness results in an exception.
+     */
+    @Test(expected = IllegalStateException.class)
+    public void testByteArrayGivesUpAfterRepeatedFailures() {
+
+        // Given
+        SecureRandom broken = new SecureRandom() {
+            @Override
+            public synchronized void nextBytes(byte[] bytes) {
+                throw new ProviderException("Persistent failure");
+            }
+        };
+
+        // When
+        Generate.byteArray(16, broken);
+
+        // Then
+        // We should get an IllegalStateException
+    }
+
+    /**
+     * Checks password entropy calculations against known values.
+     */
+    @Test
+    public void testPasswordEntropyBits() {
+
+        // When
+        double eightAlphanumeric = Generate.passwordEntropyBits(8, Generate.PASSWORD_ALPHABET_SIZE);
+        double sixteenHex = Generate.passwordEntropyBits(16, 16);
+
+        // Then
+        assertEquals(62, Generate.PASSWORD_ALPHABET_SIZE);
+        assertEquals(47.63, eightAlphanumeric, 0.01);
+        assertEquals(64, sixteenHex, 0.0001);
+    }
+
+    /**
+     * Checks that buffered random values have the requested length and don't repeat,
+     * including across a refill of the buffer.
+     */
+    @Test
+    public void shouldGenerateFastByteArrays() {
+
+        // Given
+        int length = AuthenticatedCrypto.NONCE_SIZE;
+        int count = Generate.FAST_BUFFER_BYTES / length * 3;
+        Set<String> values = new HashSet<>();
+
+        for (int i = 0; i < count; i++) {
+
+            // When
+            byte[] value = Generate.fastByteArray(length);
+
+            // Then
+            assertEquals(length, value.length);
+            assertTrue("Got a repeated value.", values.add(ByteArray.toHex(value)));
+        }
+    }
+
+    /**
+     * Checks that the random stream fills reads completely and doesn't repeat itself.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReadFromRandomInputStream() throws IOException {
+
+        // Given
+        InputStream random = Generate.randomInputStream();
+        byte[] first = new byte[1000];
+        byte[] second = new byte[1000];
+
+        // When
+        IOUtils.readFully(random, first);
+        IOUtils.readFully(random, second);
+
+        // Then
+        assertFalse(Arrays.equals(first, second));
+        assertFalse(Arrays.equals(new byte[1000], first));
+        assertTrue(random.read() >= 0);
+    }
+
+    /**
+     * Checks that short codes have the requested length and decode whatever the case or hyphenation.
+     */
+    @Test
+    public void shouldGenerateShortCode() {
+
+        // When
+        String code = Generate.shortCode(8);
+
+        // Then
+        assertEquals(8, code.length());
+        assertTrue(code.matches("[0-9ABCDEFGHJKMNPQRSTVWXYZ]{8}"));
+        byte[] decoded = ByteArray.fromCrockford(code);
+        String hyphenated = code.substring(0, 4).toLowerCase() + "-" + code.substring(4);
+        assertArrayEquals(decoded, ByteArray.fromCrockford(hyphenated));
+    }
+
+    /**
+     * Verifies that passwords without runs contain no three repeated or sequential characters.
+     */
+    @Test
+    public void shouldGeneratePasswordWithoutRuns() {
+
+        // Given
+        // A small alphabet, so runs would be common
+        String alphabet = "abc";
+
+        // When
+        String password = Generate.passwordNoRuns(10000, alphabet);
+
+        // Then
+        assertEquals(10000, password.length());
+        for (int i = 2; i < password.length(); i++) {
+            int step = password.charAt(i) - password.charAt(i - 1);
+            boolean run = Math.abs(step) <= 1 && password.charAt(i - 1) - password.charAt(i - 2) == step;
+            assertFalse("Run at " + i + ": " + password.substring(i - 2, i + 1), run);
+        }
+    }
+
+    /**
+     * Verifies that an alphabet that can't avoid runs is rejected, rather than looping forever.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectUnsatisfiablePasswordWithoutRuns() {
+
+        // When
+        Generate.passwordNoRuns(3, "aa");
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies that random doubles are in the range [0, 1) with a mean close to 0.5.
+     */
+    @Test
+    public void shouldGenerateRandomDoubles() {
+
+        // Given
+        int samples = 100000;
+
+        // When
+        double sum = 0;
+        double min = 1;
+        double max = 0;
+        for (int i = 0; i < samples; i++) {
+            double value = Generate.randomDouble();
+            sum += value;
+            min = Math.min(min, value);
+            max = Math.max(max, value);
+        }
+
+        // Then
+        // The standard error of the mean is about 0.001, so this is a very generous bound
+        assertTrue(min >= 0);
+        assertTrue(max < 1);
+        assertEquals(0.5, sum / samples, 0.01);
+    }
+
+    /**
+     * Verifies that samples contain the right number of distinct indices, all within range.
+     */
+    @Test
+    public void shouldSampleWithoutReplacement() {
+
+        // Given
+        int n = 10;
+        int k = 3;
+        int[] counts = new int[n];
+
+        for (int run = 0; run < 1000; run++) {
+
+            // When
+            int[] sample = Generate.sample(n, k);
+
+            // Then
+            assertEquals(k, sample.length);
+            Set<Integer> distinct = new HashSet<>();
+            for (int index : sample) {
+                assertTrue(index >= 0 && index < n);
+                distinct.add(index);
+                counts[index]++;
+            }
+            assertEquals(k, distinct.size());
+        }
+
+        // Every index should be selected at some point
+        for (int count : counts) {
+            assertTrue(count > 0);
+        }
+    }
+
+    /**
+     * Verifies that asking for more indices than are available is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotSampleMoreThanAvailable() {
+
+        // When
+        Generate.sample(3, 4);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies that a hex token can be converted to base64url and back without changing the underlying bytes.
+     */
+    @Test
+    public void shouldConvertTokenEncoding() {
+
+        // Given
+        String hex = Generate.token();
+
+        // When
+        String base64Url = Generate.convertToken(hex, TokenEncoding.HEX, TokenEncoding.BASE64URL);
+        String backAgain = Generate.convertToken(base64Url, TokenEncoding.BASE64URL, TokenEncoding.HEX);
+
+        // Then
+        assertArrayEquals(ByteArray.fromHex(hex), ByteArray.fromBase64Url(base64Url));
+        assertTrue(base64Url.length() < hex.length());
+        assertEquals(hex, backAgain);
+    }
+
+    /**
+     * Verifies that a token that isn't in the source encoding is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectTokenInWrongEncoding() {
+
+        // Given
+        String base64Url = Generate.token(TokenEncoding.BASE64URL);
+
+        // When
+        Generate.convertToken(base64Url, TokenEncoding.BASE32, TokenEncoding.HEX);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Checks that random big integers are always below the upper bound, and that every value in a small range occurs.
+     */
+    @Test
+    public void shouldGenerateRandomBigIntegerBelowMax() {
+
+        // Given
+        BigInteger max = BigInteger.valueOf(10);
+        Set<BigInteger> seen = new HashSet<>();
+
+        for (int i = 0; i < 1000; i++) {
+
+            // When
+            BigInteger value = Generate.randomBigInteger(max);
+
+            // Then
+            assertTrue(value.signum() >= 0);
+            assertTrue(value.compareTo(max) < 0);
+            seen.add(value);
+        }
+        assertEquals(10, seen.size());
+    }
+
+    /**
+     * Checks that a non-positive upper bound is rejected.
+     */
+    @Test
+    public void shouldRejectNonPositiveBigIntegerMax() {
+
+        for (BigInteger max : new BigInteger[]{BigInteger.ZERO, BigInteger.valueOf(-1)}) {
+
+            // Given
+            IllegalArgumentException error = null;
+
+            // When
+            try {
+                Generate.randomBigInteger(max);
+            } catch (IllegalArgumentException e) {
+                error = e;
+            }
+
+            // Then
+            assertNotNull(error);
+        }
+    }
+
+    /**
+     * Checks rolling codes against the HOTP test vectors in RFC 4226, Appendix D.
+     */
+    @Test
+    public void shouldGenerateRfc4226RollingCodes() {
+
+        // Given
+        byte[] key = ByteArray.fromString("12345678901234567890");
+        String[] expected = {"755224", "287082", "359152", "969429", "338314",
+                "254676", "287922", "162583", "399871", "520489"};
+
+        for (int counter = 0; counter < expected.length; counter++) {
+
+            // When
+            String code = Generate.rollingCode(key, counter, 6);
+
+            // Then
+            assertEquals("Counter " + counter, expected[counter], code);
+        }
+    }
+
+    /**
+     * Checks that bulk password generation returns the requested number of passwords, of the requested length,
+     * using only password characters.
+     */
+    @Test
+    public void shouldGenerateManyPasswords() {
+
+        // Given
+        int count = 1000;
+        int length = 12;
+
+        // When
+        String[] passwords = Generate.passwords(count, length);
+
+        // Then
+        assertEquals(count, passwords.length);
+        Set<String> unique = new HashSet<>();
+        for (String password : passwords) {
+            assertEquals(length, password.length());
+            assertTrue(password, password.matches("[A-Za-z0-9]+"));
+            unique.add(password);
+        }
+        assertEquals(count, unique.size());
+    }
+
+    /**
+     * Benchmarks {@link Generate#passwords(int, int)} against calling {@link Generate#password(int)} in a loop.
+     * Run this manually to compare timings.
+     */
+    @Test
+    @Ignore("Benchmark")
+    public void benchmarkPasswords() {
+
+        // Given
+        int count = 10000;
+        int length = 12;
+        Generate.passwords(count, length);
+        for (int i = 0; i < count; i++) {
+            Generate.password(length);
+        }
+
+        // When
+        long start = System.nanoTime();
+        Generate.passwords(count, length);
+        long bulk = System.nanoTime() - start;
+        start = System.nanoTime();
+        for (int i = 0; i < count; i++) {
+            Generate.password(length);
+        }
+        long loop = System.nanoTime() - start;
+
+        // Then
+        System.out.println("passwords(" + count + ", " + length + "): " + TimeUnit.NANOSECONDS.toMillis(bulk) + "ms");
+        System.out.println(count + " x password(" + length + "): " + TimeUnit.NANOSECONDS.toMillis(loop) + "ms");
+    }
+
+    /**
+     * Benchmarks {@link Generate#password(int)}, which generates random bytes in chunks, against generating a
+     * single buffer of random bytes for the whole length. Run this manually to compare timings.
+     */
+    @Test
+    @Ignore("Benchmark")
+    public void benchmarkLongPassword() {
+
+        // Given
+        int length = 100000;
+        Generate.password(length);
+        singleBufferPassword(length);
+
+        // When
+        long start = System.nanoTime();
+        Generate.password(length);
+        long chunked = System.nanoTime() - start;
+        start = System.nanoTime();
+        singleBufferPassword(length);
+        long single = System.nanoTime() - start;
+
+        // Then
+        System.out.println("password(" + length + "), chunked: " + TimeUnit.NANOSECONDS.toMillis(chunked) + "ms");
+        System.out.println("password(" + length + "), single buffer: " + TimeUnit.NANOSECONDS.toMillis(single) + "ms");
+    }
+
+    /**
+     * The previous implementation of {@link Generate#password(int)}, which generated all the random bytes up front.
+     */
+    private static String singleBufferPassword(int length) {
+        String characters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789";
+        StringBuilder result = new StringBuilder();
+        byte[] values = Generate.byteArray(length);
+        int index = 0;
+        for (int i = 0; i < length; i++) {
+            index += (values[i] & 0xff);
+            index = index % characters.length();
+            result.append(characters.charAt(index));
+        }
+        return result.toString();
+    }
+
+    /**
+     * Benchmarks {@link Generate#fastByteArray(int)} against {@link Generate#byteArray(int)} for nonce-sized reads.
+     * Run this manually to compare timings.
+     */
+    @Test
+    @Ignore("Benchmark")
+    public void benchmarkFastByteArray() {
+
+        // Given
+        int count = 100000;
+        int length = 12;
+        for (int i = 0; i < count; i++) {
+            Generate.fastByteArray(length);
+            Generate.byteArray(length);
+        }
+
+        // When
+        long start = System.nanoTime();
+        for (int i = 0; i < count; i++) {
+            Generate.fastByteArray(length);
+        }
+        long fast = System.nanoTime() - start;
+        start = System.nanoTime();
+        for (int i = 0; i < count; i++) {
+            Generate.byteArray(length);
+        }
+        long standard = System.nanoTime() - start;
+
+        // Then
+        System.out.println(count + " x fastByteArray(" + length + "): " + TimeUnit.NANOSECONDS.toMillis(fast) + "ms");
+        System.out.println(count + " x byteArray(" + length + "): " + TimeUnit.NANOSECONDS.toMillis(standard) + "ms");
+    }
+
+    /**
+     * Checks that bulk password generation from a custom alphabet uses only that alphabet, and rejects an empty one.
+     */
+    @Test
+    public void shouldGenerateManyPasswordsFromAlphabet() {
+
+        // Given
+        String alphabet = "abc";
+
+        // When
+        String[] passwords = Generate.passwords(100, 8, alphabet);
+        IllegalArgumentException empty = null;
+        try {
+            Generate.passwords(100, 8, "");
+        } catch (IllegalArgumentException e) {
+            empty = e;
+        }
+
+        // Then
+        assertEquals(100, passwords.length);
+        for (String password : passwords) {
+            assertTrue(password, password.matches("[abc]{8}"));
+        }
+        assertNotNull(empty);
+    }
 }
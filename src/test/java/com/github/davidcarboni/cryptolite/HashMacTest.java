@@ -4,8 +4,13 @@ import org.apache.commons.lang.StringUtils;
 import org.junit.Test;
 
 import javax.crypto.SecretKey;
+import java.io.ByteArrayInputStream;
+import java.io.IOException;
 
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
 import static org.junit.Assert.assertFalse;
+import static org.junit.Assert.assertNotEquals;
 import static org.junit.Assert.assertTrue;
 
 /**
@@ -77,4 +82,48 @@ public class HashMacTest {
         assertTrue(StringUtils.equals(hmac, verification));
     }
 
+    /**
+     * Verifies that the HMAC of a stream, read in chunks, matches the HMAC of the same bytes in memory.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldDigestStream() throws IOException {
+
+        // Given
+        HashMac hashMac = new HashMac(Keys.newSecretKey());
+        byte[] message = Generate.byteArray(100000);
+
+        // When
+        byte[] streamed = hashMac.digest(new ByteArrayInputStream(message));
+
+        // Then
+        assertArrayEquals(hashMac.digest(message), streamed);
+        assertTrue(hashMac.verify(new ByteArrayInputStream(message), streamed));
+        message[0] ^= 1;
+        assertFalse(hashMac.verify(new ByteArrayInputStream(message), streamed));
+    }
+
+    /**
+     * Checks that the same value gives the same identifier, and that different keys give different identifiers.
+     */
+    @Test
+    public void shouldDeriveStableIdentifier() {
+
+        // Given
+        String email = "someone@example.com";
+        HashMac hashMac = new HashMac(Keys.newSecretKey());
+        HashMac otherKey = new HashMac(Keys.newSecretKey());
+
+        // When
+        String identifier = hashMac.identifier(email);
+        String again = hashMac.identifier(email);
+        String other = otherKey.identifier(email);
+
+        // Then
+        assertEquals(identifier, again);
+        assertNotEquals(identifier, other);
+        assertTrue(identifier, identifier.matches("[a-z2-7]+"));
+        assertFalse(identifier.contains(email));
+    }
 }
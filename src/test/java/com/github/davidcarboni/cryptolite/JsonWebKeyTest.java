@@ -0,0 +1,94 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.bouncycastle.jce.provider.BouncyCastleProvider;
+import org.junit.Test;
+
+import java.security.KeyPairGenerator;
+import java.security.NoSuchAlgorithmException;
+import java.security.PublicKey;
+import java.security.spec.ECGenParameterSpec;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertNotNull;
+import static org.junit.Assert.assertTrue;
+
+/**
+ * Test for {@link JsonWebKey}.
+ *
+ * @author David Carboni
+ */
+public class JsonWebKeyTest {
+
+    /**
+     * Checks that an RSA public key can be converted to a JWK and back again.
+     */
+    @Test
+    public void shouldRoundTripRsaKey() {
+
+        // Given
+        PublicKey key = Keys.newKeyPair().getPublic();
+
+        // When
+        String jwk = JsonWebKey.encodePublicKey(key);
+        PublicKey decoded = JsonWebKey.decodePublicKey(jwk);
+
+        // Then
+        assertTrue(jwk.contains("\"kty\":\"RSA\""));
+        assertTrue(jwk.contains("\"e\":\"AQAB\""));
+        assertArrayEquals(key.getEncoded(), decoded.getEncoded());
+    }
+
+    /**
+     * Checks that an EC public key can be converted to a JWK and back again.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test
+    public void shouldRoundTripEcKey() throws Exception {
+
+        // Given
+        KeyPairGenerator generator;
+        try {
+            generator = KeyPairGenerator.getInstance("EC");
+        } catch (NoSuchAlgorithmException e) {
+            SecurityProvider.addProvider();
+            generator = KeyPairGenerator.getInstance("EC");
+        }
+        generator.initialize(new ECGenParameterSpec("secp256r1"));
+        PublicKey key = generator.generateKeyPair().getPublic();
+
+        // When
+        String jwk = JsonWebKey.encodePublicKey(key);
+        PublicKey decoded = JsonWebKey.decodePublicKey(jwk);
+
+        // Then
+        assertTrue(jwk.contains("\"crv\":\"P-256\""));
+        assertArrayEquals(key.getEncoded(), decoded.getEncoded());
+    }
+
+    /**
+     * Checks that a key on secp256k1, which has the same field size as P-256, isn't mislabelled as P-256.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test
+    public void shouldRejectSecp256k1Key() throws Exception {
+
+        // Given
+        // Newer JDKs don't support secp256k1, so use Bouncy Castle:
+        KeyPairGenerator generator = KeyPairGenerator.getInstance("EC", new BouncyCastleProvider());
+        generator.initialize(new ECGenParameterSpec("secp256k1"));
+        PublicKey key = generator.generateKeyPair().getPublic();
+
+        // When
+        IllegalArgumentException error = null;
+        try {
+            JsonWebKey.encodePublicKey(key);
+        } catch (IllegalArgumentException e) {
+            error = e;
+        }
+
+        // Then
+        assertNotNull(error);
+    }
+}
@@ -4,11 +4,17 @@ import org.junit.BeforeClass;
 import org.junit.Test;
 
 import javax.crypto.SecretKey;
+import javax.crypto.spec.SecretKeySpec;
 import java.security.KeyPair;
 import java.security.PrivateKey;
 import java.security.PublicKey;
 import java.util.Arrays;
+import java.util.HashMap;
+import java.util.Map;
 
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertFalse;
 import static org.junit.Assert.assertTrue;
 import static org.junit.Assert.fail;
 
@@ -186,4 +192,202 @@ public class KeyWrapperTest {
         assertTrue(Arrays.equals(key.getEncoded(), recovered.getEncoded()));
     }
 
+    /**
+     * Test for {@link KeyWrapper#isWrapped(String)}.
+     */
+    @Test
+    public void testIsWrapped() {
+
+        // Given
+        KeyWrapper keyWrapper = new KeyWrapper("testIsWrapped", Generate.salt());
+        String wrappedSecretKey = keyWrapper.wrapSecretKey(Keys.newSecretKey());
+        String wrappedPrivateKey = keyWrapper.wrapPrivateKey(keyPair.getPrivate());
+        String random = ByteArray.toBase64(Generate.byteArray(13));
+        String notBase64 = "This is not base64!";
+
+        // When
+        boolean secretKeyResult = KeyWrapper.isWrapped(wrappedSecretKey);
+        boolean privateKeyResult = KeyWrapper.isWrapped(wrappedPrivateKey);
+        boolean randomResult = KeyWrapper.isWrapped(random);
+        boolean notBase64Result = KeyWrapper.isWrapped(notBase64);
+
+        // Then
+        assertTrue(secretKeyResult);
+        assertTrue(privateKeyResult);
+        assertFalse(randomResult);
+        assertFalse(notBase64Result);
+    }
+
+    /**
+     * Test for {@link KeyWrapper#aesKeyWrap(SecretKey, byte[])} and
+     * {@link KeyWrapper#aesKeyUnwrap(SecretKey, byte[])} using the RFC 3394 test vector
+     * for wrapping 128 bits of key data with a 128-bit KEK.
+     */
+    @Test
+    public void testAesKeyWrapRfc3394() {
+
+        // Given
+        SecretKey kek = new SecretKeySpec(ByteArray.fromHex("000102030405060708090a0b0c0d0e0f"), "AES");
+        byte[] key = ByteArray.fromHex("00112233445566778899aabbccddeeff");
+        String expected = "1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe5";
+
+        // When
+        byte[] wrapped = KeyWrapper.aesKeyWrap(kek, key);
+        byte[] unwrapped = KeyWrapper.aesKeyUnwrap(kek, wrapped);
+
+        // Then
+        assertEquals(expected, ByteArray.toHex(wrapped));
+        assertArrayEquals(key, unwrapped);
+    }
+
+    /**
+     * Test for {@link KeyWrapper#aesKeyWrap(SecretKey, byte[])} and
+     * {@link KeyWrapper#aesKeyUnwrap(SecretKey, byte[])} using the RFC 5649 test vectors
+     * for key data that is not a multiple of 8 bytes.
+     */
+    @Test
+    public void testAesKeyWrapRfc5649() {
+
+        // Given
+        SecretKey kek = new SecretKeySpec(ByteArray.fromHex("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8"), "AES");
+        byte[] key20 = ByteArray.fromHex("c37b7e6492584340bed12207808941155068f738");
+        byte[] key7 = ByteArray.fromHex("466f7250617369");
+
+        // When
+        byte[] wrapped20 = KeyWrapper.aesKeyWrap(kek, key20);
+        byte[] wrapped7 = KeyWrapper.aesKeyWrap(kek, key7);
+
+        // Then
+        assertEquals("138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a", ByteArray.toHex(wrapped20));
+        assertEquals("afbeb0f07dfbf5419200f2ccb50bb24f", ByteArray.toHex(wrapped7));
+        assertArrayEquals(key20, KeyWrapper.aesKeyUnwrap(kek, wrapped20));
+        assertArrayEquals(key7, KeyWrapper.aesKeyUnwrap(kek, wrapped7));
+    }
+
+    /**
+     * Test for {@link KeyWrapper#aesKeyUnwrap(SecretKey, byte[])} with an altered wrapped key.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testAesKeyUnwrapAltered() {
+
+        // Given
+        SecretKey kek = new SecretKeySpec(ByteArray.fromHex("000102030405060708090a0b0c0d0e0f"), "AES");
+        byte[] wrapped = ByteArray.fromHex("1fa68b0a8112b447aef34bd8fb5a7b829d3e862371d2cfe6");
+
+        // When
+        KeyWrapper.aesKeyUnwrap(kek, wrapped);
+
+        // Then
+        // We should get an IllegalArgumentException because the integrity check fails
+    }
+
+    /**
+     * Test for {@link KeyWrapper#rewrapSecretKeys(Map, KeyWrapper)}.
+     * <p>
+     * Checks that re-wrapped keys unwrap under the new wrapper, to the same keys, and not under the old one.
+     */
+    @Test
+    public void shouldRewrapSecretKeys() {
+
+        // Given
+        KeyWrapper oldWrapper = new KeyWrapper(Keys.newSecretKey());
+        KeyWrapper newWrapper = new KeyWrapper(Keys.newSecretKey());
+        Map<String, SecretKey> keys = new HashMap<>();
+        Map<String, String> wrapped = new HashMap<>();
+        for (String id : new String[]{"one", "two", "three"}) {
+            keys.put(id, Keys.newSecretKey());
+            wrapped.put(id, oldWrapper.wrapSecretKey(keys.get(id)));
+        }
+
+        // When
+        Map<String, String> rewrapped = oldWrapper.rewrapSecretKeys(wrapped, newWrapper);
+
+        // Then
+        assertEquals(keys.keySet(), rewrapped.keySet());
+        for (String id : keys.keySet()) {
+            assertArrayEquals(keys.get(id).getEncoded(), newWrapper.unwrapSecretKey(rewrapped.get(id)).getEncoded());
+            try {
+                oldWrapper.unwrapSecretKey(rewrapped.get(id));
+                fail("Key " + id + " should not unwrap under the old wrapper.");
+            } catch (RuntimeException e) {
+                // Expected
+            }
+        }
+    }
+
+    /**
+     * Verifies that fixed wrapped keys, produced independently (PBKDF2-HMAC-SHA256 and RFC 3394 key wrap,
+     * as used by {@link KeyWrapper#wrapSecretKey(SecretKey)}), unwrap to the known key with both wrap key sizes.
+     */
+    @Test
+    public void shouldImportWrappedSecretKey() {
+
+        // Given
+        String password = "correct horse battery staple";
+        String salt = "AAECAwQFBgcICQoLDA0ODw==";
+        String wrappedStrong = "uLyKacsMyD/5BzlnhB6NLN983EBZWtXbMy818kW+7YGCPNmHxhJ21A==";
+        String wrappedStandard = "RXka/WWOALTG2Xw434zFvmnJtew55ShG8YNXbBzgxy8Zi93Mjaqwdw==";
+        byte[] expected = ByteArray.fromHex("202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f");
+
+        // When
+        SecretKey strong = KeyWrapper.importWrappedSecretKey(password, salt, wrappedStrong);
+        SecretKey standard = KeyWrapper.importWrappedSecretKey(password, salt, wrappedStandard);
+
+        // Then
+        assertArrayEquals(expected, strong.getEncoded());
+        assertArrayEquals(expected, standard.getEncoded());
+    }
+
+    /**
+     * Verifies that importing with the wrong password fails.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotImportWithWrongPassword() {
+
+        // Given
+        String salt = "AAECAwQFBgcICQoLDA0ODw==";
+        String wrapped = "uLyKacsMyD/5BzlnhB6NLN983EBZWtXbMy818kW+7YGCPNmHxhJ21A==";
+
+        // When
+        KeyWrapper.importWrappedSecretKey("wrong password", salt, wrapped);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Verifies that a key wrapped with both a password and a recovery token can be unwrapped with either.
+     */
+    @Test
+    public void shouldWrapDual() {
+
+        // Given
+        SecretKey key = Keys.newSecretKey();
+        String password = "Mary had a little Caribou.";
+        String recoveryToken = Generate.token();
+
+        // When
+        String[] wrapped = KeyWrapper.wrapDual(key, password, recoveryToken);
+
+        // Then
+        assertArrayEquals(key.getEncoded(), KeyWrapper.unwrapDual(wrapped[0], password).getEncoded());
+        assertArrayEquals(key.getEncoded(), KeyWrapper.unwrapDual(wrapped[1], recoveryToken).getEncoded());
+    }
+
+    /**
+     * Verifies that the password-wrapped key can't be unwrapped with the recovery token.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotUnwrapDualWithOtherCredential() {
+
+        // Given
+        String recoveryToken = Generate.token();
+        String[] wrapped = KeyWrapper.wrapDual(Keys.newSecretKey(), "Mary had a little Caribou.", recoveryToken);
+
+        // When
+        KeyWrapper.unwrapDual(wrapped[0], recoveryToken);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
 }
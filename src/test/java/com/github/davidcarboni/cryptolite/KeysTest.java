@@ -1,14 +1,36 @@
 package com.github.davidcarboni.cryptolite;
 
+import org.apache.commons.codec.binary.Base64;
+import org.bouncycastle.asn1.pkcs.PrivateKeyInfo;
+import org.bouncycastle.asn1.sec.ECPrivateKey;
+import org.bouncycastle.jce.provider.BouncyCastleProvider;
 import org.junit.Test;
 
 import javax.crypto.SecretKey;
+import java.io.IOException;
+import java.math.BigInteger;
+import java.nio.charset.StandardCharsets;
 import java.security.KeyPair;
+import java.security.KeyPairGenerator;
+import java.security.KeyStore;
+import java.security.NoSuchAlgorithmException;
+import java.security.PrivateKey;
+import java.security.PublicKey;
+import java.security.cert.X509Certificate;
+import java.security.interfaces.ECPublicKey;
+import java.security.spec.ECGenParameterSpec;
+import java.util.ArrayList;
 import java.util.Arrays;
+import java.util.List;
+import java.util.Map;
 
+import static org.junit.Assert.assertArrayEquals;
 import static org.junit.Assert.assertEquals;
 import static org.junit.Assert.assertFalse;
+import static org.junit.Assert.assertNotEquals;
 import static org.junit.Assert.assertNotNull;
+import static org.junit.Assert.assertTrue;
+import static org.junit.Assert.fail;
 
 /**
  * Test for {@link Keys}.
@@ -91,5 +113,835 @@ public class KeysTest {
         assertEquals(keyHex, ByteArray.toHex(key.getEncoded()));
     }
 
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#newSecretKeyBase64()}.
+     * <p>
+     * Checks that the decoded key is the expected length.
+     */
+    @Test
+    public void testNewSecretKeyBase64() {
+
+        // When
+        String key = Keys.newSecretKeyBase64();
+
+        // Then
+        assertEquals(Keys.SYMMETRIC_KEY_SIZE / 8, ByteArray.fromBase64(key).length);
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#rederiveSecretKey(String, String, String, int, int)}.
+     * <p>
+     * Checks the old key matches the legacy derivation and the new key matches the new parameters.
+     */
+    @Test
+    public void testRederiveSecretKey() {
+
+        // Given
+        String password = "Mary had a little Café";
+        String oldSalt = "EvwdaavC8dRvR4RPaI9Gkg==";
+        String newSalt = Generate.salt();
+        int newIterations = 10000;
+
+        // When
+        SecretKey[] keys = Keys.rederiveSecretKey(password, oldSalt, newSalt, Keys.SYMMETRIC_PASSWORD_ITERATIONS, newIterations);
+
+        // Then
+        assertArrayEquals(Keys.generateSecretKey(password, oldSalt).getEncoded(), keys[0].getEncoded());
+        assertArrayEquals(Keys.generateSecretKey(password, newSalt, newIterations).getEncoded(), keys[1].getEncoded());
+        assertFalse(Arrays.equals(keys[0].getEncoded(), keys[1].getEncoded()));
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#newDeterministicSecretKey(String)}.
+     * <p>
+     * Checks that regenerating the key with the returned salt gives the same key.
+     */
+    @Test
+    public void testNewDeterministicSecretKey() {
+
+        // Given
+        String password = "Mary had a little Café";
+
+        // When
+        DerivedSecretKey derived = Keys.newDeterministicSecretKey(password);
+
+        // Then
+        SecretKey regenerated = Keys.generateSecretKey(password, derived.getSalt());
+        assertArrayEquals(regenerated.getEncoded(), derived.getKey().getEncoded());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#generateSecretKeyFromPin(String, String, KdfParameters, AttemptRecorder)}.
+     * <p>
+     * Checks that the same PIN gives the same key and that attempts are refused once the recorder denies them.
+     */
+    @Test
+    public void testGenerateSecretKeyFromPin() {
+
+        // Given
+        String pin = "1234";
+        String salt = Generate.salt();
+        KdfParameters params = new KdfParameters(64, 1, 1);
+        AttemptRecorder recorder = new AttemptRecorder() {
+            private int attempts;
+
+            @Override
+            public boolean recordAttempt() {
+                return ++attempts <= 3;
+            }
+        };
+
+        // When
+        SecretKey key1 = Keys.generateSecretKeyFromPin(pin, salt, params, recorder);
+        SecretKey key2 = Keys.generateSecretKeyFromPin(pin, salt, params, recorder);
+        SecretKey key3 = Keys.generateSecretKeyFromPin("4321", salt, params, recorder);
+
+        // Then
+        assertEquals(Keys.SYMMETRIC_KEY_SIZE / 8, key1.getEncoded().length);
+        assertArrayEquals(key1.getEncoded(), key2.getEncoded());
+        assertFalse(Arrays.equals(key1.getEncoded(), key3.getEncoded()));
+        try {
+            Keys.generateSecretKeyFromPin(pin, salt, params, recorder);
+            fail("The fourth attempt should have been refused.");
+        } catch (AttemptsExceededException e) {
+            // Expected
+        }
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#generateSecretKeyFromPin(String, String, KdfParameters, AttemptRecorder)}.
+     * <p>
+     * Checks that a null PIN or salt is rejected without recording an attempt.
+     */
+    @Test
+    public void testGenerateSecretKeyFromPinNull() {
+
+        // Given
+        KdfParameters params = new KdfParameters(64, 1, 1);
+        final int[] attempts = new int[1];
+        AttemptRecorder recorder = new AttemptRecorder() {
+            @Override
+            public boolean recordAttempt() {
+                attempts[0]++;
+                return true;
+            }
+        };
+
+        // When
+        IllegalArgumentException nullPin = null;
+        IllegalArgumentException nullSalt = null;
+        try {
+            Keys.generateSecretKeyFromPin(null, Generate.salt(), params, recorder);
+        } catch (IllegalArgumentException e) {
+            nullPin = e;
+        }
+        try {
+            Keys.generateSecretKeyFromPin("1234", null, params, recorder);
+        } catch (IllegalArgumentException e) {
+            nullSalt = e;
+        }
+
+        // Then
+        assertNotNull(nullPin);
+        assertNotNull(nullSalt);
+        assertEquals(0, attempts[0]);
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#parsePrivateKeyPem(String)}.
+     * <p>
+     * Checks that a PKCS#8 RSA key can be parsed and used to sign.
+     */
+    @Test
+    public void testParsePrivateKeyPemRsaPkcs8() {
+
+        // Given
+        KeyPair keyPair = Keys.newKeyPair();
+        String pem = pem("PRIVATE KEY", keyPair.getPrivate().getEncoded());
+
+        // When
+        PrivateKey privateKey = Keys.parsePrivateKeyPem(pem);
+
+        // Then
+        assertEquals("RSA", privateKey.getAlgorithm());
+        assertCanSign(privateKey, keyPair.getPublic());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#parsePrivateKeyPem(String)}.
+     * <p>
+     * Checks that a PKCS#1 RSA key can be parsed and used to sign.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void testParsePrivateKeyPemRsaPkcs1() throws IOException {
+
+        // Given
+        KeyPair keyPair = Keys.newKeyPair();
+        PrivateKeyInfo info = PrivateKeyInfo.getInstance(keyPair.getPrivate().getEncoded());
+        String pem = pem("RSA PRIVATE KEY", info.parsePrivateKey().toASN1Primitive().getEncoded());
+
+        // When
+        PrivateKey privateKey = Keys.parsePrivateKeyPem(pem);
+
+        // Then
+        assertEquals("RSA", privateKey.getAlgorithm());
+        assertCanSign(privateKey, keyPair.getPublic());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#parsePrivateKeyPem(String)}.
+     * <p>
+     * Checks that a SEC1 EC key can be parsed and used to sign.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test
+    public void testParsePrivateKeyPemEc() throws Exception {
+
+        // Given
+        KeyPairGenerator generator;
+        try {
+            generator = KeyPairGenerator.getInstance("EC");
+        } catch (NoSuchAlgorithmException e) {
+            SecurityProvider.addProvider();
+            generator = KeyPairGenerator.getInstance("EC");
+        }
+        generator.initialize(new ECGenParameterSpec("secp256r1"));
+        KeyPair keyPair = generator.generateKeyPair();
+        PrivateKeyInfo info = PrivateKeyInfo.getInstance(keyPair.getPrivate().getEncoded());
+        BigInteger value = ECPrivateKey.getInstance(info.parsePrivateKey()).getKey();
+        ECPrivateKey sec1 = new ECPrivateKey(256, value, info.getPrivateKeyAlgorithm().getParameters());
+        String pem = pem("EC PRIVATE KEY", sec1.getEncoded());
+
+        // When
+        PrivateKey privateKey = Keys.parsePrivateKeyPem(pem);
+
+        // Then
+        assertCanSign(privateKey, keyPair.getPublic());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#parsePrivateKeyPem(String)}.
+     * <p>
+     * Checks that a PKCS#8 Ed25519 key can be parsed and used to sign.
+     */
+    @Test
+    public void testParsePrivateKeyPemEd25519() {
+
+        // Given
+        KeyPair keyPair = Keys.newSigningKeyPair();
+        String pem = pem("PRIVATE KEY", keyPair.getPrivate().getEncoded());
+
+        // When
+        PrivateKey privateKey = Keys.parsePrivateKeyPem(pem);
+
+        // Then
+        assertCanSign(privateKey, keyPair.getPublic());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#parsePrivateKeyPem(String)}.
+     * <p>
+     * Checks that input without PEM BEGIN/END lines is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testParsePrivateKeyPemInvalid() {
+
+        // When
+        Keys.parsePrivateKeyPem("not a key");
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    private static String pem(String type, byte[] der) {
+        return "-----BEGIN " + type + "-----\n"
+                + new String(Base64.encodeBase64Chunked(der), StandardCharsets.US_ASCII)
+                + "-----END " + type + "-----\n";
+    }
+
+    private static void assertCanSign(PrivateKey privateKey, PublicKey publicKey) {
+        String content = "Sign me";
+        String signature = DigitalSignature.forKey(privateKey).sign(content, privateKey);
+        assertTrue(DigitalSignature.forKey(publicKey).verify(content, publicKey, signature));
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#publicKeyEquals(PublicKey, PublicKey)}.
+     * <p>
+     * Checks that two decodes of the same encoded key are equal and different keys are not.
+     */
+    @Test
+    public void testPublicKeyEquals() {
+
+        // Given
+        String encoded = KeyWrapper.encodePublicKey(Keys.newKeyPair().getPublic());
+        PublicKey other = Keys.newKeyPair().getPublic();
+        PublicKey signingKey = Keys.newSigningKeyPair().getPublic();
+
+        // When
+        PublicKey decoded1 = KeyWrapper.decodePublicKey(encoded);
+        PublicKey decoded2 = KeyWrapper.decodePublicKey(encoded);
+
+        // Then
+        assertTrue(Keys.publicKeyEquals(decoded1, decoded2));
+        assertFalse(Keys.publicKeyEquals(decoded1, other));
+        assertFalse(Keys.publicKeyEquals(decoded1, signingKey));
+        assertTrue(Keys.publicKeyEquals(signingKey, signingKey));
+        assertFalse(Keys.publicKeyEquals(signingKey, Keys.newSigningKeyPair().getPublic()));
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#publicKeyToAuthorizedKey(PublicKey, String)}.
+     * <p>
+     * Checks the output against a key generated by <code>ssh-keygen</code>.
+     */
+    @Test
+    public void testPublicKeyToAuthorizedKeyFixture() {
+
+        // Given
+        // Generated with: ssh-keygen -t rsa -b 1024 -C test@example, then ssh-keygen -e -m PKCS8
+        String pem = "-----BEGIN PUBLIC KEY-----\n" +
+                "MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDUE7b9YLpAndBXhs12EluKdL5X\n" +
+                "7KcfHX9vpMUdQmU3mv6+e0tWqQMbOEAhkoOZlHDRUIiROymmws7pXAvw3fUNppKt\n" +
+                "iNPJtUmxRMp7ObuobKWETvHL89AeP7Lvxo6wqqtqpz/UJ91HchuOp63K937Naeep\n" +
+                "z3MDE0jEreQt3uHp5QIDAQAB\n" +
+                "-----END PUBLIC KEY-----";
+        String expected = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDUE7b9YLpAndBXhs12EluKdL5X7KcfHX9vpMUdQmU3mv6+e0tWqQMbOE" +
+                "AhkoOZlHDRUIiROymmws7pXAvw3fUNppKtiNPJtUmxRMp7ObuobKWETvHL89AeP7Lvxo6wqqtqpz/UJ91HchuOp63K937Naeepz3MD" +
+                "E0jEreQt3uHp5Q== test@example";
+
+        // When
+        String authorizedKey = Keys.publicKeyToAuthorizedKey(KeyWrapper.decodePublicKey(pem), "test@example");
+
+        // Then
+        assertEquals(expected, authorizedKey);
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#publicKeyToAuthorizedKey(PublicKey, String)}
+     * and {@link com.github.davidcarboni.cryptolite.Keys#parseAuthorizedKey(String)}.
+     * <p>
+     * Checks that RSA, EC and Ed25519 keys can be encoded and parsed back.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test
+    public void testAuthorizedKeyRoundTrip() throws Exception {
+
+        // Given
+        KeyPairGenerator generator;
+        try {
+            generator = KeyPairGenerator.getInstance("EC");
+        } catch (NoSuchAlgorithmException e) {
+            SecurityProvider.addProvider();
+            generator = KeyPairGenerator.getInstance("EC");
+        }
+        generator.initialize(new ECGenParameterSpec("secp384r1"));
+        PublicKey[] keys = {Keys.newKeyPair().getPublic(), generator.generateKeyPair().getPublic(),
+                Keys.newSigningKeyPair().getPublic()};
+        String[] prefixes = {"ssh-rsa ", "ecdsa-sha2-nistp384 ", "ssh-ed25519 "};
+
+        for (int i = 0; i < keys.length; i++) {
+
+            // When
+            String authorizedKey = Keys.publicKeyToAuthorizedKey(keys[i], "user@host");
+            PublicKey parsed = Keys.parseAuthorizedKey(authorizedKey);
+
+            // Then
+            assertTrue(authorizedKey.startsWith(prefixes[i]));
+            assertTrue(authorizedKey.endsWith(" user@host"));
+            assertTrue(Keys.publicKeyEquals(keys[i], parsed));
+        }
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#publicKeyToAuthorizedKey(PublicKey, String)}.
+     * <p>
+     * Checks that a key on secp256k1, which has the same field size as nistp256, is rejected rather than mislabelled.
+     *
+     * @throws Exception {@link Exception}
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void testAuthorizedKeyNonNistCurve() throws Exception {
+
+        // Given
+        // Newer JDKs don't support secp256k1, so use Bouncy Castle:
+        KeyPairGenerator generator = KeyPairGenerator.getInstance("EC", new BouncyCastleProvider());
+        generator.initialize(new ECGenParameterSpec("secp256k1"));
+        PublicKey key = generator.generateKeyPair().getPublic();
+
+        // When
+        Keys.publicKeyToAuthorizedKey(key, "user@host");
+
+        // Then
+        // We expect an exception.
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#splitKeys(byte[])}.
+     * <p>
+     * Checks that the encryption and MAC keys are distinct, the right size and deterministic.
+     */
+    @Test
+    public void testSplitKeys() {
+
+        // Given
+        byte[] master = Generate.byteArray(32);
+
+        // When
+        SecretKey[] keys1 = Keys.splitKeys(master);
+        SecretKey[] keys2 = Keys.splitKeys(master);
+
+        // Then
+        assertEquals(Keys.SPLIT_KEY_BYTES, keys1[0].getEncoded().length);
+        assertEquals(Keys.SPLIT_KEY_BYTES, keys1[1].getEncoded().length);
+        assertFalse(Arrays.equals(keys1[0].getEncoded(), keys1[1].getEncoded()));
+        assertArrayEquals(keys1[0].getEncoded(), keys2[0].getEncoded());
+        assertArrayEquals(keys1[1].getEncoded(), keys2[1].getEncoded());
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#deriveChild(byte[], String)}.
+     * <p>
+     * Checks that different paths give different keys, the same path gives the same key and
+     * a child can be derived from its parent's key.
+     */
+    @Test
+    public void testDeriveChild() {
+
+        // Given
+        byte[] master = Generate.byteArray(32);
+
+        // When
+        byte[] device3 = Keys.deriveChild(master, "user/42/device/3");
+        byte[] device3Again = Keys.deriveChild(master, "user/42/device/3");
+        byte[] device4 = Keys.deriveChild(master, "user/42/device/4");
+        byte[] user42 = Keys.deriveChild(master, "user/42");
+
+        // Then
+        assertEquals(Keys.SPLIT_KEY_BYTES, device3.length);
+        assertArrayEquals(device3, device3Again);
+        assertFalse(Arrays.equals(device3, device4));
+        assertFalse(Arrays.equals(device3, user42));
+        assertArrayEquals(device3, Keys.deriveChild(user42, "device/3"));
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#keyId(PublicKey)}.
+     * <p>
+     * Checks that the identifier is stable for a key and different for different keys.
+     */
+    @Test
+    public void testKeyId() {
 
+        // Given
+        PublicKey key = Keys.newKeyPair().getPublic();
+        PublicKey other = Keys.newSigningKeyPair().getPublic();
+
+        // When
+        String id = Keys.keyId(key);
+
+        // Then
+        assertEquals(id, Keys.keyId(KeyWrapper.decodePublicKey(ByteArray.toBase64(key.getEncoded()))));
+        assertNotEquals(id, Keys.keyId(other));
+        assertTrue(id.matches("[A-Z2-7]{16}"));
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#newEcKeyPair(String)}.
+     * <p>
+     * Checks that a key pair is generated on each supported curve and can sign.
+     */
+    @Test
+    public void shouldGenerateEcKeyPairOnNamedCurve() {
+
+        // Given
+        String[] curves = {"P-256", "P-384", "P-521"};
+        int[] fieldSizes = {256, 384, 521};
+
+        for (int i = 0; i < curves.length; i++) {
+
+            // When
+            KeyPair keyPair = Keys.newEcKeyPair(curves[i]);
+
+            // Then
+            ECPublicKey publicKey = (ECPublicKey) keyPair.getPublic();
+            assertEquals(fieldSizes[i], publicKey.getParams().getCurve().getField().getFieldSize());
+            assertCanSign(keyPair.getPrivate(), publicKey);
+        }
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#newEcKeyPair(String)}.
+     * <p>
+     * Checks that an unknown curve name is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectUnknownCurveName() {
+
+        // When
+        Keys.newEcKeyPair("P-192");
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Test method for {@link com.github.davidcarboni.cryptolite.Keys#withKeyBytes(SecretKey, KeyBytesConsumer)}.
+     * <p>
+     * Checks that the consumer sees the key bytes, that they're wiped afterwards and that the key is still usable.
+     */
+    @Test
+    public void shouldLendKeyBytes() {
+
+        // Given
+        Keys.useStandardKeys();
+        SecretKey key = Keys.newSecretKey();
+        final byte[][] lent = new byte[1][];
+        final byte[] seen = new byte[key.getEncoded().length];
+
+        // When
+        Keys.withKeyBytes(key, new KeyBytesConsumer() {
+            @Override
+            public void accept(byte[] keyBytes) {
+                lent[0] = keyBytes;
+                System.arraycopy(keyBytes, 0, seen, 0, keyBytes.length);
+            }
+        });
+
+        // Then
+        assertArrayEquals(key.getEncoded(), seen);
+        assertArrayEquals(new byte[seen.length], lent[0]);
+        assertArrayEquals(key.getEncoded(), Keys.copyKeyBytes(key));
+        byte[] encrypted = new AuthenticatedCrypto().encrypt(seen, Keys.secretKeyFromBytes(Keys.copyKeyBytes(key)));
+        assertArrayEquals(seen, new AuthenticatedCrypto().decrypt(encrypted, key));
+    }
+
+    /**
+     * Verifies that a key bound to a machine secret changes if the machine secret changes.
+     */
+    @Test
+    public void shouldBindKeyToMachineSecret() {
+
+        // Given
+        String password = "Mary had a little Caribou.";
+        String salt = Generate.salt();
+        byte[] machineSecret = Generate.byteArray(32);
+        byte[] otherMachineSecret = Generate.byteArray(32);
+
+        // When
+        SecretKey key = Keys.generateSecretKeyBound(password, salt, machineSecret);
+        SecretKey again = Keys.generateSecretKeyBound(password, salt, machineSecret);
+        SecretKey other = Keys.generateSecretKeyBound(password, salt, otherMachineSecret);
+
+        // Then
+        assertArrayEquals(key.getEncoded(), again.getEncoded());
+        assertFalse(Arrays.equals(key.getEncoded(), other.getEncoded()));
+        assertFalse(Arrays.equals(key.getEncoded(), Keys.generateSecretKey(password, salt).getEncoded()));
+    }
+
+    /**
+     * Verifies that a newly generated key pair passes the pairwise consistency self-test.
+     * <p>
+     * The self-test signs {@link Keys#SELF_TEST_MESSAGE} and verifies the signature.
+     */
+    @Test
+    public void shouldGenerateVerifiedKeyPair() {
+
+        // When
+        KeyPair keyPair = Keys.newKeyPairVerified();
+
+        // Then
+        assertNotNull(keyPair);
+        assertTrue(Keys.selfTest(keyPair));
+    }
+
+    /**
+     * Verifies that the self-test fails for a mismatched key pair.
+     */
+    @Test
+    public void shouldFailSelfTestForMismatchedKeys() {
+
+        // Given
+        KeyPair mismatched = new KeyPair(Keys.newKeyPair().getPublic(), Keys.newKeyPair().getPrivate());
+
+        // When
+        boolean result = Keys.selfTest(mismatched);
+
+        // Then
+        assertFalse(result);
+    }
+
+    /**
+     * Verifies that duplicated salts are grouped by value, with the positions they appear at.
+     */
+    @Test
+    public void shouldFindDuplicateSalts() {
+
+        // Given
+        String unique = Generate.salt();
+        String shared = Generate.salt();
+        String alsoShared = Generate.salt();
+        List<String> salts = Arrays.asList(shared, unique, alsoShared, shared, alsoShared, shared);
+
+        // When
+        Map<String, List<Integer>> duplicates = Keys.findDuplicateSalts(salts);
+
+        // Then
+        assertEquals(2, duplicates.size());
+        assertEquals(Arrays.asList(0, 3, 5), duplicates.get(shared));
+        assertEquals(Arrays.asList(2, 4), duplicates.get(alsoShared));
+        assertFalse(duplicates.containsKey(unique));
+    }
+
+    /**
+     * Verifies that the supported key derivation functions include PBKDF2 and Argon2id.
+     */
+    @Test
+    public void shouldListSupportedKdfs() {
+
+        // When
+        List<AlgorithmInfo> kdfs = Keys.supportedKdfs();
+
+        // Then
+        List<String> names = new ArrayList<>();
+        for (AlgorithmInfo kdf : kdfs) {
+            names.add(kdf.getName());
+        }
+        assertTrue(names.contains("PBKDF2WithHmacSHA256"));
+        assertFalse(names.contains("PBKDF2WithHmacSHA1"));
+        assertTrue(names.contains("Argon2id"));
+        assertEquals("1024", kdfs.get(0).getDefaults().get("iterations"));
+        AlgorithmInfo password = kdfs.get(2);
+        assertEquals(Password.class.getSimpleName(), password.getUsedBy());
+        assertEquals(Keys.SYMMETRIC_PASSWORD_ALGORITHM, password.getName());
+        assertEquals(Keys.SYMMETRIC_KEY_SIZE, password.getKeySize());
+    }
+
+    /**
+     * Checks that a generated key can be exported as a PKCS#12 bundle and read back.
+     */
+    @Test
+    public void shouldRoundTripPkcs12() {
+
+        // Given
+        KeyPair keyPair = Keys.newEcKeyPair("P-256");
+        String password = "Pkcs12 password";
+
+        // When
+        byte[] bundle = Keys.toPkcs12(keyPair.getPrivate(),
+                Keys.newSelfSignedCertificate(keyPair, "cryptolite test", 1), password);
+        KeyStore.PrivateKeyEntry entry = Keys.fromPkcs12(bundle, password);
+
+        // Then
+        assertArrayEquals(keyPair.getPrivate().getEncoded(), entry.getPrivateKey().getEncoded());
+        assertArrayEquals(keyPair.getPublic().getEncoded(), entry.getCertificate().getPublicKey().getEncoded());
+    }
+
+    /**
+     * Checks that a PKCS#12 bundle can't be read with the wrong password.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotReadPkcs12WithWrongPassword() {
+
+        // Given
+        KeyPair keyPair = Keys.newEcKeyPair("P-256");
+        byte[] bundle = Keys.toPkcs12(keyPair.getPrivate(),
+                Keys.newSelfSignedCertificate(keyPair, "cryptolite test", 1), "right");
+
+        // When
+        Keys.fromPkcs12(bundle, "wrong");
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Checks that a null password is rejected when writing or reading a PKCS#12 bundle.
+     */
+    @Test
+    public void shouldRejectNullPkcs12Password() {
+
+        // Given
+        KeyPair keyPair = Keys.newEcKeyPair("P-256");
+        X509Certificate certificate = Keys.newSelfSignedCertificate(keyPair, "cryptolite test", 1);
+        byte[] bundle = Keys.toPkcs12(keyPair.getPrivate(), certificate, "right");
+
+        // When
+        IllegalArgumentException writing = null;
+        IllegalArgumentException reading = null;
+        try {
+            Keys.toPkcs12(keyPair.getPrivate(), certificate, null);
+        } catch (IllegalArgumentException e) {
+            writing = e;
+        }
+        try {
+            Keys.fromPkcs12(bundle, null);
+        } catch (IllegalArgumentException e) {
+            reading = e;
+        }
+
+        // Then
+        assertNotNull(writing);
+        assertNotNull(reading);
+    }
+
+    /**
+     * Checks that a key generated for each supported cipher has the length that cipher requires.
+     */
+    @Test
+    public void shouldGenerateKeyForEachCipher() {
+
+        for (AlgorithmInfo cipher : Crypto.supportedCiphers()) {
+
+            // Given
+            String cipherName = cipher.getName();
+
+            // When
+            SecretKey key = Keys.newSecretKeyFor(cipherName);
+            String base64 = Keys.newSecretKeyBase64(cipherName);
+
+            // Then
+            assertEquals(cipher.getKeySize() / 8, key.getEncoded().length);
+            assertEquals(cipher.getKeySize() / 8, ByteArray.fromBase64(base64).length);
+        }
+    }
+
+    /**
+     * Checks that requesting a key for an unsupported cipher is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectKeyForUnsupportedCipher() {
+
+        // Given
+        String cipherName = "ChaCha20-Poly1305";
+
+        // When
+        Keys.newSecretKeyFor(cipherName);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+
+    /**
+     * Checks that a weak key derivation configuration is rejected, with every problem reported.
+     */
+    @Test
+    public void shouldRejectWeakKdfConfiguration() {
+
+        // Given
+        String message = null;
+
+        // When
+        try {
+            Keys.checkKdfPolicy(1000, 8, "PBKDF2WithHmacSHA1");
+        } catch (IllegalArgumentException e) {
+            message = e.getMessage();
+        }
+
+        // Then
+        assertNotNull(message);
+        assertTrue(message, message.contains("SHA1"));
+        assertTrue(message, message.contains("1000 iterations"));
+        assertTrue(message, message.contains("8-byte salt"));
+    }
+
+    /**
+     * Checks that a strong key derivation configuration passes, and that a custom policy is applied.
+     */
+    @Test
+    public void shouldAcceptStrongKdfConfiguration() {
+
+        // Given
+        KdfPolicy relaxed = new KdfPolicy(1000, 16, KdfPolicy.DEFAULT_DISALLOWED_DIGESTS);
+
+        // When
+        Keys.checkKdfPolicy(210000, Generate.SALT_BYTES, Keys.SYMMETRIC_PASSWORD_ALGORITHM);
+        relaxed.check(Keys.SYMMETRIC_PASSWORD_ITERATIONS, Generate.SALT_BYTES, Keys.SYMMETRIC_PASSWORD_ALGORITHM);
+
+        // Then
+        // No exception should be thrown
+    }
+
+    /**
+     * Checks that the library's own default iteration count deliberately fails the default policy, which
+     * requires a stronger configuration, while Argon2id passes.
+     */
+    @Test
+    public void shouldRejectDefaultIterationsUnderDefaultPolicy() {
+
+        // Given
+        int iterations = Keys.SYMMETRIC_PASSWORD_ITERATIONS;
+
+        // When
+        IllegalArgumentException rejected = null;
+        try {
+            Keys.checkKdfPolicy(iterations, Generate.SALT_BYTES, Keys.SYMMETRIC_PASSWORD_ALGORITHM);
+        } catch (IllegalArgumentException e) {
+            rejected = e;
+        }
+        Keys.checkKdfPolicy(new KdfParameters().getIterations(), Generate.SALT_BYTES, Keys.ARGON2ID);
+
+        // Then
+        assertNotNull(rejected);
+        assertTrue(rejected.getMessage(), rejected.getMessage().contains(iterations + " iterations"));
+    }
+
+    /**
+     * Checks that a policy can't be created with negative minimums or a null list of digests.
+     */
+    @Test
+    public void shouldRejectInvalidKdfPolicy() {
+
+        // Given
+        List<String> digests = KdfPolicy.DEFAULT_DISALLOWED_DIGESTS;
+        int[][] minimums = {{-1, 16}, {1000, -1}};
+
+        // When
+        int rejected = 0;
+        for (int[] minimum : minimums) {
+            try {
+                new KdfPolicy(minimum[0], minimum[1], digests);
+            } catch (IllegalArgumentException e) {
+                rejected++;
+            }
+        }
+        try {
+            new KdfPolicy(1000, 16, null);
+        } catch (IllegalArgumentException e) {
+            rejected++;
+        }
+        try {
+            new KdfPolicy(1000, 16, Arrays.asList("MD5", null));
+        } catch (IllegalArgumentException e) {
+            rejected++;
+        }
+
+        // Then
+        assertEquals(4, rejected);
+        assertEquals(0, new KdfPolicy(0, 0, new ArrayList<String>()).getMinIterations());
+    }
+
+    /**
+     * Checks that tenant keys are reproducible for the same tenant and different across tenants.
+     */
+    @Test
+    public void shouldDeriveIndependentTenantKeys() {
+
+        // Given
+        byte[] root = Generate.byteArray(Keys.SPLIT_KEY_BYTES);
+
+        // When
+        SecretKey tenantA = Keys.tenantKey(root, "tenant-a");
+        SecretKey tenantB = Keys.tenantKey(root, "tenant-b");
+        SecretKey tenantAAgain = Keys.tenantKey(root, "tenant-a");
+
+        // Then
+        assertArrayEquals(tenantA.getEncoded(), tenantAAgain.getEncoded());
+        assertFalse(Arrays.equals(tenantA.getEncoded(), tenantB.getEncoded()));
+        assertEquals(Keys.SYMMETRIC_KEY_SIZE / 8, tenantA.getEncoded().length);
+    }
 }
@@ -0,0 +1,68 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Before;
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertNotEquals;
+import static org.junit.Assert.assertNull;
+import static org.junit.Assert.assertTrue;
+
+/**
+ * Test for {@link LicenseKey}.
+ *
+ * @author David Carboni
+ */
+public class LicenseKeyTest {
+
+    SecretKey secret;
+
+    @Before
+    public void setup() {
+        secret = Keys.newSecretKey();
+    }
+
+    /**
+     * Checks that the same payload always produces the same key, which validates to the payload.
+     */
+    @Test
+    public void shouldGenerateDeterministicKey() {
+
+        // Given
+        long payload = 20241231L;
+
+        // When
+        String key = LicenseKey.generate(secret, payload);
+        String again = LicenseKey.generate(secret, payload);
+        String other = LicenseKey.generate(secret, payload + 1);
+
+        // Then
+        assertTrue(key.matches("[A-Z2-7]{6}(-[A-Z2-7]{6}){3}"));
+        assertEquals(key, again);
+        assertNotEquals(key, other);
+        assertEquals(Long.valueOf(payload), LicenseKey.validate(secret, key));
+        assertEquals(Long.valueOf(payload), LicenseKey.validate(secret, key.toLowerCase().replace("-", " ")));
+    }
+
+    /**
+     * Checks that altered keys, and keys generated with a different secret, don't validate.
+     */
+    @Test
+    public void shouldDetectTamperedKey() {
+
+        // Given
+        String key = LicenseKey.generate(secret, 42);
+        char replacement = key.charAt(0) == 'A' ? 'B' : 'A';
+        String tampered = replacement + key.substring(1);
+
+        // When
+        Long tamperedPayload = LicenseKey.validate(secret, tampered);
+        Long otherSecretPayload = LicenseKey.validate(Keys.newSecretKey(), key);
+
+        // Then
+        assertNull(tamperedPayload);
+        assertNull(otherSecretPayload);
+    }
+}
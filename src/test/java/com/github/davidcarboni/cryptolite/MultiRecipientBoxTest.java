@@ -0,0 +1,62 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import java.util.Arrays;
+
+import static org.junit.Assert.assertArrayEquals;
+
+/**
+ * Test for {@link MultiRecipientBox}.
+ *
+ * @author David Carboni
+ */
+public class MultiRecipientBoxTest {
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    /**
+     * Checks that each of several recipients can open the box independently.
+     */
+    @Test
+    public void shouldOpenForEachRecipient() {
+
+        // Given
+        byte[][] alice = SealedBox.newKeyPair();
+        byte[][] bob = SealedBox.newKeyPair();
+        byte[][] carol = SealedBox.newKeyPair();
+        byte[] document = ByteArray.fromString("Shared with three people.");
+
+        // When
+        byte[] box = MultiRecipientBox.seal(Arrays.asList(alice[0], bob[0], carol[0]), document);
+
+        // Then
+        assertArrayEquals(document, MultiRecipientBox.open(alice[0], alice[1], box));
+        assertArrayEquals(document, MultiRecipientBox.open(bob[0], bob[1], box));
+        assertArrayEquals(document, MultiRecipientBox.open(carol[0], carol[1], box));
+    }
+
+    /**
+     * Checks that someone who isn't a recipient can't open the box.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldNotOpenForNonRecipient() {
+
+        // Given
+        byte[][] alice = SealedBox.newKeyPair();
+        byte[][] bob = SealedBox.newKeyPair();
+        byte[][] eve = SealedBox.newKeyPair();
+        byte[] box = MultiRecipientBox.seal(Arrays.asList(alice[0], bob[0]), ByteArray.fromString("Not for Eve."));
+
+        // When
+        MultiRecipientBox.open(eve[0], eve[1], box);
+
+        // Then
+        // We should get an AuthenticationException
+    }
+}
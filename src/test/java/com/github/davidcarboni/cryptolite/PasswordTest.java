@@ -3,8 +3,17 @@ package com.github.davidcarboni.cryptolite;
 import org.junit.BeforeClass;
 import org.junit.Test;
 
+import javax.crypto.SecretKey;
+import java.io.ByteArrayInputStream;
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.io.PrintStream;
+import java.io.StringReader;
+import java.nio.charset.StandardCharsets;
 import java.security.NoSuchAlgorithmException;
 import java.security.SecureRandom;
+import java.util.Arrays;
+import java.util.concurrent.TimeUnit;
 
 import static org.junit.Assert.*;
 
@@ -221,4 +230,528 @@ public class PasswordTest {
         assertFalse(result);
     }
 
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#parse(java.lang.String)}
+     * followed by {@link PasswordHash#toString()} reproduces the original hash.
+     */
+    @Test
+    public void shouldParseAndReserialise() {
+
+        // Given
+        String hash = Password.hash("testParse");
+
+        // When
+        PasswordHash passwordHash = Password.parse(hash);
+
+        // Then
+        assertEquals(Generate.SALT_BYTES, ByteArray.fromBase64(passwordHash.getSalt()).length);
+        assertEquals(Keys.SYMMETRIC_PASSWORD_ITERATIONS, passwordHash.getIterations());
+        assertEquals(hash, passwordHash.toString());
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#calibrateIterations(long, TimeUnit)}
+     * returns an iteration count that takes roughly the target time.
+     */
+    @Test
+    public void shouldCalibrateIterations() {
+
+        // Given
+        long target = 200;
+
+        // When
+        int iterations = Password.calibrateIterations(target, TimeUnit.MILLISECONDS);
+
+        // Then
+        assertTrue(iterations > 0);
+        long start = System.nanoTime();
+        Keys.generateSecretKey("testCalibrate", Generate.salt(), iterations);
+        long elapsed = TimeUnit.NANOSECONDS.toMillis(System.nanoTime() - start);
+        // Timing is noisy, so allow generous slack:
+        assertTrue("Took " + elapsed + "ms", elapsed >= target / 4);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verifyAndUpgrade(String, String)}
+     * provides an Argon2id hash when verifying a legacy PBKDF2 hash, and that the new hash verifies.
+     */
+    @Test
+    public void shouldUpgradeLegacyHash() {
+
+        // Given
+        String password = "testUpgrade";
+        String legacyHash = Password.hash(password);
+
+        // When
+        PasswordVerification verification = Password.verifyAndUpgrade(password, legacyHash);
+
+        // Then
+        assertTrue(verification.isValid());
+        assertTrue(verification.getUpgradedHash().startsWith(Password.ARGON2_PREFIX));
+        assertTrue(Password.verify(password, verification.getUpgradedHash()));
+        assertFalse(Password.verify("wrong", verification.getUpgradedHash()));
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verifyAndUpgrade(String, String)}
+     * doesn't upgrade a hash that is already Argon2id, or one that doesn't match.
+     */
+    @Test
+    public void shouldNotUpgradeCurrentOrInvalidHash() {
+
+        // Given
+        String password = "testUpgrade";
+        String currentHash = Password.hashArgon2(password);
+        String legacyHash = Password.hash(password);
+
+        // When
+        PasswordVerification current = Password.verifyAndUpgrade(password, currentHash);
+        PasswordVerification invalid = Password.verifyAndUpgrade("wrong", legacyHash);
+
+        // Then
+        assertTrue(current.isValid());
+        assertNull(current.getUpgradedHash());
+        assertFalse(invalid.isValid());
+        assertNull(invalid.getUpgradedHash());
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#canVerify(String)}
+     * recognises hashes produced by this class.
+     */
+    @Test
+    public void shouldRecogniseSupportedHashes() {
+
+        // Given
+        String pbkdf2Hash = Password.hash("testCanVerify");
+        String argon2Hash = Password.hashArgon2("testCanVerify");
+
+        // When
+        boolean pbkdf2 = Password.canVerify(pbkdf2Hash);
+        boolean argon2 = Password.canVerify(argon2Hash);
+
+        // Then
+        assertTrue(pbkdf2);
+        assertTrue(argon2);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#canVerify(String)}
+     * rejects values in other formats.
+     */
+    @Test
+    public void shouldNotRecogniseUnsupportedHashes() {
+
+        // Given
+        String md5 = "5f4dcc3b5aa765d61d8327deb882cf99";
+        String bcrypt = "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy";
+
+        // When
+        boolean canVerifyMd5 = Password.canVerify(md5);
+        boolean canVerifyBcrypt = Password.canVerify(bcrypt);
+        boolean canVerifyNull = Password.canVerify(null);
+
+        // Then
+        assertFalse(canVerifyMd5);
+        assertFalse(canVerifyBcrypt);
+        assertFalse(canVerifyNull);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#readPassword(java.io.Reader, char[])}
+     * reads a line into a char array, which generates the same key as the String password
+     * and can then be wiped.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReadPasswordIntoWipeableBuffer() throws IOException {
+
+        // Given
+        String salt = Generate.salt();
+        char[] buffer = new char[64];
+
+        // When
+        int length = Password.readPassword(new StringReader("correct horse\r\nnext line"), buffer);
+        char[] password = Arrays.copyOf(buffer, length);
+        ByteArray.zeroize(buffer);
+        SecretKey key = Keys.generateSecretKey(password, salt);
+        ByteArray.zeroize(password);
+
+        // Then
+        assertEquals("correct horse".length(), length);
+        assertArrayEquals(Keys.generateSecretKey("correct horse", salt).getEncoded(), key.getEncoded());
+        assertArrayEquals(new char[64], buffer);
+        assertArrayEquals(new char[length], password);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#estimateCrackTime(double, double, TimeUnit)}
+     * increases with entropy and gives a sensible magnitude.
+     */
+    @Test
+    public void shouldEstimateCrackTime() {
+
+        // Given
+        double hashesPerSecond = 1000000;
+
+        // When
+        long fortyBits = Password.estimateCrackTime(40, hashesPerSecond, TimeUnit.SECONDS);
+        long fiftyBits = Password.estimateCrackTime(50, hashesPerSecond, TimeUnit.SECONDS);
+        long days = Password.estimateCrackTime(40, hashesPerSecond, TimeUnit.DAYS);
+        long huge = Password.estimateCrackTime(256, hashesPerSecond, TimeUnit.NANOSECONDS);
+
+        // Then
+        // 2^39 / 10^6 is about 549,756 seconds, or about 6.4 days:
+        assertEquals(549755, fortyBits);
+        assertEquals(6, days);
+        assertTrue(fiftyBits > fortyBits);
+        assertEquals(Long.MAX_VALUE, huge);
+    }
+
+    /**
+     * Verifies that {@link Password#readPassword(String)} reads piped input when there's no terminal.
+     * <p>
+     * Reading from a real terminal without echo can't be exercised in an automated test, so this
+     * covers the fallback used when input is piped in.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReadPasswordFromPipe() throws IOException {
+
+        // Given
+        ByteArrayOutputStream prompt = new ByteArrayOutputStream();
+        StringReader pipe = new StringReader("correct horse\n");
+
+        // When
+        char[] password = Password.readPassword("Password: ", pipe, new PrintStream(prompt, true));
+        char[] end = Password.readPassword("Password: ", pipe, new PrintStream(prompt, true));
+
+        // Then
+        assertArrayEquals("correct horse".toCharArray(), password);
+        assertNull(end);
+        assertEquals("Password: Password: ", prompt.toString());
+    }
+
+    /**
+     * Verifies that a password and its confirmation can be read one after the other from the same input.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReadPasswordAndConfirmation() throws IOException {
+
+        // Given
+        ByteArrayOutputStream prompt = new ByteArrayOutputStream();
+        StringReader pipe = new StringReader("correct horse
+battery staple
+");
+
+        // When
+        char[] password = Password.readPassword("Password: ", pipe, new PrintStream(prompt, true));
+        char[] confirm = Password.readPassword("Confirm: ", pipe, new PrintStream(prompt, true));
+
+        // Then
+        assertArrayEquals("correct horse".toCharArray(), password);
+        assertArrayEquals("battery staple".toCharArray(), confirm);
+        assertEquals("Password: Confirm: ", prompt.toString());
+    }
+
+    /**
+     * Verifies that reading a password from piped standard input doesn't consume the lines after it,
+     * including multi-byte UTF-8 characters.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldReadPasswordAndConfirmationFromStream() throws IOException {
+
+        // Given
+        ByteArrayOutputStream prompt = new ByteArrayOutputStream();
+        ByteArrayInputStream pipe = new ByteArrayInputStream(
+                "corr\u00e9ct h\u00f6rse \ud83d\udd11\r\ncorr\u00e9ct h\u00f6rse \ud83d\udd11\n".getBytes(StandardCharsets.UTF_8));
+
+        // When
+        char[] password = Password.readPassword("Password: ", pipe, new PrintStream(prompt, true));
+        char[] confirm = Password.readPassword("Confirm: ", pipe, new PrintStream(prompt, true));
+        char[] end = Password.readPassword("Password: ", pipe, new PrintStream(prompt, true));
+
+        // Then
+        assertArrayEquals("corr\u00e9ct h\u00f6rse \ud83d\udd11".toCharArray(), password);
+        assertArrayEquals(password, confirm);
+        assertNull(end);
+    }
+
+    /**
+     * Verifies that an Argon2id hash with a 64-byte digest verifies correctly.
+     */
+    @Test
+    public void shouldHashWithConfigurableLength() {
+
+        // Given
+        String password = "Mary had a little Caribou.";
+
+        // When
+        String hash = Password.hashArgon2(password, 64);
+
+        // Then
+        String digest = hash.substring(hash.lastIndexOf('$') + 1);
+        assertEquals(64, ByteArray.fromBase64(digest).length);
+        assertTrue(Password.verify(password, hash));
+        assertFalse(Password.verify("Mary had a little lamb.", hash));
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verify(String, String)}
+     * accepts a PHC string from the Argon2 reference implementation's test vectors.
+     */
+    @Test
+    public void shouldVerifyArgon2KnownAnswer() {
+
+        // Given
+        // From the reference implementation (phc-winner-argon2 test.c): t=2, m=2^8, p=1
+        String hash = "$argon2id$v=19$m=256,t=2,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+
+        // When
+        boolean correct = Password.verify("password", hash);
+        boolean incorrect = Password.verify("Password", hash);
+
+        // Then
+        assertTrue(correct);
+        assertFalse(incorrect);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verify(String, String)}
+     * returns false, rather than throwing or running away with resources, for Argon2id hashes
+     * with invalid or excessive parameters.
+     */
+    @Test
+    public void shouldRejectInvalidArgon2Parameters() {
+
+        // Given
+        String salt = "c29tZXNhbHQ";
+        String hash = "nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+        String[] invalid = {
+                "$argon2id$v=19$m=256,t=0,p=1$" + salt + "$" + hash,
+                "$argon2id$v=19$m=256,t=2,p=0$" + salt + "$" + hash,
+                "$argon2id$v=19$m=7,t=2,p=1$" + salt + "$" + hash,
+                "$argon2id$v=19$m=256,t=2,p=1$" + salt + "$AAA",
+                "$argon2id$v=19$m=999999999,t=2,p=1$" + salt + "$" + hash,
+                "$argon2id$v=19$m=256,t=999999999,p=1$" + salt + "$" + hash,
+                "$argon2id$v=19$m=256,t=2,p=999999999$" + salt + "$" + hash,
+        };
+
+        for (String value : invalid) {
+
+            // When
+            boolean result = Password.verify("password", value);
+
+            // Then
+            assertFalse(value, result);
+        }
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#parse(java.lang.String)}
+     * separates an Argon2id hash into its components and that {@link PasswordHash#toString()}
+     * reproduces the original hash.
+     */
+    @Test
+    public void shouldParseArgon2() {
+
+        // Given
+        String hash = "$argon2id$v=19$m=256,t=2,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+
+        // When
+        PasswordHash passwordHash = Password.parse(hash);
+
+        // Then
+        assertEquals(Password.ARGON2_ALGORITHM, passwordHash.getAlgorithm());
+        assertEquals(2, passwordHash.getIterations());
+        assertEquals(256, passwordHash.getArgon2Parameters().getMemoryKb());
+        assertEquals(1, passwordHash.getArgon2Parameters().getParallelism());
+        assertEquals("somesalt", ByteArray.toString(ByteArray.fromBase64(passwordHash.getSalt())));
+        assertEquals(hash, passwordHash.toString());
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#parse(java.lang.String)}
+     * rejects an Argon2id hash with invalid parameters.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldNotParseInvalidArgon2() {
+
+        // Given
+        String hash = "$argon2id$v=19$m=256,t=0,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+
+        // When
+        Password.parse(hash);
+
+        // Then
+        // We expect an exception.
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#canVerify(String)}
+     * recognises hashes produced under both {@link Keys#useStrongKeys()} and {@link Keys#useStandardKeys()}.
+     */
+    @Test
+    public void shouldRecogniseHashesOfEitherKeySize() {
+
+        // Given
+        int keySize = Keys.SYMMETRIC_KEY_SIZE;
+        String strong;
+        String standard;
+        boolean verifyStrong;
+        boolean verifyStandard;
+        try {
+            Keys.useStrongKeys();
+            strong = Password.hash("testCanVerify");
+            Keys.useStandardKeys();
+            standard = Password.hash("testCanVerify");
+
+            // Verify each with the other key size in effect:
+            verifyStrong = Password.verify("testCanVerify", strong);
+            Keys.useStrongKeys();
+            verifyStandard = Password.verify("testCanVerify", standard);
+        } finally {
+            Keys.SYMMETRIC_KEY_SIZE = keySize;
+        }
+
+        // When
+        boolean canVerifyStrong = Password.canVerify(strong);
+        boolean canVerifyStandard = Password.canVerify(standard);
+
+        // Then
+        assertTrue(canVerifyStrong);
+        assertTrue(canVerifyStandard);
+        assertTrue(verifyStrong);
+        assertTrue(verifyStandard);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#canVerify(String)}
+     * rejects Argon2id hashes with parameters that {@link Password#verify(String, String)} wouldn't accept.
+     */
+    @Test
+    public void shouldNotRecogniseInvalidArgon2Parameters() {
+
+        // Given
+        String zeroIterations = "$argon2id$v=19$m=256,t=0,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+        String excessiveMemory = "$argon2id$v=19$m=999999999,t=2,p=1$c29tZXNhbHQ$nf65EOgLrQMR/uIPnA4rEsF5h7TKyQwu9U1bMCHGi/4";
+
+        // When
+        boolean canVerifyZeroIterations = Password.canVerify(zeroIterations);
+        boolean canVerifyExcessiveMemory = Password.canVerify(excessiveMemory);
+
+        // Then
+        assertFalse(canVerifyZeroIterations);
+        assertFalse(canVerifyExcessiveMemory);
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#hash(String, int)}
+     * records the iteration count, so the hash verifies and parses with that count.
+     */
+    @Test
+    public void shouldHashWithIterations() {
+
+        // Given
+        String password = "testHashWithIterations";
+        int iterations = 2000;
+
+        // When
+        String hash = Password.hash(password, iterations);
+
+        // Then
+        assertTrue(hash.startsWith(Password.PBKDF2_PREFIX + "i=2000$"));
+        assertTrue(Password.verify(password, hash));
+        assertFalse(Password.verify("wrong", hash));
+        assertTrue(Password.canVerify(hash));
+        PasswordHash passwordHash = Password.parse(hash);
+        assertEquals(iterations, passwordHash.getIterations());
+        assertEquals(Password.PBKDF2_HASH_BYTES, ByteArray.fromBase64(passwordHash.getHash()).length);
+        assertEquals(hash, passwordHash.toString());
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verify(String, String)}
+     * returns false for a hash whose iteration count has been tampered with, rather than
+     * running for an excessive time.
+     */
+    @Test
+    public void shouldRejectExcessiveIterations() {
+
+        // Given
+        String hash = Password.hash("testIterations", 1000);
+        String tampered = hash.replace("i=1000$", "i=999999999$");
+
+        // When
+        boolean result = Password.verify("testIterations", tampered);
+
+        // Then
+        assertFalse(result);
+        assertFalse(Password.canVerify(tampered));
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#hash(String, int, int)}
+     * produces a hash of the requested length, which verifies.
+     */
+    @Test
+    public void shouldHashWithLength() {
+
+        // Given
+        String password = "testHashWithLength";
+
+        // When
+        String hash = Password.hash(password, 1000, 64);
+
+        // Then
+        assertEquals(64, ByteArray.fromBase64(Password.parse(hash).getHash()).length);
+        assertTrue(Password.verify(password, hash));
+        assertFalse(Password.verify("wrong", hash));
+    }
+
+    /**
+     * Verifies that
+     * {@link com.github.davidcarboni.cryptolite.Password#verify(String, String)}
+     * handles original-format hashes of lengths other than the current key size.
+     */
+    @Test
+    public void shouldVerifyOriginalFormatOfAnyLength() {
+
+        // Given
+        String password = "testAnyLength";
+        String salt = Generate.salt();
+        byte[] hash = Keys.generateSecretKey(password.toCharArray(), salt, Keys.SYMMETRIC_PASSWORD_ITERATIONS, 48 * 8).getEncoded();
+        String value = ByteArray.toBase64(ByteArray.concat(ByteArray.fromBase64(salt), hash));
+
+        // When
+        boolean correct = Password.verify(password, value);
+        boolean incorrect = Password.verify("wrong", value);
+
+        // Then
+        assertTrue(correct);
+        assertFalse(incorrect);
+    }
 }
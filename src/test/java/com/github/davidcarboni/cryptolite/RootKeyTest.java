@@ -0,0 +1,54 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Test;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertNotNull;
+import static org.junit.Assert.assertNull;
+
+/**
+ * Test for {@link RootKey}.
+ *
+ * @author David Carboni
+ */
+public class RootKeyTest {
+
+    /**
+     * Verifies that a root key survives a round trip through JSON with the same ID, creation time and key.
+     */
+    @Test
+    public void shouldRoundTripJson() {
+
+        // Given
+        RootKey rootKey = Keys.newRootKey();
+
+        // When
+        String json = rootKey.toJson();
+        RootKey loaded = RootKey.fromJson(json);
+
+        // Then
+        assertNotNull(rootKey.getKeyId());
+        assertEquals(rootKey.getKeyId(), loaded.getKeyId());
+        assertEquals(rootKey.getCreatedAt(), loaded.getCreatedAt());
+        assertArrayEquals(rootKey.getKey().getEncoded(), loaded.getKey().getEncoded());
+        assertEquals(json, loaded.toJson());
+        assertNull(RootKey.fromJson(null));
+    }
+
+    /**
+     * Verifies that JSON without a key is rejected.
+     */
+    @Test(expected = IllegalArgumentException.class)
+    public void shouldRejectMissingKey() {
+
+        // Given
+        String json = "{\"kid\":\"ABC\",\"createdAt\":1}";
+
+        // When
+        RootKey.fromJson(json);
+
+        // Then
+        // We should get an IllegalArgumentException
+    }
+}
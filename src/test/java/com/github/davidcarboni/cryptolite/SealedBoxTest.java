@@ -0,0 +1,116 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Test;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+
+/**
+ * Test for {@link SealedBox}.
+ *
+ * @author David Carboni
+ */
+public class SealedBoxTest {
+
+    /**
+     * Checks that a sealed box can be opened by the recipient.
+     */
+    @Test
+    public void shouldSealAndOpen() {
+
+        // Given
+        byte[][] recipient = SealedBox.newKeyPair();
+        byte[] message = ByteArray.fromString("For your eyes only.");
+
+        // When
+        byte[] box = SealedBox.seal(recipient[0], message);
+        byte[] opened = SealedBox.open(recipient[0], recipient[1], box);
+
+        // Then
+        assertEquals(message.length + SealedBox.OVERHEAD_BYTES, box.length);
+        assertArrayEquals(message, opened);
+    }
+
+    /**
+     * Checks that an altered sealed box is detected.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectAlteredBox() {
+
+        // Given
+        byte[][] recipient = SealedBox.newKeyPair();
+        byte[] box = SealedBox.seal(recipient[0], ByteArray.fromString("For your eyes only."));
+        box[box.length - 1]++;
+
+        // When
+        SealedBox.open(recipient[0], recipient[1], box);
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Checks that a sealed box can't be opened with a different key pair.
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldNotOpenWithWrongKey() {
+
+        // Given
+        byte[][] recipient = SealedBox.newKeyPair();
+        byte[][] other = SealedBox.newKeyPair();
+        byte[] box = SealedBox.seal(recipient[0], ByteArray.fromString("For your eyes only."));
+
+        // When
+        SealedBox.open(other[0], other[1], box);
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Checks that the ephemeral private key is wiped once the box has been sealed.
+     */
+    @Test
+    public void shouldWipeEphemeralPrivateKey() {
+
+        // Given
+        byte[][] recipient = SealedBox.newKeyPair();
+        byte[][] ephemeral = SealedBox.newKeyPair();
+        byte[] message = ByteArray.fromString("For your eyes only.");
+
+        // When
+        byte[] box = SealedBox.seal(recipient[0], message, ephemeral);
+
+        // Then
+        assertArrayEquals(new byte[SealedBox.PRIVATE_KEY_BYTES], ephemeral[1]);
+        assertArrayEquals(message, SealedBox.open(recipient[0], recipient[1], box));
+    }
+
+    /**
+     * Checks the output against a box produced by libsodium with a fixed ephemeral key pair, and that
+     * libsodium's box opens. The key pairs are Alice's (ephemeral) and Bob's (recipient) from RFC 7748.
+     * The expected box was produced with libsodium's <code>crypto_box_easy</code>, using the
+     * <code>crypto_box_seal</code> nonce, and checked with <code>crypto_box_seal_open</code>.
+     */
+    @Test
+    public void shouldMatchLibsodiumKnownAnswer() {
+
+        // Given
+        byte[][] ephemeral = {
+                ByteArray.fromHex("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"),
+                ByteArray.fromHex("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")};
+        byte[] recipientPublicKey = ByteArray.fromHex("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f");
+        byte[] recipientPrivateKey = ByteArray.fromHex("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb");
+        byte[] message = ByteArray.fromString("Sealed box known answer");
+        byte[] expected = ByteArray.fromHex("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"
+                + "a4808eeeeaa79d3cd06713e2ca7d731bf462cdaa8d9c2333bf1e6b1183145e254f393005cb236b");
+
+        // When
+        byte[] box = SealedBox.seal(recipientPublicKey, message, ephemeral);
+        byte[] opened = SealedBox.open(recipientPublicKey, recipientPrivateKey, expected);
+
+        // Then
+        assertArrayEquals(expected, box);
+        assertArrayEquals(message, opened);
+    }
+}
@@ -0,0 +1,139 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.junit.Before;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+import java.util.concurrent.TimeUnit;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertNull;
+import static org.junit.Assert.assertTrue;
+import static org.junit.Assert.fail;
+
+/**
+ * Test for {@link SecureCookie}.
+ *
+ * @author David Carboni
+ */
+public class SecureCookieTest {
+
+    SecureCookie secureCookie;
+    SecretKey key;
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    /**
+     * Instantiates a {@link SecureCookie} and generates a key.
+     */
+    @Before
+    public void setUp() {
+        secureCookie = new SecureCookie();
+        key = Keys.newSecretKey();
+    }
+
+    /**
+     * Checks that a valid cookie returns its value and is safe to use as a cookie value.
+     */
+    @Test
+    public void shouldOpenValidCookie() {
+
+        // Given
+        byte[] value = ByteArray.fromString("session=123");
+        String cookie = secureCookie.seal(value, key, 1, TimeUnit.HOURS);
+
+        // When
+        byte[] result = secureCookie.open(cookie, key);
+
+        // Then
+        assertArrayEquals(value, result);
+        assertTrue(cookie, cookie.matches("[A-Za-z0-9_-]+"));
+    }
+
+    /**
+     * Checks that an expired cookie is rejected.
+     *
+     * @throws InterruptedException If the sleep is interrupted.
+     */
+    @Test
+    public void shouldRejectExpiredCookie() throws InterruptedException {
+
+        // Given
+        String cookie = secureCookie.seal(ByteArray.fromString("session=123"), key, 1, TimeUnit.MILLISECONDS);
+        Thread.sleep(10);
+
+        // When
+        byte[] result = secureCookie.open(cookie, key);
+
+        // Then
+        assertNull(result);
+    }
+
+    /**
+     * Checks that a cookie whose expiry time has been extended is rejected.
+     */
+    @Test
+    public void shouldRejectTamperedCookie() {
+
+        // Given
+        String cookie = secureCookie.seal(ByteArray.fromString("session=123"), key, 1, TimeUnit.HOURS);
+        byte[] sealed = Base64.decodeBase64(cookie);
+        sealed[0] = 0x7f;
+        String tampered = Base64.encodeBase64URLSafeString(sealed);
+
+        // When
+        byte[] result = secureCookie.open(tampered, key);
+
+        // Then
+        assertNull(result);
+    }
+
+    /**
+     * Checks that a cookie whose encrypted format version has been altered is rejected, rather than throwing.
+     */
+    @Test
+    public void shouldRejectTamperedVersion() {
+
+        // Given
+        String cookie = secureCookie.seal(ByteArray.fromString("session=123"), key, 1, TimeUnit.HOURS);
+        byte[] sealed = Base64.decodeBase64(cookie);
+        sealed[8] = 0x7f;
+        String tampered = Base64.encodeBase64URLSafeString(sealed);
+
+        // When
+        byte[] result = secureCookie.open(tampered, key);
+
+        // Then
+        assertNull(result);
+    }
+
+    /**
+     * Checks that a ttl which is not positive, or which would overflow the expiry time, is rejected.
+     */
+    @Test
+    public void shouldRejectInvalidTtl() {
+
+        // Given
+        byte[] value = ByteArray.fromString("session=123");
+        long[] ttls = {0, -1, Long.MAX_VALUE};
+
+        for (long ttl : ttls) {
+            try {
+
+                // When
+                secureCookie.seal(value, key, ttl, TimeUnit.MILLISECONDS);
+
+                // Then
+                fail("Expected an exception for ttl " + ttl);
+            } catch (IllegalArgumentException e) {
+                // Expected
+            }
+        }
+    }
+}
@@ -0,0 +1,96 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.apache.commons.codec.binary.Base64;
+import org.apache.commons.lang.StringUtils;
+import org.junit.Before;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import java.security.KeyPair;
+import java.util.concurrent.TimeUnit;
+
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertNull;
+
+/**
+ * Test for {@link SignedToken}.
+ *
+ * @author David Carboni
+ */
+public class SignedTokenTest {
+
+    SignedToken signedToken;
+    static KeyPair keyPair;
+
+    /**
+     * Generates a signing {@link KeyPair}.
+     */
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        keyPair = Keys.newSigningKeyPair();
+    }
+
+    /**
+     * Instantiates a {@link SignedToken}.
+     */
+    @Before
+    public void setUp() {
+        signedToken = new SignedToken();
+    }
+
+    /**
+     * Checks that a valid token returns its payload.
+     */
+    @Test
+    public void shouldVerifyValidToken() {
+
+        // Given
+        String payload = "user=123";
+        String token = signedToken.issue(payload, keyPair.getPrivate(), 1, TimeUnit.HOURS);
+
+        // When
+        String result = signedToken.verify(token, keyPair.getPublic());
+
+        // Then
+        assertEquals(payload, result);
+    }
+
+    /**
+     * Checks that an expired token is rejected.
+     *
+     * @throws InterruptedException {@link InterruptedException}
+     */
+    @Test
+    public void shouldRejectExpiredToken() throws InterruptedException {
+
+        // Given
+        String token = signedToken.issue("user=123", keyPair.getPrivate(), 1, TimeUnit.MILLISECONDS);
+        Thread.sleep(10);
+
+        // When
+        String result = signedToken.verify(token, keyPair.getPublic());
+
+        // Then
+        assertNull(result);
+    }
+
+    /**
+     * Checks that a token with a tampered payload is rejected.
+     */
+    @Test
+    public void shouldRejectTamperedToken() {
+
+        // Given
+        String token = signedToken.issue("user=123", keyPair.getPrivate(), 1, TimeUnit.HOURS);
+        byte[] body = Base64.decodeBase64(StringUtils.substringBefore(token, SignedToken.SEPARATOR));
+        body[body.length - 1] = '4';
+        String tampered = Base64.encodeBase64URLSafeString(body) + SignedToken.SEPARATOR
+                + StringUtils.substringAfter(token, SignedToken.SEPARATOR);
+
+        // When
+        String result = signedToken.verify(tampered, keyPair.getPublic());
+
+        // Then
+        assertNull(result);
+    }
+}
@@ -0,0 +1,205 @@
+package com.github.davidcarboni.cryptolite;
+
+import org.junit.Before;
+import org.junit.BeforeClass;
+import org.junit.Test;
+
+import javax.crypto.SecretKey;
+import java.io.ByteArrayInputStream;
+import java.io.ByteArrayOutputStream;
+import java.io.IOException;
+import java.nio.ByteBuffer;
+import java.util.Arrays;
+import java.util.concurrent.CancellationException;
+import java.util.concurrent.atomic.AtomicBoolean;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertNotNull;
+
+/**
+ * Test for {@link StreamingCrypto}.
+ *
+ * @author David Carboni
+ */
+public class StreamingCryptoTest {
+
+    SecretKey key;
+
+    @BeforeClass
+    public static void setUpBeforeClass() {
+        // Use standard keys to make sure tests run in any environment:
+        Keys.useStandardKeys();
+    }
+
+    @Before
+    public void setup() {
+        key = Keys.newSecretKey();
+    }
+
+    /**
+     * Checks that each field of the header can be decoded as documented.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldWriteDocumentedHeader() throws IOException {
+
+        // Given
+        StreamingCrypto crypto = new StreamingCrypto(1000);
+
+        // When
+        byte[] encrypted = encrypt(crypto, Generate.byteArray(10));
+
+        // Then
+        ByteBuffer header = ByteBuffer.wrap(encrypted);
+        byte[] magic = new byte[StreamingCrypto.MAGIC_BYTES];
+        header.get(magic);
+        assertEquals("CLST", ByteArray.toString(magic));
+        assertEquals(StreamingCrypto.STREAM_VERSION, header.get());
+        assertEquals(AuthenticatedCrypto.CIPHER_ID, header.get());
+        assertEquals(1000, header.getInt());
+        assertEquals(StreamingCrypto.HEADER_SIZE, header.position() + AuthenticatedCrypto.NONCE_SIZE);
+        assertEquals(StreamingCrypto.HEADER_SIZE + 10 + AuthenticatedCrypto.TAG_BITS / 8, encrypted.length);
+    }
+
+    /**
+     * Checks that streams round-trip, including empty streams and whole numbers of chunks.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldEncryptAndDecrypt() throws IOException {
+
+        // Given
+        StreamingCrypto crypto = new StreamingCrypto(1024);
+
+        for (int length : new int[]{0, 1, 1023, 1024, 1025, 5000, 3 * 1024}) {
+            byte[] data = Generate.byteArray(length);
+
+            // When
+            byte[] decrypted = decrypt(crypto, encrypt(crypto, data));
+
+            // Then
+            assertArrayEquals(data, decrypted);
+        }
+    }
+
+    /**
+     * Checks that dropping the last chunk is detected.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test(expected = AuthenticationException.class)
+    public void shouldDetectTruncation() throws IOException {
+
+        // Given
+        StreamingCrypto crypto = new StreamingCrypto(1024);
+        byte[] encrypted = encrypt(crypto, Generate.byteArray(3000));
+        int chunk = 1024 + AuthenticatedCrypto.TAG_BITS / 8;
+        byte[] truncated = Arrays.copyOf(encrypted, StreamingCrypto.HEADER_SIZE + 2 * chunk);
+
+        // When
+        decrypt(crypto, truncated);
+
+        // Then
+        // We should get an AuthenticationException
+    }
+
+    /**
+     * Checks that a crafted header declaring an oversized chunk size is rejected before a buffer is allocated.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldRejectOversizedChunkSize() throws IOException {
+
+        // Given
+        StreamingCrypto crypto = new StreamingCrypto();
+        byte[] crafted = ByteBuffer.allocate(StreamingCrypto.HEADER_SIZE)
+                .put(ByteArray.fromString(StreamingCrypto.MAGIC))
+                .put((byte) StreamingCrypto.STREAM_VERSION)
+                .put((byte) AuthenticatedCrypto.CIPHER_ID)
+                .putInt(Integer.MAX_VALUE)
+                .array();
+
+        // When
+        decrypt(crypto, crafted);
+
+        // Then
+        // We should get a MalformedDataException, rather than an OutOfMemoryError
+    }
+
+    /**
+     * Checks that a configured maximum chunk size is enforced when decrypting.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test(expected = MalformedDataException.class)
+    public void shouldEnforceConfiguredMaxChunkSize() throws IOException {
+
+        // Given
+        byte[] encrypted = encrypt(new StreamingCrypto(4096), Generate.byteArray(10));
+        StreamingCrypto crypto = new StreamingCrypto();
+        crypto.setMaxChunkSize(1024);
+
+        // When
+        decrypt(crypto, encrypted);
+
+        // Then
+        // We should get a MalformedDataException
+    }
+
+    /**
+     * Checks that encryption can be cancelled part-way through and that the partial output doesn't decrypt.
+     *
+     * @throws IOException {@link IOException}
+     */
+    @Test
+    public void shouldCancelEncryption() throws IOException {
+
+        // Given
+        StreamingCrypto crypto = new StreamingCrypto(1024);
+        final AtomicBoolean cancelled = new AtomicBoolean();
+        ByteArrayInputStream source = new ByteArrayInputStream(Generate.byteArray(5000)) {
+            @Override
+            public synchronized int read(byte[] b, int off, int len) {
+                // Cancel after the first chunk has been read:
+                cancelled.set(true);
+                return super.read(b, off, len);
+            }
+        };
+        ByteArrayOutputStream destination = new ByteArrayOutputStream();
+
+        // When
+        CancellationException cancellation = null;
+        try {
+            crypto.encrypt(source, destination, key, cancelled);
+        } catch (CancellationException e) {
+            cancellation = e;
+        }
+        AuthenticationException authentication = null;
+        try {
+            decrypt(crypto, destination.toByteArray());
+        } catch (AuthenticationException e) {
+            authentication = e;
+        }
+
+        // Then
+        assertNotNull(cancellation);
+        assertEquals(StreamingCrypto.HEADER_SIZE + 1024 + AuthenticatedCrypto.TAG_BITS / 8, destination.size());
+        assertNotNull(authentication);
+    }
+
+    private byte[] encrypt(StreamingCrypto crypto, byte[] data) throws IOException {
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        crypto.encrypt(new ByteArrayInputStream(data), out, key);
+        return out.toByteArray();
+    }
+
+    private byte[] decrypt(StreamingCrypto crypto, byte[] encrypted) throws IOException {
+        ByteArrayOutputStream out = new ByteArrayOutputStream();
+        crypto.decrypt(new ByteArrayInputStream(encrypted), out, key);
+        return out.toByteArray();
+    }
+}